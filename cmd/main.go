@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -9,6 +10,11 @@ import (
 
 	"navmate-backend/config"
 	"navmate-backend/db"
+	"navmate-backend/internal/adapters/maps"
+	"navmate-backend/internal/events"
+	"navmate-backend/internal/handlers/booking"
+	"navmate-backend/internal/jobs"
+	"navmate-backend/internal/notify"
 	"navmate-backend/internal/routes"
 )
 
@@ -30,6 +36,33 @@ func main() {
 	}
 	sugar.Infow("db initialized")
 
+	// Background jobs
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mapsAdapter, err := maps.NewGoogleMapsAdapter(cfg.Google.GoogleMapsAPIKey, cfg.Ride.DefaultProvider, cfg.Money.DefaultRegion)
+	if err != nil {
+		sugar.Fatalf("maps adapter: %v", err)
+	}
+	deps, err := routes.NewDeps(cfg, db.DB)
+	if err != nil {
+		sugar.Fatalf("deps: %v", err)
+	}
+	deps.Log = sugar
+	if pg, ok := deps.Events.(*events.PostgresBus); ok {
+		go pg.Listen(ctx, sugar)
+	}
+	notifier := notify.Multi{notify.NewLogNotifier(sugar), notify.NewEventNotifier(deps.Events)}
+	go jobs.NewTripScheduler(db.DB, mapsAdapter, deps.FX, notifier, cfg, sugar).Run(ctx)
+	go jobs.NewPlanRetention(db.DB, cfg, sugar).Run(ctx)
+	go jobs.NewRidePoller(db.DB, deps.Rides, deps.Events, cfg, sugar).Run(ctx)
+	go jobs.NewHeartbeatReminder(db.DB, notifier, cfg, sugar).Run(ctx)
+	go jobs.NewPaymentWebhookProcessor(db.DB, deps.Payments, deps.Events, cfg, sugar).Run(ctx)
+	go jobs.NewPaymentReconciler(db.DB, deps.Payments, deps.Events, cfg, sugar).Run(ctx)
+	go jobs.NewSplitSettler(db.DB, deps.Payments, deps.Events, cfg, sugar).Run(ctx)
+	go jobs.NewReceiptIssuer(db.DB, deps.Mail, cfg, sugar).Run(ctx)
+	go jobs.NewBookingDispatcher(db.DB, booking.New(db.DB, deps.Rides, deps.Payments, deps.FX, deps.Events, cfg), notifier, cfg, sugar).Run(ctx)
+
 	// Router
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
@@ -38,7 +71,7 @@ func main() {
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,PATCH,DELETE,OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Authorization,Content-Type")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Authorization,Content-Type,Idempotency-Key")
 		if c.Request.Method == http.MethodOptions {
 			c.AbortWithStatus(http.StatusNoContent)
 			return
@@ -47,7 +80,7 @@ func main() {
 	})
 
 	// Routes
-	routes.SetupRouter(router, db.DB, cfg)
+	routes.SetupRouter(router, db.DB, cfg, deps)
 
 	// Start
 	addr := fmt.Sprintf(":%s", cfg.Server.Port)
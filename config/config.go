@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -20,6 +22,8 @@ type Config struct {
 	}
 	App struct {
 		Timezone string
+		Env      string   // development enables the /v1/dev endpoints
+		Admins   []string // emails allowed on the /v1/admin endpoints
 	}
 
 	Google struct {
@@ -28,6 +32,144 @@ type Config struct {
 		RedirectURL      string
 		GoogleMapsAPIKey string // NEW: Added Maps API Key
 	}
+
+	Trips struct {
+		ScheduleInterval      time.Duration // how often the trip scheduler wakes up
+		ScheduleRecheck       time.Duration // how often upcoming scheduled plans are re-estimated
+		ScheduleChangeMinutes int           // travel time delta that triggers a notification
+
+		ItineraryTTL      time.Duration // how long fares/ETAs of a new itinerary stay valid
+		PlanRetention     time.Duration // unselected plans older than this are cleaned up
+		RetentionMode     string        // archive|delete
+		RetentionInterval time.Duration
+	}
+
+	Ride struct {
+		Providers       []RideProviderConfig
+		DefaultProvider string        // provider put on RIDE legs by the routing adapter
+		QuoteTimeout    time.Duration // per-provider timeout when comparing quotes
+		QuoteTTL        time.Duration // how long a provider quote can be booked
+		PollInterval    time.Duration // how often active bookings are polled for status
+		SimSeed         int64         // ride simulator seed; 0 = different every run
+		SimScenario     string        // ride simulator script, see ride.Scenario*
+		// provider callbacks signed longer ago than this are rejected (replay protection)
+		WebhookTolerance time.Duration
+	}
+
+	Booking struct {
+		CancelFreeWindow             time.Duration // cancelling within this window after booking is free
+		CancelFeeCents               int           // fee after the free window, in Money.Currency
+		CancelFeeDriverAssignedCents int           // fee once a driver is on the way
+		MaxAttempts                  int           // provider attempts per booking, including fallbacks
+		RetryBackoff                 time.Duration // first wait before retrying the same provider; doubles each time
+		AttemptsTimeout              time.Duration // all attempts of a booking, waits included, must fit in this
+		DispatchLead                 time.Duration // advance bookings are sent to the provider this long before pickup
+		DispatchInterval             time.Duration // how often due advance bookings are dispatched
+	}
+
+	Events struct {
+		Backend   string        // memory|postgres (LISTEN/NOTIFY, shared across instances)
+		KeepAlive time.Duration // comment line sent on idle event streams
+	}
+
+	Safety struct {
+		ReminderInterval time.Duration // how often due heartbeats are checked for reminders
+	}
+
+	Money struct {
+		Currency      string // currency of the amounts configured here (fees), ISO 4217
+		DefaultRegion string // fare region for routes outside every known region, see maps.FareRules
+		FXSource      string // static
+		FXRatesFile   string // JSON rates for the static source; empty = built-in rates
+	}
+
+	Payment struct {
+		Gateway   string // mock (in-process) | omise
+		APIURL    string // Omise-compatible API base URL
+		SecretKey string
+		Timeout   time.Duration
+		ReturnURL string // where e-wallet payments send the user back to
+
+		WebhookSecret    string        // base64 signing secret of the gateway's webhooks
+		WebhookTolerance time.Duration // webhooks signed longer ago than this are rejected (replay protection)
+		WebhookInterval  time.Duration // how often stored webhooks are processed
+
+		BookingFeeCents int // service fee added to every booking's fare when authorizing, in Money.Currency
+
+		AuthHold          time.Duration // uncaptured authorizations older than this are released
+		ReconcileInterval time.Duration // how often open authorizations are settled
+
+		SplitTimeout         time.Duration // how long participants have to pay their share of a split fare
+		SplitMaxParticipants int
+	}
+
+	Receipt struct {
+		Prefix    string // receipt numbers are PREFIX-YEAR-000001
+		VATRateBP int    // VAT included in every price, in basis points (700 = 7%)
+		// seller details printed on every receipt
+		BusinessName    string
+		BusinessTaxID   string
+		BusinessAddress string
+		BusinessEmail   string
+		Interval        time.Duration // how often receipts are issued for newly captured payments
+	}
+
+	Mail struct {
+		Sender       string // "" (disabled) | file | smtp
+		From         string
+		Dir          string // file: where .eml files are written
+		SMTPHost     string
+		SMTPPort     int
+		SMTPUsername string
+		SMTPPassword string
+	}
+}
+
+// RideProviderConfig describes one ride-hailing provider, e.g. RIDE_PROVIDERS=RideNow:1.0,GoCab:0.92
+type RideProviderConfig struct {
+	Name          string
+	PriceFactor   float64 // multiplier applied to the routing estimate
+	WebhookSecret string  // signing secret of the provider's status callbacks, from RIDE_WEBHOOK_SECRETS
+}
+
+func parseRideProviders(spec string) []RideProviderConfig {
+	var out []RideProviderConfig
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, factor, _ := strings.Cut(part, ":")
+		pc := RideProviderConfig{Name: strings.TrimSpace(name), PriceFactor: 1.0}
+		if f, err := strconv.ParseFloat(strings.TrimSpace(factor), 64); err == nil && f > 0 {
+			pc.PriceFactor = f
+		}
+		out = append(out, pc)
+	}
+	return out
+}
+
+// parseRideWebhookSecrets reads name:secret pairs, comma separated.
+func parseRideWebhookSecrets(spec string) map[string]string {
+	out := map[string]string{}
+	for _, part := range parseList(spec) {
+		name, secret, ok := strings.Cut(part, ":")
+		if ok && strings.TrimSpace(secret) != "" {
+			out[strings.TrimSpace(name)] = strings.TrimSpace(secret)
+		}
+	}
+	return out
+}
+
+// parseList splits a comma separated value, dropping empty items.
+func parseList(spec string) []string {
+	var out []string
+	for _, part := range strings.Split(spec, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
 }
 
 func getEnv(key, def string) string {
@@ -46,6 +188,15 @@ func getEnvInt(key string, def int) int {
 	return def
 }
 
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return def
+}
+
 func Load() *Config {
 	_ = godotenv.Load()
 
@@ -57,11 +208,84 @@ func Load() *Config {
 	cfg.Database.Password = getEnv("DB_PASSWORD", "postgres")
 	cfg.Database.DBName = getEnv("DB_NAME", "navmate")
 	cfg.App.Timezone = getEnv("APP_TIMEZONE", "Asia/Bangkok")
+	cfg.App.Env = getEnv("APP_ENV", "production")
+	cfg.App.Admins = parseList(getEnv("ADMIN_EMAILS", ""))
 	cfg.Google.ClientID = getEnv("GOOGLE_CLIENT_ID", "")
 	cfg.Google.ClientSecret = getEnv("GOOGLE_CLIENT_SECRET", "")
 	cfg.Google.RedirectURL = getEnv("GOOGLE_REDIRECT_URL", "http://localhost:8080/auth/google/callback")
 	// NEW: Load the Maps API key from .env file
 	cfg.Google.GoogleMapsAPIKey = getEnv("GOOGLE_MAPS_API_KEY", "")
 
+	cfg.Trips.ScheduleInterval = getEnvDuration("TRIP_SCHEDULE_INTERVAL", time.Minute)
+	cfg.Trips.ScheduleRecheck = getEnvDuration("TRIP_SCHEDULE_RECHECK", 10*time.Minute)
+	cfg.Trips.ScheduleChangeMinutes = getEnvInt("TRIP_SCHEDULE_CHANGE_MINUTES", 10)
+	cfg.Trips.ItineraryTTL = getEnvDuration("ITINERARY_TTL", 15*time.Minute)
+	cfg.Trips.PlanRetention = getEnvDuration("PLAN_RETENTION", 72*time.Hour)
+	cfg.Trips.RetentionMode = getEnv("PLAN_RETENTION_MODE", "archive")
+	cfg.Trips.RetentionInterval = getEnvDuration("PLAN_RETENTION_INTERVAL", time.Hour)
+
+	cfg.Ride.Providers = parseRideProviders(getEnv("RIDE_PROVIDERS", "RideNow:1.0,GoCab:0.92,TukTukGo:0.85"))
+	cfg.Ride.DefaultProvider = getEnv("RIDE_DEFAULT_PROVIDER", "RideNow")
+	cfg.Ride.QuoteTimeout = getEnvDuration("RIDE_QUOTE_TIMEOUT", 3*time.Second)
+	cfg.Ride.QuoteTTL = getEnvDuration("RIDE_QUOTE_TTL", 5*time.Minute)
+	cfg.Ride.PollInterval = getEnvDuration("RIDE_POLL_INTERVAL", 30*time.Second)
+	cfg.Ride.SimSeed = int64(getEnvInt("RIDE_SIM_SEED", 0))
+	cfg.Ride.SimScenario = getEnv("RIDE_SIM_SCENARIO", "random")
+	cfg.Ride.WebhookTolerance = getEnvDuration("RIDE_WEBHOOK_TOLERANCE", 5*time.Minute)
+	// RIDE_WEBHOOK_SECRETS=RideNow:secret,GoCab:secret; providers without one can't send callbacks
+	secrets := parseRideWebhookSecrets(getEnv("RIDE_WEBHOOK_SECRETS", ""))
+	for i := range cfg.Ride.Providers {
+		cfg.Ride.Providers[i].WebhookSecret = secrets[cfg.Ride.Providers[i].Name]
+	}
+
+	cfg.Booking.CancelFreeWindow = getEnvDuration("BOOKING_CANCEL_FREE_WINDOW", 2*time.Minute)
+	cfg.Booking.CancelFeeCents = getEnvInt("BOOKING_CANCEL_FEE_CENTS", 2000)
+	cfg.Booking.CancelFeeDriverAssignedCents = getEnvInt("BOOKING_CANCEL_FEE_DRIVER_ASSIGNED_CENTS", 4000)
+	cfg.Booking.MaxAttempts = getEnvInt("BOOKING_MAX_ATTEMPTS", 3)
+	cfg.Booking.RetryBackoff = getEnvDuration("BOOKING_RETRY_BACKOFF", 500*time.Millisecond)
+	cfg.Booking.AttemptsTimeout = getEnvDuration("BOOKING_ATTEMPTS_TIMEOUT", 20*time.Second)
+	cfg.Booking.DispatchLead = getEnvDuration("BOOKING_DISPATCH_LEAD", 15*time.Minute)
+	cfg.Booking.DispatchInterval = getEnvDuration("BOOKING_DISPATCH_INTERVAL", time.Minute)
+
+	cfg.Events.Backend = getEnv("EVENT_BUS", "postgres")
+	cfg.Events.KeepAlive = getEnvDuration("EVENT_STREAM_KEEPALIVE", 25*time.Second)
+
+	cfg.Safety.ReminderInterval = getEnvDuration("SAFETY_REMINDER_INTERVAL", 30*time.Second)
+
+	cfg.Money.Currency = getEnv("CURRENCY", "THB")
+	cfg.Money.DefaultRegion = getEnv("FARE_DEFAULT_REGION", "TH")
+	cfg.Money.FXSource = getEnv("FX_SOURCE", "static")
+	cfg.Money.FXRatesFile = getEnv("FX_RATES_FILE", "")
+
+	cfg.Payment.Gateway = getEnv("PAYMENT_GATEWAY", "mock")
+	cfg.Payment.APIURL = getEnv("PAYMENT_API_URL", "https://api.omise.co")
+	cfg.Payment.SecretKey = getEnv("PAYMENT_SECRET_KEY", "")
+	cfg.Payment.Timeout = getEnvDuration("PAYMENT_TIMEOUT", 10*time.Second)
+	cfg.Payment.ReturnURL = getEnv("PAYMENT_RETURN_URL", "")
+	cfg.Payment.WebhookSecret = getEnv("PAYMENT_WEBHOOK_SECRET", "")
+	cfg.Payment.WebhookTolerance = getEnvDuration("PAYMENT_WEBHOOK_TOLERANCE", 5*time.Minute)
+	cfg.Payment.WebhookInterval = getEnvDuration("PAYMENT_WEBHOOK_INTERVAL", 5*time.Second)
+	cfg.Payment.BookingFeeCents = getEnvInt("PAYMENT_BOOKING_FEE_CENTS", 0)
+	cfg.Payment.AuthHold = getEnvDuration("PAYMENT_AUTH_HOLD", 7*24*time.Hour)
+	cfg.Payment.ReconcileInterval = getEnvDuration("PAYMENT_RECONCILE_INTERVAL", time.Minute)
+	cfg.Payment.SplitTimeout = getEnvDuration("SPLIT_TIMEOUT", 30*time.Minute)
+	cfg.Payment.SplitMaxParticipants = getEnvInt("SPLIT_MAX_PARTICIPANTS", 7)
+
+	cfg.Receipt.Prefix = getEnv("RECEIPT_PREFIX", "NM")
+	cfg.Receipt.VATRateBP = getEnvInt("RECEIPT_VAT_BP", 700)
+	cfg.Receipt.BusinessName = getEnv("BUSINESS_NAME", "NavMate")
+	cfg.Receipt.BusinessTaxID = getEnv("BUSINESS_TAX_ID", "")
+	cfg.Receipt.BusinessAddress = getEnv("BUSINESS_ADDRESS", "")
+	cfg.Receipt.BusinessEmail = getEnv("BUSINESS_EMAIL", "")
+	cfg.Receipt.Interval = getEnvDuration("RECEIPT_INTERVAL", time.Minute)
+
+	cfg.Mail.Sender = getEnv("MAIL_SENDER", "")
+	cfg.Mail.From = getEnv("MAIL_FROM", "")
+	cfg.Mail.Dir = getEnv("MAIL_DIR", "mail-out")
+	cfg.Mail.SMTPHost = getEnv("SMTP_HOST", "")
+	cfg.Mail.SMTPPort = getEnvInt("SMTP_PORT", 587)
+	cfg.Mail.SMTPUsername = getEnv("SMTP_USERNAME", "")
+	cfg.Mail.SMTPPassword = getEnv("SMTP_PASSWORD", "")
+
 	return cfg
 }
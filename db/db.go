@@ -51,6 +51,20 @@ func InitDB(cfg *config.Config) (*gorm.DB, error) {
 		&models.Payment{},
 		&models.SafetySession{},
 		&models.Heartbeat{},
+		&models.TripSchedule{},
+		&models.RideQuote{},
+		&models.UserPreference{},
+		&models.BookingEvent{},
+		&models.PaymentWebhookEvent{},
+		&models.PaymentTransaction{},
+		&models.PaymentMethod{},
+		&models.Promotion{},
+		&models.PromotionRedemption{},
+		&models.CreditTransaction{},
+		&models.Receipt{},
+		&models.InvoiceCounter{},
+		&models.FareSplit{},
+		&models.SplitShare{},
 	)
 
 	DB = db
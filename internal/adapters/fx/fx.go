@@ -0,0 +1,49 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"navmate-backend/internal/money"
+)
+
+var ErrNoRate = errors.New("no exchange rate")
+
+// RateSource is implemented by every exchange-rate integration.
+type RateSource interface {
+	Name() string
+	// Rate is the price of one major unit of from in major units of to.
+	Rate(ctx context.Context, from, to string) (float64, error)
+}
+
+type Options struct {
+	Source    string // static
+	RatesFile string // static: JSON rates file; empty = built-in rates
+}
+
+// New builds the rate source selected by FX_SOURCE.
+func New(opts Options) (RateSource, error) {
+	switch opts.Source {
+	case "", "static":
+		if opts.RatesFile == "" {
+			return NewStatic(DefaultBase, DefaultRates), nil
+		}
+		return LoadStatic(opts.RatesFile)
+	default:
+		return nil, fmt.Errorf("unknown fx source %q", opts.Source)
+	}
+}
+
+// Convert returns m in currency to and the rate used, so callers can keep the
+// rate a quote was converted at.
+func Convert(ctx context.Context, src RateSource, m money.Money, to string) (money.Money, float64, error) {
+	if m.Currency == to {
+		return m, 1, nil
+	}
+	rate, err := src.Rate(ctx, m.Currency, to)
+	if err != nil {
+		return money.Money{}, 0, err
+	}
+	return money.Convert(m, to, rate), rate, nil
+}
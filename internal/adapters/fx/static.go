@@ -0,0 +1,87 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultRates are units of each currency per US dollar, used when no rates
+// file is configured. Good enough for display; load a file for anything else.
+const DefaultBase = "USD"
+
+var DefaultRates = map[string]float64{
+	"USD": 1, "THB": 36.5, "EUR": 0.92, "GBP": 0.79, "SGD": 1.35, "MYR": 4.7, "IDR": 15700,
+	"PHP": 56.5, "HKD": 7.8, "AUD": 1.52, "CNY": 7.25, "LAK": 21500, "VND": 25400, "JPY": 150, "KRW": 1380,
+}
+
+// Static serves rates from a fixed table, for offline use and tests. Rates
+// between two non-base currencies are crossed through the base.
+type Static struct {
+	base  string
+	rates map[string]float64 // units per one base unit
+	asOf  time.Time
+}
+
+func NewStatic(base string, rates map[string]float64) *Static {
+	r := make(map[string]float64, len(rates)+1)
+	for k, v := range rates {
+		r[k] = v
+	}
+	r[base] = 1
+	return &Static{base: base, rates: r}
+}
+
+// ratesFile is the format of FX_RATES_FILE:
+//
+//	{"base": "USD", "as_of": "2026-10-01T00:00:00Z", "rates": {"THB": 36.5, "JPY": 150}}
+type ratesFile struct {
+	Base  string             `json:"base"`
+	AsOf  time.Time          `json:"as_of"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// LoadStatic reads a rates file.
+func LoadStatic(path string) (*Static, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fx rates: %w", err)
+	}
+	var f ratesFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("fx rates %s: %w", path, err)
+	}
+	if f.Base == "" {
+		return nil, fmt.Errorf("fx rates %s: base is required", path)
+	}
+	for cur, r := range f.Rates {
+		if r <= 0 {
+			return nil, fmt.Errorf("fx rates %s: rate of %s must be positive", path, cur)
+		}
+	}
+	s := NewStatic(f.Base, f.Rates)
+	s.asOf = f.AsOf
+	return s, nil
+}
+
+func (s *Static) Name() string { return "static" }
+
+// AsOf is when the file's rates were taken; zero for the built-in table.
+func (s *Static) AsOf() time.Time { return s.asOf }
+
+func (s *Static) Rate(_ context.Context, from, to string) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	f, ok := s.rates[from]
+	if !ok {
+		return 0, fmt.Errorf("%w from %s", ErrNoRate, from)
+	}
+	t, ok := s.rates[to]
+	if !ok {
+		return 0, fmt.Errorf("%w to %s", ErrNoRate, to)
+	}
+	return t / f, nil
+}
@@ -0,0 +1,133 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// ErrDisabled is returned by callers that need a Sender when MAIL_SENDER is empty.
+var ErrDisabled = errors.New("mail is not configured")
+
+// Attachment is a file sent with a message, e.g. a PDF receipt.
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Message is one email. Text and HTML are alternatives of the same body;
+// either may be empty.
+type Message struct {
+	To          string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []Attachment
+}
+
+// Sender delivers email.
+type Sender interface {
+	Name() string
+	Send(ctx context.Context, m Message) error
+}
+
+type Options struct {
+	Sender   string // "" (disabled) | file | smtp
+	From     string
+	Dir      string // file: where messages are written
+	Host     string // smtp
+	Port     int
+	Username string
+	Password string
+}
+
+// New returns the configured sender, or nil when mail is disabled.
+func New(opts Options) (Sender, error) {
+	switch opts.Sender {
+	case "":
+		return nil, nil
+	case "file":
+		return NewFile(opts.Dir, opts.From), nil
+	case "smtp":
+		if opts.Host == "" || opts.From == "" {
+			return nil, errors.New("mail sender smtp: SMTP_HOST and MAIL_FROM are required")
+		}
+		return NewSMTP(opts.Host, opts.Port, opts.Username, opts.Password, opts.From), nil
+	default:
+		return nil, fmt.Errorf("unknown mail sender %q", opts.Sender)
+	}
+}
+
+// encode renders m as a MIME message from from.
+func encode(m Message, from string, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", m.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", w.Boundary())
+
+	var body bytes.Buffer
+	alt := multipart.NewWriter(&body)
+	for _, part := range []struct{ typ, content string }{{"text/plain", m.Text}, {"text/html", m.HTML}} {
+		if part.content == "" {
+			continue
+		}
+		pw, err := alt.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.typ + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64(pw, []byte(part.content))
+	}
+	if err := alt.Close(); err != nil {
+		return nil, err
+	}
+	pw, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {"multipart/alternative; boundary=" + alt.Boundary()}})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pw.Write(body.Bytes()); err != nil {
+		return nil, err
+	}
+
+	for _, a := range m.Attachments {
+		pw, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {a.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64(pw, a.Data)
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBase64 writes data base64 encoded in 76 character lines.
+func writeBase64(w interface{ Write([]byte) (int, error) }, data []byte) {
+	enc := base64.StdEncoding.EncodeToString(data)
+	var sb strings.Builder
+	for len(enc) > 76 {
+		sb.WriteString(enc[:76] + "\r\n")
+		enc = enc[76:]
+	}
+	sb.WriteString(enc + "\r\n")
+	_, _ = w.Write([]byte(sb.String()))
+}
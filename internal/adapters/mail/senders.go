@@ -0,0 +1,89 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"navmate-backend/internal/utils"
+)
+
+// File writes every message as an .eml file instead of sending it. Meant
+// for development: the files open in any mail client.
+type File struct {
+	dir  string
+	from string
+}
+
+func NewFile(dir, from string) *File {
+	if dir == "" {
+		dir = "mail-out"
+	}
+	if from == "" {
+		from = "NavMate <no-reply@localhost>"
+	}
+	return &File{dir: dir, from: from}
+}
+
+func (f *File) Name() string { return "file" }
+
+func (f *File) Send(_ context.Context, m Message) error {
+	now := time.Now()
+	raw, err := encode(m, f.from, now)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405"), utils.RandomToken(4))
+	return os.WriteFile(filepath.Join(f.dir, name), raw, 0o644)
+}
+
+// SMTP sends through a mail server, authenticating with PLAIN when a
+// username is set (net/smtp only allows that over TLS or to localhost).
+type SMTP struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTP(host string, port int, username, password, from string) *SMTP {
+	if port == 0 {
+		port = 587
+	}
+	s := &SMTP{addr: net.JoinHostPort(host, strconv.Itoa(port)), from: from}
+	if username != "" {
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+func (s *SMTP) Name() string { return "smtp" }
+
+func (s *SMTP) Send(_ context.Context, m Message) error {
+	raw, err := encode(m, s.from, time.Now())
+	if err != nil {
+		return err
+	}
+	addr, err := mailAddress(s.from)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(s.addr, s.auth, addr, []string{m.To}, raw)
+}
+
+// mailAddress is the bare address of "Name <addr>".
+func mailAddress(from string) (string, error) {
+	a, err := netmail.ParseAddress(from)
+	if err != nil {
+		return "", fmt.Errorf("MAIL_FROM: %w", err)
+	}
+	return a.Address, nil
+}
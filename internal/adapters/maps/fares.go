@@ -0,0 +1,58 @@
+package maps
+
+import "math"
+
+// FareRule prices rides and transit in one region, in that region's currency.
+type FareRule struct {
+	Region       string
+	Currency     string
+	BaseCents    int
+	PerKmCents   int
+	PerMinCents  int
+	TransitCents int // flat transit fare
+
+	// rough bounding box; the first region containing a route's start wins
+	minLat, maxLat, minLng, maxLng float64
+}
+
+// FareRules are the regions we price. Order matters where boxes overlap.
+var FareRules = []FareRule{
+	{Region: "SG", Currency: "SGD", BaseCents: 400, PerKmCents: 70, PerMinCents: 25, TransitCents: 200,
+		minLat: 1.15, maxLat: 1.48, minLng: 103.6, maxLng: 104.1},
+	{Region: "TH", Currency: "THB", BaseCents: 4000, PerKmCents: 800, PerMinCents: 200, TransitCents: 3500,
+		minLat: 5.6, maxLat: 20.5, minLng: 97.3, maxLng: 105.7},
+	{Region: "MY", Currency: "MYR", BaseCents: 300, PerKmCents: 100, PerMinCents: 25, TransitCents: 250,
+		minLat: 0.85, maxLat: 7.4, minLng: 99.6, maxLng: 119.3},
+	{Region: "VN", Currency: "VND", BaseCents: 12000, PerKmCents: 9000, PerMinCents: 500, TransitCents: 7000,
+		minLat: 8.2, maxLat: 23.4, minLng: 102.1, maxLng: 109.5},
+	{Region: "JP", Currency: "JPY", BaseCents: 500, PerKmCents: 400, PerMinCents: 80, TransitCents: 250,
+		minLat: 24, maxLat: 46, minLng: 122.9, maxLng: 146},
+}
+
+// FareRuleFor returns the rule of the named region, ok=false if unknown.
+func FareRuleFor(region string) (FareRule, bool) {
+	for _, r := range FareRules {
+		if r.Region == region {
+			return r, true
+		}
+	}
+	return FareRule{}, false
+}
+
+// FareRuleAt returns the region containing the coordinates, or def.
+func FareRuleAt(lat, lng float64, def FareRule) FareRule {
+	for _, r := range FareRules {
+		if lat >= r.minLat && lat <= r.maxLat && lng >= r.minLng && lng <= r.maxLng {
+			return r
+		}
+	}
+	return def
+}
+
+// RideFare is the base fare plus distance and time, in the rule's currency.
+func (r FareRule) RideFare(distanceMeters int, durationSeconds int) int {
+	km := float64(distanceMeters) / 1000.0
+	minutes := float64(durationSeconds) / 60.0
+	cost := float64(r.BaseCents) + (km * float64(r.PerKmCents)) + (minutes * float64(r.PerMinCents))
+	return int(math.Round(cost))
+}
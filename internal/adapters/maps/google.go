@@ -17,21 +17,26 @@ type LegOpt struct {
 	Minutes   int
 	DistanceM int64
 	Provider  *string
+	FareCents int // this leg's share of RoughCostCents
 }
 type ItinOpt struct {
 	ModeMix        string
 	TotalMinutes   int
 	RoughCostCents int
+	Currency       string // of the fares, set by the region the route starts in
 	Legs           []LegOpt
 }
 
 // GoogleMapsAdapter handles communication with Google Maps APIs
 type GoogleMapsAdapter struct {
-	client *maps.Client
+	client       *maps.Client
+	rideProvider string   // provider name put on RIDE legs
+	region       FareRule // fares for routes outside every known region
 }
 
-// NewGoogleMapsAdapter creates a new adapter instance
-func NewGoogleMapsAdapter(apiKey string) (*GoogleMapsAdapter, error) {
+// NewGoogleMapsAdapter creates a new adapter instance. defaultRegion prices
+// routes that can't be placed in a region, see FareRules.
+func NewGoogleMapsAdapter(apiKey, rideProvider, defaultRegion string) (*GoogleMapsAdapter, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("Google Maps API key is missing")
 	}
@@ -39,7 +44,17 @@ func NewGoogleMapsAdapter(apiKey string) (*GoogleMapsAdapter, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create google maps client: %w", err)
 	}
-	return &GoogleMapsAdapter{client: client}, nil
+	if rideProvider == "" {
+		rideProvider = "RideNow"
+	}
+	if defaultRegion == "" {
+		defaultRegion = "TH"
+	}
+	region, ok := FareRuleFor(defaultRegion)
+	if !ok {
+		return nil, fmt.Errorf("unknown fare region %q", defaultRegion)
+	}
+	return &GoogleMapsAdapter{client: client, rideProvider: rideProvider, region: region}, nil
 }
 
 // EstimateItineraries calculates route options using Google Directions API
@@ -71,11 +86,14 @@ func (a *GoogleMapsAdapter) EstimateItineraries(origin, destination string, depa
 	// Process DRIVING route to create a RIDE option
 	if len(drivingRoute) > 0 && len(drivingRoute[0].Legs) > 0 {
 		leg := drivingRoute[0].Legs[0]
-		rideProvider := "RideNow"
+		rideProvider := a.rideProvider
+		rule := FareRuleAt(leg.StartLocation.Lat, leg.StartLocation.Lng, a.region)
+		fare := rule.RideFare(leg.Distance.Meters, int(leg.Duration.Seconds()))
 		options = append(options, ItinOpt{
 			ModeMix:        "RIDE",
 			TotalMinutes:   int(math.Round(leg.Duration.Minutes())),
-			RoughCostCents: calculateRideFare(leg.Distance.Meters, int(leg.Duration.Seconds())),
+			RoughCostCents: fare,
+			Currency:       rule.Currency,
 			Legs: []LegOpt{{
 				Mode:      "RIDE",
 				From:      leg.StartAddress,
@@ -83,6 +101,7 @@ func (a *GoogleMapsAdapter) EstimateItineraries(origin, destination string, depa
 				Minutes:   int(math.Round(leg.Duration.Minutes())),
 				DistanceM: int64(leg.Distance.Meters),
 				Provider:  &rideProvider,
+				FareCents: fare,
 			}},
 		})
 	}
@@ -90,16 +109,19 @@ func (a *GoogleMapsAdapter) EstimateItineraries(origin, destination string, depa
 	// Process TRANSIT route to create a WALK+TRANSIT option
 	if len(transitRoute) > 0 && len(transitRoute[0].Legs) > 0 {
 		leg := transitRoute[0].Legs[0]
+		rule := FareRuleAt(leg.StartLocation.Lat, leg.StartLocation.Lng, a.region)
 		options = append(options, ItinOpt{
 			ModeMix:        "WALK+TRANSIT",
 			TotalMinutes:   int(math.Round(leg.Duration.Minutes())),
-			RoughCostCents: 3500, // Assume a flat fee for transit for simplicity
+			RoughCostCents: rule.TransitCents, // Assume a flat fee for transit for simplicity
+			Currency:       rule.Currency,
 			Legs: []LegOpt{{
 				Mode:      "TRANSIT", // Simplified to one leg for now
 				From:      leg.StartAddress,
 				To:        leg.EndAddress,
 				Minutes:   int(math.Round(leg.Duration.Minutes())),
 				DistanceM: int64(leg.Distance.Meters),
+				FareCents: rule.TransitCents,
 			}},
 		})
 	}
@@ -107,29 +129,18 @@ func (a *GoogleMapsAdapter) EstimateItineraries(origin, destination string, depa
 	// If no options were found from Google, return the original stub data as a fallback
 	if len(options) == 0 {
 		log.Println("No routes found from Google, falling back to stub data.")
-		return getStubData(origin, destination)
+		return getStubData(origin, destination, a.rideProvider, a.region)
 	}
 
 	return options
 }
 
-// calculateRideFare is a simple fare calculation model.
-// Example: 40 THB base fare + 8 THB/km + 2 THB/min
-func calculateRideFare(distanceMeters int, durationSeconds int) int {
-	baseFareCents := 4000
-	perKmCents := 800
-	perMinCents := 200
-	km := float64(distanceMeters) / 1000.0
-	minutes := float64(durationSeconds) / 60.0
-	cost := float64(baseFareCents) + (km * float64(perKmCents)) + (minutes * float64(perMinCents))
-	return int(math.Round(cost))
-}
-
-// getStubData provides fallback data if Google API fails
-func getStubData(origin, destination string) []ItinOpt {
-	ride := "RideNow"
+// getStubData provides fallback data if Google API fails, priced in the default region
+func getStubData(origin, destination, rideProvider string, rule FareRule) []ItinOpt {
+	ride := rideProvider
+	fare := rule.RideFare(9000, 18*60)
 	return []ItinOpt{
-		{ModeMix: "RIDE", TotalMinutes: 18, RoughCostCents: 12000, Legs: []LegOpt{{Mode: "RIDE", From: origin, To: destination, Minutes: 18, DistanceM: 9000, Provider: &ride}}},
-		{ModeMix: "WALK+TRANSIT", TotalMinutes: 42, RoughCostCents: 3000, Legs: []LegOpt{{Mode: "WALK", From: origin, To: "Station A", Minutes: 8, DistanceM: 600}, {Mode: "TRANSIT", From: "Station A", To: "Station B", Minutes: 30, DistanceM: 12000}, {Mode: "WALK", From: "Station B", To: destination, Minutes: 4, DistanceM: 300}}},
+		{ModeMix: "RIDE", TotalMinutes: 18, RoughCostCents: fare, Currency: rule.Currency, Legs: []LegOpt{{Mode: "RIDE", From: origin, To: destination, Minutes: 18, DistanceM: 9000, Provider: &ride, FareCents: fare}}},
+		{ModeMix: "WALK+TRANSIT", TotalMinutes: 42, RoughCostCents: rule.TransitCents, Currency: rule.Currency, Legs: []LegOpt{{Mode: "WALK", From: origin, To: "Station A", Minutes: 8, DistanceM: 600}, {Mode: "TRANSIT", From: "Station A", To: "Station B", Minutes: 30, DistanceM: 12000, FareCents: rule.TransitCents}, {Mode: "WALK", From: "Station B", To: destination, Minutes: 4, DistanceM: 300}}},
 	}
 }
@@ -0,0 +1,125 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Payment statuses as stored on models.Payment.
+const (
+	StatusPending           = "pending" // waiting for the customer, e.g. 3-D Secure
+	StatusAuthorized        = "authorized"
+	StatusCaptured          = "captured"
+	StatusPartiallyRefunded = "partially_refunded"
+	StatusRefunded          = "refunded"
+	StatusVoided            = "voided"
+	StatusDeclined          = "declined"
+	StatusExpired           = "expired"  // authorization lapsed before capture
+	StatusDisputed          = "disputed" // the cardholder opened a chargeback
+)
+
+var ErrNotFound = errors.New("payment not found at gateway")
+
+// GatewayError is an error answer from the gateway API, e.g. capturing more
+// than was authorized.
+type GatewayError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *GatewayError) Error() string {
+	return fmt.Sprintf("payment gateway: %s: %s (HTTP %d)", e.Code, e.Message, e.StatusCode)
+}
+
+// AuthRequest pays with exactly one of Source, Customer or SourceType.
+type AuthRequest struct {
+	AmountCents    int
+	Currency       string // ISO 4217, e.g. THB
+	Source         string // one-time card token from the client SDK
+	Customer       string // saved card, see SaveCard
+	SourceType     string // redirect/QR payment, e.g. promptpay, truemoney; answered with StatusPending
+	Description    string
+	IdempotencyKey string // same key = same charge
+}
+
+// SavedCard is a card stored at the gateway for later charges. Only Ref and
+// display details are kept on our side.
+type SavedCard struct {
+	Ref      string // gateway customer id, used as AuthRequest.Customer
+	Brand    string // e.g. Visa
+	Last4    string
+	ExpMonth int
+	ExpYear  int
+}
+
+// Charge is the gateway's view of a payment.
+type Charge struct {
+	ExternalRef    string
+	Status         string // see Status*
+	AmountCents    int
+	CapturedCents  int
+	RefundedCents  int
+	FailureCode    string // set when declined
+	FailureMessage string
+	AuthorizeURI   string // set when pending: where the customer completes the payment
+}
+
+// PaymentGateway is implemented by every payment service provider integration.
+type PaymentGateway interface {
+	Name() string
+	// Authorize holds the amount on the customer's card without charging it.
+	// A declined card is a Charge with StatusDeclined, not an error.
+	Authorize(ctx context.Context, req AuthRequest) (Charge, error)
+	// Capture charges amountCents (at most the authorized amount) of an authorization.
+	Capture(ctx context.Context, externalRef string, amountCents int) (Charge, error)
+	// Void releases an authorization without charging.
+	Void(ctx context.Context, externalRef string) (Charge, error)
+	// Refund returns amountCents of a captured payment. A retry with the same
+	// idempotencyKey does not refund again.
+	Refund(ctx context.Context, externalRef string, amountCents int, idempotencyKey string) (Charge, error)
+	Status(ctx context.Context, externalRef string) (Charge, error)
+	// SaveCard stores the card behind a one-time token for later charges.
+	SaveCard(ctx context.Context, token, description string) (SavedCard, error)
+	// RemoveCard forgets a saved card.
+	RemoveCard(ctx context.Context, ref string) error
+	// ParseWebhook verifies the request's signature and timestamp and decodes the event.
+	ParseWebhook(r *http.Request) (WebhookEvent, error)
+}
+
+type Options struct {
+	Gateway          string // mock|omise
+	APIURL           string
+	SecretKey        string
+	Timeout          time.Duration
+	WebhookSecret    string
+	WebhookTolerance time.Duration
+	ReturnURL        string
+}
+
+// New returns the gateway selected by PAYMENT_GATEWAY. The mock gateway runs
+// the Omise client against an in-process MockServer, so nothing leaves the
+// process and no key is needed.
+func New(opts Options) (PaymentGateway, error) {
+	switch opts.Gateway {
+	case "", "mock":
+		return NewMockGateway(opts.WebhookSecret, opts.WebhookTolerance)
+	case "omise":
+		if opts.SecretKey == "" {
+			return nil, errors.New("payment gateway omise: PAYMENT_SECRET_KEY is required")
+		}
+		return NewOmise("omise", OmiseOptions{
+			BaseURL:          opts.APIURL,
+			SecretKey:        opts.SecretKey,
+			WebhookSecret:    opts.WebhookSecret,
+			WebhookTolerance: opts.WebhookTolerance,
+			Client:           &http.Client{Timeout: opts.Timeout},
+			ReturnURI:        opts.ReturnURL,
+		})
+	default:
+		return nil, fmt.Errorf("unknown payment gateway %q", opts.Gateway)
+	}
+}
@@ -0,0 +1,252 @@
+package payment
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"navmate-backend/internal/utils"
+)
+
+// MockTokenFailPrefix declines the card: "tokn_test_fail_insufficient_fund"
+// is declined with failure_code insufficient_fund. Any other token succeeds,
+// also when saved with SaveCard. Redirect/QR payments (source types) stay
+// pending until the customer pays.
+const MockTokenFailPrefix = "tokn_test_fail_"
+
+// MockServer is an in-memory Omise-compatible charges API for local runs and
+// tests, e.g. httptest.NewServer(payment.NewMockServer()) + NewOmise.
+type MockServer struct {
+	mux *http.ServeMux
+
+	mu        sync.Mutex
+	charges   map[string]*omiseCharge
+	idem      map[string]string // Idempotency-Key -> charge id
+	refunds   map[string]string // Idempotency-Key -> refund id
+	customers map[string]string // customer id -> the token its card was saved from
+}
+
+func NewMockServer() *MockServer {
+	s := &MockServer{
+		mux: http.NewServeMux(), charges: map[string]*omiseCharge{}, idem: map[string]string{}, refunds: map[string]string{},
+		customers: map[string]string{},
+	}
+	s.mux.HandleFunc("POST /charges", s.create)
+	s.mux.HandleFunc("GET /charges/{id}", s.get)
+	s.mux.HandleFunc("POST /charges/{id}/capture", s.capture)
+	s.mux.HandleFunc("POST /charges/{id}/reverse", s.reverse)
+	s.mux.HandleFunc("POST /charges/{id}/refunds", s.refund)
+	s.mux.HandleFunc("POST /customers", s.createCustomer)
+	s.mux.HandleFunc("DELETE /customers/{id}", s.deleteCustomer)
+	return s
+}
+
+// NewMockGateway is the Omise client wired straight to a MockServer.
+func NewMockGateway(webhookSecret string, webhookTolerance time.Duration) (*Omise, error) {
+	return NewOmise("mock", OmiseOptions{
+		BaseURL:          "http://mock-gateway",
+		SecretKey:        "skey_test_mock",
+		WebhookSecret:    webhookSecret,
+		WebhookTolerance: webhookTolerance,
+		Client:           &http.Client{Transport: handlerTransport{NewMockServer()}},
+	})
+}
+
+// handlerTransport serves requests with an http.Handler instead of the network.
+type handlerTransport struct{ h http.Handler }
+
+func (t handlerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.h.ServeHTTP(rec, r)
+	return rec.Result(), nil
+}
+
+func (s *MockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if key, _, ok := r.BasicAuth(); !ok || key == "" {
+		mockError(w, http.StatusUnauthorized, "authentication_failure", "authentication failed")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func mockError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(omiseCharge{Object: "error", Code: code, Message: msg})
+}
+
+func mockCharge(w http.ResponseWriter, c *omiseCharge) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(c)
+}
+
+func formInt(r *http.Request, key string) (int, bool) {
+	n, err := strconv.Atoi(r.FormValue(key))
+	return n, err == nil && n > 0
+}
+
+func (s *MockServer) create(w http.ResponseWriter, r *http.Request) {
+	amount, ok := formInt(r, "amount")
+	if !ok {
+		mockError(w, http.StatusBadRequest, "invalid_amount", "amount must be a positive integer")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := r.Header.Get("Idempotency-Key")
+	if id, seen := s.idem[key]; key != "" && seen {
+		mockCharge(w, s.charges[id])
+		return
+	}
+
+	c := &omiseCharge{
+		Object: "charge", ID: "chrg_test_" + utils.RandomToken(12), Amount: amount,
+		Currency: r.FormValue("currency"), Status: "pending", Authorized: true,
+	}
+	card := r.FormValue("card")
+	if cust := r.FormValue("customer"); cust != "" {
+		saved, ok := s.customers[cust]
+		if !ok {
+			mockError(w, http.StatusNotFound, "not_found", "customer was not found")
+			return
+		}
+		card = saved
+	}
+	if r.FormValue("source[type]") != "" {
+		c.Authorized = false
+		c.AuthorizeURI = "https://mock-gateway/pay/" + c.ID
+	}
+	if strings.HasPrefix(card, MockTokenFailPrefix) {
+		c.Status, c.Authorized = "failed", false
+		c.FailureCode = strings.TrimPrefix(card, MockTokenFailPrefix)
+		c.FailureMessage = "the card was declined"
+	}
+	s.charges[c.ID] = c
+	if key != "" {
+		s.idem[key] = c.ID
+	}
+	mockCharge(w, c)
+}
+
+// find must be called with s.mu held; it writes the 404 itself.
+func (s *MockServer) find(w http.ResponseWriter, r *http.Request) *omiseCharge {
+	c, ok := s.charges[r.PathValue("id")]
+	if !ok {
+		mockError(w, http.StatusNotFound, "not_found", "charge was not found")
+	}
+	return c
+}
+
+func (s *MockServer) get(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c := s.find(w, r); c != nil {
+		mockCharge(w, c)
+	}
+}
+
+func (s *MockServer) capture(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.find(w, r)
+	if c == nil {
+		return
+	}
+	if !c.Authorized || c.Paid || c.Reversed || c.Status != "pending" {
+		mockError(w, http.StatusBadRequest, "failed_capture", "charge is not awaiting capture")
+		return
+	}
+	amount, ok := formInt(r, "capture_amount")
+	if !ok {
+		amount = c.Amount
+	}
+	if amount > c.Amount {
+		mockError(w, http.StatusBadRequest, "invalid_amount", "capture amount exceeds the authorized amount")
+		return
+	}
+	c.Paid, c.Status, c.CapturedAmount = true, "successful", amount
+	mockCharge(w, c)
+}
+
+func (s *MockServer) reverse(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.find(w, r)
+	if c == nil {
+		return
+	}
+	if c.Paid || c.Status != "pending" {
+		mockError(w, http.StatusBadRequest, "failed_reverse", "only uncaptured charges can be reversed")
+		return
+	}
+	c.Reversed, c.Status = true, "reversed"
+	mockCharge(w, c)
+}
+
+func (s *MockServer) refund(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.find(w, r)
+	if c == nil {
+		return
+	}
+	amount, ok := formInt(r, "amount")
+	if !ok {
+		mockError(w, http.StatusBadRequest, "invalid_amount", "amount must be a positive integer")
+		return
+	}
+	key := r.Header.Get("Idempotency-Key")
+	id, seen := s.refunds[key]
+	if key == "" || !seen {
+		if !c.Paid || amount > c.CapturedAmount-c.RefundedAmount {
+			mockError(w, http.StatusBadRequest, "failed_refund", "refund exceeds the captured amount")
+			return
+		}
+		c.RefundedAmount += amount
+		id = "rfnd_test_" + utils.RandomToken(12)
+		if key != "" {
+			s.refunds[key] = id
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"object": "refund", "id": id, "amount": amount, "charge": c.ID,
+	})
+}
+
+func (s *MockServer) createCustomer(w http.ResponseWriter, r *http.Request) {
+	token := r.FormValue("card")
+	if token == "" {
+		mockError(w, http.StatusBadRequest, "invalid_card", "card token is required")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := "cust_test_" + utils.RandomToken(12)
+	s.customers[id] = token
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"object": "customer", "id": id,
+		"cards": map[string]any{"data": []map[string]any{{
+			"object": "card", "id": "card_test_" + utils.RandomToken(12), "brand": "Visa", "last_digits": "4242",
+			"expiration_month": 12, "expiration_year": time.Now().Year() + 3,
+		}}},
+	})
+}
+
+func (s *MockServer) deleteCustomer(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := r.PathValue("id")
+	if _, ok := s.customers[id]; !ok {
+		mockError(w, http.StatusNotFound, "not_found", "customer was not found")
+		return
+	}
+	delete(s.customers, id)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"object": "customer", "id": id, "deleted": true})
+}
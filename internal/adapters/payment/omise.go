@@ -0,0 +1,250 @@
+package payment
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Omise talks to an Omise-compatible charges API: an uncaptured charge is the
+// authorization, which is later captured, reversed (void) or refunded. The
+// secret key is sent as the basic-auth user and amounts are in the smallest
+// currency unit, like our *_cents fields.
+type Omise struct {
+	name             string
+	baseURL          string
+	secretKey        string
+	returnURI        string
+	webhookKey       []byte
+	webhookTolerance time.Duration
+	client           *http.Client
+}
+
+type OmiseOptions struct {
+	BaseURL          string
+	SecretKey        string
+	WebhookSecret    string        // base64, as shown by the gateway; empty rejects every webhook
+	WebhookTolerance time.Duration // max age of a webhook's signed timestamp
+	Client           *http.Client  // nil = http.DefaultClient
+	ReturnURI        string        // where redirect payments (e.g. e-wallets) send the customer back to
+}
+
+func NewOmise(name string, opts OmiseOptions) (*Omise, error) {
+	key, err := base64.StdEncoding.DecodeString(opts.WebhookSecret)
+	if err != nil {
+		return nil, fmt.Errorf("payment gateway %s: webhook secret is not base64: %w", name, err)
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.WebhookTolerance <= 0 {
+		opts.WebhookTolerance = 5 * time.Minute
+	}
+	return &Omise{
+		name: name, baseURL: strings.TrimRight(opts.BaseURL, "/"), secretKey: opts.SecretKey, returnURI: opts.ReturnURI,
+		webhookKey: key, webhookTolerance: opts.WebhookTolerance, client: opts.Client,
+	}, nil
+}
+
+func (o *Omise) Name() string { return o.name }
+
+// omiseCharge is the charge object; errors come back as object "error".
+type omiseCharge struct {
+	Object         string `json:"object"`
+	ID             string `json:"id"`
+	Amount         int    `json:"amount"`
+	Currency       string `json:"currency"`
+	Status         string `json:"status"` // pending|successful|failed|reversed|expired
+	Authorized     bool   `json:"authorized"`
+	Paid           bool   `json:"paid"`
+	Reversed       bool   `json:"reversed"`
+	CapturedAmount int    `json:"captured_amount"`
+	RefundedAmount int    `json:"refunded_amount"`
+	FailureCode    string `json:"failure_code"`
+	FailureMessage string `json:"failure_message"`
+	AuthorizeURI   string `json:"authorize_uri"`
+	// error object
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (c omiseCharge) charge() Charge {
+	ch := Charge{
+		ExternalRef: c.ID, AmountCents: c.Amount, RefundedCents: c.RefundedAmount,
+		FailureCode: c.FailureCode, FailureMessage: c.FailureMessage,
+	}
+	if c.Paid {
+		ch.CapturedCents = c.CapturedAmount
+		if ch.CapturedCents == 0 {
+			ch.CapturedCents = c.Amount
+		}
+	}
+	switch {
+	case c.Status == "failed":
+		ch.Status = StatusDeclined
+	case c.Reversed || c.Status == "reversed":
+		ch.Status = StatusVoided
+	case c.Status == "expired":
+		ch.Status = StatusExpired
+	case c.Paid && ch.RefundedCents >= ch.CapturedCents:
+		ch.Status = StatusRefunded
+	case c.Paid && ch.RefundedCents > 0:
+		ch.Status = StatusPartiallyRefunded
+	case c.Paid:
+		ch.Status = StatusCaptured
+	case c.Authorized:
+		ch.Status = StatusAuthorized
+	default:
+		ch.Status = StatusPending
+		ch.AuthorizeURI = c.AuthorizeURI
+	}
+	return ch
+}
+
+func (o *Omise) Authorize(ctx context.Context, req AuthRequest) (Charge, error) {
+	form := url.Values{
+		"amount":   {strconv.Itoa(req.AmountCents)},
+		"currency": {strings.ToLower(req.Currency)},
+		"capture":  {"false"},
+	}
+	switch {
+	case req.Source != "":
+		form.Set("card", req.Source)
+	case req.Customer != "":
+		form.Set("customer", req.Customer)
+	case req.SourceType != "":
+		form.Set("source[type]", req.SourceType)
+		if o.returnURI != "" {
+			form.Set("return_uri", o.returnURI)
+		}
+	}
+	if req.Description != "" {
+		form.Set("description", req.Description)
+	}
+	return o.do(ctx, http.MethodPost, "/charges", form, req.IdempotencyKey)
+}
+
+func (o *Omise) Capture(ctx context.Context, externalRef string, amountCents int) (Charge, error) {
+	form := url.Values{"capture_amount": {strconv.Itoa(amountCents)}}
+	return o.do(ctx, http.MethodPost, "/charges/"+url.PathEscape(externalRef)+"/capture", form, "")
+}
+
+func (o *Omise) Void(ctx context.Context, externalRef string) (Charge, error) {
+	return o.do(ctx, http.MethodPost, "/charges/"+url.PathEscape(externalRef)+"/reverse", nil, "")
+}
+
+// Refund answers with a refund object, so the charge is re-read afterwards.
+func (o *Omise) Refund(ctx context.Context, externalRef string, amountCents int, idempotencyKey string) (Charge, error) {
+	form := url.Values{"amount": {strconv.Itoa(amountCents)}}
+	if _, err := o.do(ctx, http.MethodPost, "/charges/"+url.PathEscape(externalRef)+"/refunds", form, idempotencyKey); err != nil {
+		return Charge{}, err
+	}
+	return o.Status(ctx, externalRef)
+}
+
+func (o *Omise) Status(ctx context.Context, externalRef string) (Charge, error) {
+	return o.do(ctx, http.MethodGet, "/charges/"+url.PathEscape(externalRef), nil, "")
+}
+
+// omiseCustomer holds saved cards; we save one card per customer.
+type omiseCustomer struct {
+	ID    string `json:"id"`
+	Cards struct {
+		Data []struct {
+			ID              string `json:"id"`
+			Brand           string `json:"brand"`
+			LastDigits      string `json:"last_digits"`
+			ExpirationMonth int    `json:"expiration_month"`
+			ExpirationYear  int    `json:"expiration_year"`
+		} `json:"data"`
+	} `json:"cards"`
+}
+
+func (o *Omise) SaveCard(ctx context.Context, token, description string) (SavedCard, error) {
+	form := url.Values{"card": {token}}
+	if description != "" {
+		form.Set("description", description)
+	}
+	var cust omiseCustomer
+	if err := o.call(ctx, http.MethodPost, "/customers", form, "", &cust); err != nil {
+		return SavedCard{}, err
+	}
+	if len(cust.Cards.Data) == 0 {
+		return SavedCard{}, fmt.Errorf("payment gateway %s: customer %s has no card", o.name, cust.ID)
+	}
+	card := cust.Cards.Data[0]
+	return SavedCard{
+		Ref: cust.ID, Brand: card.Brand, Last4: card.LastDigits,
+		ExpMonth: card.ExpirationMonth, ExpYear: card.ExpirationYear,
+	}, nil
+}
+
+func (o *Omise) RemoveCard(ctx context.Context, ref string) error {
+	return o.call(ctx, http.MethodDelete, "/customers/"+url.PathEscape(ref), nil, "", nil)
+}
+
+func (o *Omise) do(ctx context.Context, method, path string, form url.Values, idemKey string) (Charge, error) {
+	var c omiseCharge
+	if err := o.call(ctx, method, path, form, idemKey, &c); err != nil {
+		return Charge{}, err
+	}
+	return c.charge(), nil
+}
+
+// call sends one API request and decodes the answer into out (may be nil).
+func (o *Omise) call(ctx context.Context, method, path string, form url.Values, idemKey string, out any) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, o.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(o.secretKey, "")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idemKey != "" {
+		req.Header.Set("Idempotency-Key", idemKey)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("payment gateway %s: %w", o.name, err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("payment gateway %s: read %s %s: %w", o.name, method, path, err)
+	}
+	var e struct {
+		Object  string `json:"object"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return fmt.Errorf("payment gateway %s: decode %s %s: %w", o.name, method, path, err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode >= 300 || e.Object == "error" {
+		return &GatewayError{StatusCode: resp.StatusCode, Code: e.Code, Message: e.Message}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("payment gateway %s: decode %s %s: %w", o.name, method, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package ride
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrQuoteNotFound  = errors.New("quote not found")
+	ErrQuoteExpired   = errors.New("quote expired")
+	ErrUnknownBooking = errors.New("unknown booking reference")
+	ErrCancelRejected = errors.New("provider rejected cancellation")
+)
+
+type QuoteRequest struct {
+	From           string
+	To             string
+	DistanceM      int64
+	Minutes        int
+	RoughFareCents int    // routing estimate, used as the pricing baseline
+	Currency       string // of RoughFareCents; quotes come back in the same currency
+}
+
+type Quote struct {
+	ID              string    `json:"quote_id"`
+	Provider        string    `json:"provider"`
+	FareCents       int       `json:"fare_cents"`
+	Currency        string    `json:"currency"`
+	EtaMinutes      int       `json:"eta_minutes"`
+	SurgeMultiplier float64   `json:"surge_multiplier"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
+
+type BookRequest struct {
+	QuoteID     string
+	From        string
+	To          string
+	AcceptSurge bool // the user agreed to the surged quote returned by a previous Book
+}
+
+type BookingResult struct {
+	Provider    string
+	Status      string // confirmed, surge_too_high, failed
+	EtaMinutes  int
+	FareCents   int
+	ExternalRef string
+	SurgeQuote  *Quote // set with surge_too_high: book it with AcceptSurge to take the surged fare
+}
+
+// StatusUpdate is a provider-side change to a booking, from polling or a webhook.
+type StatusUpdate struct {
+	ExternalRef string  `json:"external_ref"`
+	Status      string  `json:"status"`
+	EtaMinutes  int     `json:"eta_minutes"`
+	Driver      *Driver `json:"driver,omitempty"`
+}
+
+type Driver struct {
+	Name         string   `json:"name"`
+	VehiclePlate string   `json:"vehicle_plate"`
+	VehicleModel string   `json:"vehicle_model"`
+	Lat          *float64 `json:"lat,omitempty"`
+	Lng          *float64 `json:"lng,omitempty"`
+}
+
+// RideProvider is implemented by every ride-hailing integration.
+type RideProvider interface {
+	Name() string
+	Quote(ctx context.Context, req QuoteRequest) (Quote, error)
+	Book(ctx context.Context, req BookRequest) (BookingResult, error)
+	Cancel(ctx context.Context, externalRef string) error
+	Status(ctx context.Context, externalRef string) (StatusUpdate, error)
+	// ParseWebhook authenticates and decodes a provider callback.
+	ParseWebhook(r *http.Request) (StatusUpdate, error)
+}
@@ -0,0 +1,103 @@
+package ride
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"navmate-backend/internal/money"
+)
+
+// Registry holds the configured ride providers by name.
+type Registry struct {
+	providers   map[string]RideProvider
+	order       []string
+	defaultName string
+}
+
+func NewRegistry(defaultName string, providers ...RideProvider) *Registry {
+	r := &Registry{providers: map[string]RideProvider{}, defaultName: defaultName}
+	for _, p := range providers {
+		r.providers[p.Name()] = p
+		r.order = append(r.order, p.Name())
+	}
+	if _, ok := r.providers[defaultName]; !ok && len(r.order) > 0 {
+		r.defaultName = r.order[0]
+	}
+	return r
+}
+
+func (r *Registry) Get(name string) (RideProvider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+// Default is the provider name put on RIDE legs when planning.
+func (r *Registry) Default() string { return r.defaultName }
+
+func (r *Registry) All() []RideProvider {
+	out := make([]RideProvider, 0, len(r.order))
+	for _, name := range r.order {
+		out = append(out, r.providers[name])
+	}
+	return out
+}
+
+// QuoteResult is one provider's answer in a quote comparison.
+type QuoteResult struct {
+	Quote    *Quote       `json:"quote,omitempty"`
+	Display  *money.Money `json:"display,omitempty"` // the fare in the user's preferred currency, set by the caller
+	Provider string       `json:"provider"`
+	Error    string       `json:"error,omitempty"`
+	// the discount of the promo code the caller was asked about, in the quote's currency
+	DiscountCents int `json:"discount_cents,omitempty"`
+}
+
+// CompareQuotes asks every provider for a quote concurrently. Providers that
+// error or do not answer within timeout are reported with an error. Successful
+// quotes come first, cheapest first.
+func (r *Registry) CompareQuotes(ctx context.Context, req QuoteRequest, timeout time.Duration) []QuoteResult {
+	providers := r.All()
+	results := make([]QuoteResult, len(providers))
+
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func(i int, p RideProvider) {
+			defer wg.Done()
+			qctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			done := make(chan QuoteResult, 1)
+			go func() {
+				q, err := p.Quote(qctx, req)
+				if err != nil {
+					done <- QuoteResult{Provider: p.Name(), Error: err.Error()}
+					return
+				}
+				done <- QuoteResult{Provider: p.Name(), Quote: &q}
+			}()
+			select {
+			case res := <-done:
+				results[i] = res
+			case <-qctx.Done():
+				results[i] = QuoteResult{Provider: p.Name(), Error: "timeout"}
+			}
+		}(i, p)
+	}
+	wg.Wait()
+
+	sort.SliceStable(results, func(a, b int) bool {
+		qa, qb := results[a].Quote, results[b].Quote
+		switch {
+		case qa == nil:
+			return false
+		case qb == nil:
+			return true
+		default:
+			return qa.FareCents < qb.FareCents
+		}
+	})
+	return results
+}
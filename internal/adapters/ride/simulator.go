@@ -0,0 +1,358 @@
+package ride
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scenario scripts for the simulator. "fail:N" fails the next N booking
+// attempts and confirms after that.
+const (
+	ScenarioRandom  = "random"  // seeded mix: 70% confirm, 20% surge, 10% fail
+	ScenarioConfirm = "confirm" // always confirm
+	ScenarioSurge   = "surge"   // first booking of every quote surges 1.8x
+	scenarioFail    = "fail"
+)
+
+// Outcomes that can be forced with Simulator.Force.
+const (
+	OutcomeConfirm        = "confirm"
+	OutcomeSurge          = "surge"
+	OutcomeFail           = "fail"
+	OutcomeCancelRejected = "cancel_rejected" // applies to the next Cancel
+)
+
+const scriptedSurge = 1.8
+
+// SimClock is the simulators' shared notion of time. Advance moves it forward
+// so driver progression can be scripted without waiting.
+type SimClock struct {
+	mu     sync.Mutex
+	now    func() time.Time
+	offset time.Duration
+}
+
+// NewSimClock wraps now (time.Now when nil).
+func NewSimClock(now func() time.Time) *SimClock {
+	if now == nil {
+		now = time.Now
+	}
+	return &SimClock{now: now}
+}
+
+func (c *SimClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now().Add(c.offset)
+}
+
+func (c *SimClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.offset += d
+	c.mu.Unlock()
+}
+
+type SimulatorOptions struct {
+	PriceFactor float64
+	QuoteTTL    time.Duration
+	Seed        int64     // same seed + same calls = same outcomes
+	Clock       *SimClock // nil = real time
+	Scenario    string    // see Scenario*; empty = random
+	// callbacks must be signed with WebhookSecret (see SignWebhook) no longer
+	// than WebhookTolerance ago; without a secret every callback is refused
+	WebhookSecret    string
+	WebhookTolerance time.Duration // 0 = 5 minutes
+}
+
+// Simulator is an in-process ride provider with seeded, scriptable outcomes.
+// A booking is confirmed, gets a driver after a minute, is picked up at its
+// ETA and completes after the trip's minutes, all on the simulator clock.
+type Simulator struct {
+	name        string
+	priceFactor float64
+	quoteTTL    time.Duration
+	clock       *SimClock
+
+	webhookSecret    []byte
+	webhookTolerance time.Duration
+
+	mu             sync.Mutex
+	rng            *rand.Rand
+	scenario       string
+	failLeft       int
+	forced         []string // queued Book outcomes
+	rejectCancels  int
+	quotes         map[string]simQuote
+	rides          map[string]simRide
+	surgedQuoteIDs map[string]bool // quotes the surge scenario already surged
+}
+
+type simQuote struct {
+	Quote
+	tripMinutes int
+}
+
+type simRide struct {
+	bookedAt    time.Time
+	etaMinutes  int
+	tripMinutes int
+}
+
+func NewSimulator(name string, opts SimulatorOptions) (*Simulator, error) {
+	if opts.Clock == nil {
+		opts.Clock = NewSimClock(nil)
+	}
+	if opts.PriceFactor <= 0 {
+		opts.PriceFactor = 1
+	}
+	if opts.WebhookTolerance <= 0 {
+		opts.WebhookTolerance = 5 * time.Minute
+	}
+	s := &Simulator{
+		name:             name,
+		priceFactor:      opts.PriceFactor,
+		quoteTTL:         opts.QuoteTTL,
+		clock:            opts.Clock,
+		webhookSecret:    []byte(opts.WebhookSecret),
+		webhookTolerance: opts.WebhookTolerance,
+		rng:              rand.New(rand.NewSource(opts.Seed)),
+		quotes:           map[string]simQuote{},
+		rides:            map[string]simRide{},
+		surgedQuoteIDs:   map[string]bool{},
+	}
+	if err := s.SetScenario(opts.Scenario); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetScenario replaces the running script.
+func (s *Simulator) SetScenario(scenario string) error {
+	if scenario == "" {
+		scenario = ScenarioRandom
+	}
+	failLeft := 0
+	switch {
+	case scenario == ScenarioRandom, scenario == ScenarioConfirm, scenario == ScenarioSurge:
+	case strings.HasPrefix(scenario, scenarioFail+":"):
+		n, err := strconv.Atoi(strings.TrimPrefix(scenario, scenarioFail+":"))
+		if err != nil || n < 0 {
+			return fmt.Errorf("ride simulator: bad scenario %q", scenario)
+		}
+		failLeft = n
+	default:
+		return fmt.Errorf("ride simulator: unknown scenario %q", scenario)
+	}
+
+	s.mu.Lock()
+	s.scenario, s.failLeft = scenario, failLeft
+	s.mu.Unlock()
+	return nil
+}
+
+// Force queues the outcome of the next Book (or Cancel, for cancel_rejected),
+// ahead of the scenario.
+func (s *Simulator) Force(outcome string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch outcome {
+	case OutcomeConfirm, OutcomeSurge, OutcomeFail:
+		s.forced = append(s.forced, outcome)
+	case OutcomeCancelRejected:
+		s.rejectCancels++
+	default:
+		return fmt.Errorf("ride simulator: unknown outcome %q", outcome)
+	}
+	return nil
+}
+
+func (s *Simulator) Name() string { return s.name }
+
+func (s *Simulator) Quote(_ context.Context, req QuoteRequest) (Quote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := Quote{
+		ID:              "q_" + s.token(12),
+		Provider:        s.name,
+		FareCents:       int(float64(req.RoughFareCents) * s.priceFactor),
+		Currency:        req.Currency,
+		EtaMinutes:      s.rng.Intn(10) + 3,
+		SurgeMultiplier: 1.0,
+		ExpiresAt:       s.clock.Now().Add(s.quoteTTL),
+	}
+	s.quotes[q.ID] = simQuote{Quote: q, tripMinutes: req.Minutes}
+	return q, nil
+}
+
+// token returns n random hex digits from the seeded rng, so quote and
+// booking ids repeat with the seed. It must be called with s.mu held.
+func (s *Simulator) token(n int) string {
+	const digits = "0123456789abcdef"
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = digits[s.rng.Intn(len(digits))]
+	}
+	return string(b)
+}
+
+// nextOutcome must be called with s.mu held.
+func (s *Simulator) nextOutcome(q simQuote, acceptSurge bool) string {
+	if len(s.forced) > 0 {
+		o := s.forced[0]
+		s.forced = s.forced[1:]
+		return o
+	}
+	if acceptSurge && q.SurgeMultiplier > 1 {
+		return OutcomeConfirm
+	}
+	switch s.scenario {
+	case ScenarioConfirm:
+		return OutcomeConfirm
+	case ScenarioSurge:
+		if s.surgedQuoteIDs[q.ID] {
+			return OutcomeConfirm
+		}
+		s.surgedQuoteIDs[q.ID] = true
+		return OutcomeSurge
+	case ScenarioRandom:
+		switch n := s.rng.Intn(10); {
+		case n < 7:
+			return OutcomeConfirm
+		case n < 9:
+			return OutcomeSurge
+		default:
+			return OutcomeFail
+		}
+	default: // fail:N
+		if s.failLeft > 0 {
+			s.failLeft--
+			return OutcomeFail
+		}
+		return OutcomeConfirm
+	}
+}
+
+func (s *Simulator) Book(_ context.Context, req BookRequest) (BookingResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q, ok := s.quotes[req.QuoteID]
+	if !ok {
+		return BookingResult{}, ErrQuoteNotFound
+	}
+	now := s.clock.Now()
+	if now.After(q.ExpiresAt) {
+		return BookingResult{}, ErrQuoteExpired
+	}
+
+	switch s.nextOutcome(q, req.AcceptSurge) {
+	case OutcomeSurge:
+		multiplier := scriptedSurge
+		if s.scenario == ScenarioRandom {
+			multiplier = 1.5 + s.rng.Float64() // 1.5x - 2.5x
+		}
+		sq := Quote{
+			ID:              "q_" + s.token(12),
+			Provider:        s.name,
+			FareCents:       int(float64(q.FareCents) * multiplier),
+			Currency:        q.Currency,
+			EtaMinutes:      s.rng.Intn(10) + 2,
+			SurgeMultiplier: multiplier,
+			ExpiresAt:       now.Add(s.quoteTTL),
+		}
+		s.quotes[sq.ID] = simQuote{Quote: sq, tripMinutes: q.tripMinutes}
+		return BookingResult{
+			Provider: s.name, Status: "surge_too_high",
+			EtaMinutes: sq.EtaMinutes, FareCents: sq.FareCents, SurgeQuote: &sq,
+		}, nil
+	case OutcomeFail:
+		return BookingResult{Provider: s.name, Status: "failed", FareCents: q.FareCents}, nil
+	}
+
+	ref := s.name + "-" + s.token(8)
+	s.rides[ref] = simRide{bookedAt: now, etaMinutes: q.EtaMinutes, tripMinutes: q.tripMinutes}
+	return BookingResult{
+		Provider: s.name, Status: "confirmed",
+		EtaMinutes: q.EtaMinutes, FareCents: q.FareCents, ExternalRef: ref,
+	}, nil
+}
+
+func (s *Simulator) Cancel(_ context.Context, externalRef string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rejectCancels > 0 {
+		s.rejectCancels--
+		return ErrCancelRejected
+	}
+	delete(s.rides, externalRef)
+	return nil
+}
+
+// Status derives the ride's progress from the simulator clock. Refs the
+// simulator doesn't know (e.g. after a restart) start from now.
+func (s *Simulator) Status(_ context.Context, externalRef string) (StatusUpdate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	r, ok := s.rides[externalRef]
+	if !ok {
+		r = simRide{bookedAt: now, etaMinutes: 5, tripMinutes: 15}
+		s.rides[externalRef] = r
+	}
+	return r.progress(externalRef, now.Sub(r.bookedAt)), nil
+}
+
+func (r simRide) progress(ref string, elapsed time.Duration) StatusUpdate {
+	const assignAfter = time.Minute
+	pickup := time.Duration(r.etaMinutes) * time.Minute
+	if pickup < assignAfter {
+		pickup = assignAfter
+	}
+	dropoff := pickup + time.Duration(r.tripMinutes)*time.Minute
+
+	u := StatusUpdate{ExternalRef: ref}
+	switch {
+	case elapsed < assignAfter:
+		u.Status = "confirmed"
+		u.EtaMinutes = r.etaMinutes
+		return u
+	case elapsed < pickup:
+		u.Status = "driver_assigned"
+		u.EtaMinutes = int((pickup - elapsed).Minutes()) + 1
+	case elapsed < dropoff:
+		u.Status = "in_progress"
+		u.EtaMinutes = int((dropoff - elapsed).Minutes()) + 1
+	default:
+		u.Status = "completed"
+		return u
+	}
+
+	// the driver closes in on a fixed point as the ETA runs down
+	remaining := float64(u.EtaMinutes) / float64(r.etaMinutes+r.tripMinutes+1)
+	lat, lng := 13.7563+0.02*remaining, 100.5018+0.02*remaining
+	u.Driver = &Driver{
+		Name: "Somchai", VehiclePlate: "1กข 1234", VehicleModel: "Toyota Corolla Altis",
+		Lat: &lat, Lng: &lng,
+	}
+	return u
+}
+
+// ParseWebhook accepts a JSON StatusUpdate signed with the simulator's
+// webhook secret, failing with ErrBadSignature or ErrStaleWebhook otherwise.
+func (s *Simulator) ParseWebhook(r *http.Request) (StatusUpdate, error) {
+	body, err := verifyWebhook(r, s.webhookSecret, s.webhookTolerance, time.Now())
+	if err != nil {
+		return StatusUpdate{}, err
+	}
+	var u StatusUpdate
+	if err := json.Unmarshal(body, &u); err != nil {
+		return StatusUpdate{}, err
+	}
+	return u, nil
+}
@@ -0,0 +1,62 @@
+package ride
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBadSignature = errors.New("ride webhook signature does not match")
+	ErrStaleWebhook = errors.New("ride webhook timestamp outside the allowed window")
+)
+
+// Headers of a signed provider callback. The signature is the hex HMAC-SHA256
+// of "<timestamp>.<body>" with the provider's webhook secret; a provider
+// rotating its secret may send several, comma separated.
+const (
+	HeaderWebhookSignature = "X-Ride-Signature"
+	HeaderWebhookTimestamp = "X-Ride-Timestamp"
+)
+
+// SignWebhook returns the signature of body sent at ts.
+func SignWebhook(secret []byte, ts time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%d.", ts.Unix())
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyWebhook reads r's body and checks its signature and age. Callbacks
+// are refused when no secret is configured.
+func verifyWebhook(r *http.Request, secret []byte, tolerance time.Duration, now time.Time) ([]byte, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("no webhook secret configured: %w", ErrBadSignature)
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	unix, err := strconv.ParseInt(r.Header.Get(HeaderWebhookTimestamp), 10, 64)
+	if err != nil {
+		return nil, ErrStaleWebhook
+	}
+	ts := time.Unix(unix, 0)
+	if d := now.Sub(ts); d > tolerance || d < -tolerance {
+		return nil, ErrStaleWebhook
+	}
+	want := SignWebhook(secret, ts, body)
+	for _, sig := range strings.Split(r.Header.Get(HeaderWebhookSignature), ",") {
+		if hmac.Equal([]byte(strings.TrimSpace(sig)), []byte(want)) {
+			return body, nil
+		}
+	}
+	return nil, ErrBadSignature
+}
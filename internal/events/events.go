@@ -0,0 +1,92 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Event types streamed to clients.
+const (
+	TypeBookingUpdated    = "booking_updated"
+	TypeDispatchFailed    = "booking_dispatch_failed"
+	TypePaymentUpdated    = "payment_updated"
+	TypeSplitUpdated      = "split_updated"
+	TypeTripPlanned       = "trip_planned"
+	TypeTravelTimeChanged = "travel_time_changed"
+	TypeHeartbeatDue      = "heartbeat_due"
+)
+
+// Event is a change a user's connected clients should hear about.
+type Event struct {
+	Type   string         `json:"type"`
+	UserID uint           `json:"user_id"`
+	Data   map[string]any `json:"data"`
+	At     time.Time      `json:"at"`
+}
+
+// Publisher is what handlers and jobs depend on; they never see connections.
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// Bus fans events out to per-user subscribers.
+type Bus interface {
+	Publisher
+	// Subscribe returns a channel of the user's events and a func to unsubscribe.
+	Subscribe(userID uint) (<-chan Event, func())
+}
+
+const subscriberBuffer = 32
+
+// MemoryBus delivers events inside this process only.
+type MemoryBus struct {
+	mu   sync.RWMutex
+	subs map[uint]map[chan Event]struct{}
+}
+
+func NewMemoryBus() *MemoryBus { return &MemoryBus{subs: map[uint]map[chan Event]struct{}{}} }
+
+func (b *MemoryBus) Publish(_ context.Context, e Event) error {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	b.deliver(e)
+	return nil
+}
+
+// deliver never blocks: a subscriber that falls behind misses events rather
+// than stalling the publisher.
+func (b *MemoryBus) deliver(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subs[e.UserID] {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func (b *MemoryBus) Subscribe(userID uint) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	if b.subs[userID] == nil {
+		b.subs[userID] = map[chan Event]struct{}{}
+	}
+	b.subs[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs[userID], ch)
+			if len(b.subs[userID]) == 0 {
+				delete(b.subs, userID)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
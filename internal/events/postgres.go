@@ -0,0 +1,105 @@
+package events
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Channel is the Postgres NOTIFY channel shared by all API instances.
+const Channel = "navmate_events"
+
+// NOTIFY payloads must stay under 8000 bytes.
+const maxPayload = 7900
+
+// PostgresBus publishes with pg_notify and delivers what its LISTEN connection
+// receives, so every API instance sees events published by any other.
+// Events published while the listener is reconnecting are lost.
+type PostgresBus struct {
+	db    *gorm.DB
+	local *MemoryBus
+}
+
+func NewPostgresBus(db *gorm.DB) *PostgresBus {
+	return &PostgresBus{db: db, local: NewMemoryBus()}
+}
+
+func (b *PostgresBus) Publish(ctx context.Context, e Event) error {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxPayload {
+		return fmt.Errorf("events: %s payload too large (%d bytes)", e.Type, len(payload))
+	}
+	return b.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", Channel, string(payload)).Error
+}
+
+func (b *PostgresBus) Subscribe(userID uint) (<-chan Event, func()) {
+	return b.local.Subscribe(userID)
+}
+
+// Listen holds a dedicated connection on Channel, reconnecting with backoff,
+// until ctx is cancelled.
+func (b *PostgresBus) Listen(ctx context.Context, log *zap.SugaredLogger) {
+	backoff := time.Second
+	for {
+		err := b.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warnw("event bus: listen", "err", err, "retry_in", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (b *PostgresBus) listen(ctx context.Context) error {
+	sqlDB, err := b.db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(dc any) error {
+		pc, ok := dc.(*stdlib.Conn)
+		if !ok {
+			return errors.New("events: postgres bus needs the pgx driver")
+		}
+		if _, err := pc.Conn().Exec(ctx, "LISTEN "+Channel); err != nil {
+			return fmt.Errorf("%w: %v", driver.ErrBadConn, err)
+		}
+		for {
+			n, err := pc.Conn().WaitForNotification(ctx)
+			if err != nil {
+				// never hand a LISTENing connection back to the pool
+				return fmt.Errorf("%w: %v", driver.ErrBadConn, err)
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(n.Payload), &e); err != nil {
+				continue
+			}
+			b.local.deliver(e)
+		}
+	})
+}
@@ -0,0 +1,191 @@
+package booking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"navmate-backend/config"
+	paymentadapter "navmate-backend/internal/adapters/payment"
+	"navmate-backend/internal/adapters/ride"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/money"
+	"navmate-backend/internal/repository"
+)
+
+// cancellationFee applies the fee policy: free within the window after booking
+// (unless a driver is already assigned), a flat fee afterwards and a higher
+// one once a driver is on the way. Fees are configured in CURRENCY and
+// converted to the booking's currency at feeRate. The fee never exceeds the fare.
+func cancellationFee(b models.RideBooking, now time.Time, cfg config.Config, feeRate float64) int {
+	// advance bookings are only booked with the provider at dispatch time
+	bookedAt := b.CreatedAt
+	if b.PickupAt != nil {
+		if dispatchAt := b.PickupAt.Add(-cfg.Booking.DispatchLead); dispatchAt.After(bookedAt) {
+			bookedAt = dispatchAt
+		}
+	}
+
+	var fee int
+	switch {
+	case b.Status == models.BookingDriverAssigned:
+		fee = cfg.Booking.CancelFeeDriverAssignedCents
+	case b.Status == models.BookingScheduled || b.Status == models.BookingPending || b.Status == models.BookingNeedsConfirmation:
+		fee = 0 // nothing was confirmed with the provider yet
+	case now.Sub(bookedAt) <= cfg.Booking.CancelFreeWindow:
+		fee = 0
+	default:
+		fee = cfg.Booking.CancelFeeCents
+	}
+	fee = money.Convert(money.New(fee, cfg.Money.Currency), b.Currency, feeRate).Cents
+	if fee > b.FareCents {
+		fee = b.FareCents
+	}
+	return fee
+}
+
+// feeRate is the exchange rate from the currency fees are configured in to currency.
+func (h *Handler) feeRate(ctx context.Context, currency string) (float64, error) {
+	if currency == "" || currency == h.cfg.Money.Currency {
+		return 1, nil
+	}
+	return h.rates.Rate(ctx, h.cfg.Money.Currency, currency)
+}
+
+type cancelReq struct {
+	Reason string `json:"reason"`
+}
+
+// DELETE /v1/bookings/:id
+func (h *Handler) Cancel(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	var req cancelReq
+	// body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	b, ok := h.loadBooking(c, uid)
+	if !ok {
+		return
+	}
+	// riders cancel in_progress trips with the driver, not through the app
+	if b.Status == models.BookingInProgress || !models.CanTransition(b.Status, models.BookingCancelled) {
+		c.JSON(http.StatusConflict, gin.H{"error": "booking cannot be cancelled", "status": b.Status})
+		return
+	}
+	// checked before cancelling with the provider, so a missing rate cancels nothing
+	feeRate, err := h.feeRate(c.Request.Context(), b.Currency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no exchange rate for the booking currency"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if b.ExternalRef != "" {
+		provider, found := h.rides.Get(b.Provider)
+		if !found {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "unknown provider"})
+			return
+		}
+		if err := provider.Cancel(ctx, b.ExternalRef); err != nil {
+			if errors.Is(err, ride.ErrCancelRejected) {
+				c.JSON(http.StatusConflict, gin.H{"error": "provider rejected cancellation"})
+				return
+			}
+			c.JSON(http.StatusBadGateway, gin.H{"error": "provider cancellation failed"})
+			return
+		}
+	}
+
+	// claim the cancellation before any money moves; the poller or a webhook
+	// may have written the booking meanwhile, so a stale row is re-read
+	var fee int
+	for attempt := 0; ; attempt++ {
+		now := time.Now()
+		fee = cancellationFee(b, now, h.cfg, feeRate)
+		b.CancelledAt = &now
+		b.CancelledBy = "user"
+		b.CancelReason = req.Reason
+		b.CancellationFeeCents = fee
+		err := h.bookings.Transition(ctx, &b, models.BookingCancelled, "user", req.Reason)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, repository.ErrStaleBooking) || attempt == 2 {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "update booking failed"})
+			return
+		}
+		if err := h.db.WithContext(ctx).First(&b, b.ID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "update booking failed"})
+			return
+		}
+		// a provider's report of this cancellation is taken over; a trip that started is not
+		if b.Status == models.BookingInProgress ||
+			(b.Status != models.BookingCancelled && !models.CanTransition(b.Status, models.BookingCancelled)) {
+			c.JSON(http.StatusConflict, gin.H{"error": "booking cannot be cancelled", "status": b.Status})
+			return
+		}
+	}
+
+	var pay *models.Payment
+	var payErr error
+	if b.PaymentID != nil {
+		var p models.Payment
+		if err := h.db.First(&p, *b.PaymentID).Error; err == nil {
+			// the booking is cancelled either way; a gateway failure is reported
+			payErr = h.settleCancelledPayment(ctx, &p, fee)
+			pay = &p
+		}
+	}
+
+	resp := gin.H{"booking_id": b.ID, "status": b.Status, "cancellation_fee_cents": fee, "currency": b.Currency}
+	if payErr != nil {
+		_ = h.bookings.AddNote(c.Request.Context(), &b, "system", "payment settlement failed: "+payErr.Error())
+		resp["payment_error"] = "payment gateway failed; the payment was not settled"
+	} else if pay != nil {
+		resp["payment"] = gin.H{
+			"payment_id": pay.ID, "status": pay.Status,
+			"captured_cents": pay.CapturedCents, "refunded_cents": pay.RefundedCents,
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// settleCancelledPayment keeps only the cancellation fee: an open authorization
+// is voided (or captured for the fee), a captured payment is refunded down to
+// the fee. It runs under the payment's lock like an admin capture or refund,
+// and only once per payment: the money moved is written to the ledger with
+// the key "cancel-<payment id>", which is also the gateway's idempotency key.
+func (h *Handler) settleCancelledPayment(ctx context.Context, p *models.Payment, fee int) error {
+	key := fmt.Sprintf("cancel-%d", p.ID)
+	meta := repository.LedgerMeta{Actor: "system", Note: "booking cancelled", IdempotencyKey: key}
+	err := h.paymentRepo.Settle(ctx, p, meta, func(cur models.Payment, bal models.PaymentBalance) (paymentadapter.Charge, error) {
+		switch cur.Status {
+		case paymentadapter.StatusAuthorized:
+			if fee = min(fee, bal.Capturable()); fee == 0 {
+				return h.payments.Void(ctx, cur.ExternalRef)
+			}
+			return h.payments.Capture(ctx, cur.ExternalRef, fee)
+		case paymentadapter.StatusCaptured, paymentadapter.StatusPartiallyRefunded:
+			if refund := bal.Refundable() - fee; refund > 0 {
+				return h.payments.Refund(ctx, cur.ExternalRef, refund, key)
+			}
+		}
+		return paymentadapter.Charge{}, errNothingToSettle
+	})
+	if errors.Is(err, errNothingToSettle) || errors.Is(err, repository.ErrAlreadyApplied) {
+		return nil
+	}
+	return err
+}
+
+// errNothingToSettle leaves a cancelled booking's payment as it is.
+var errNothingToSettle = errors.New("nothing to settle")
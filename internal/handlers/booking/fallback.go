@@ -0,0 +1,135 @@
+package booking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"navmate-backend/internal/adapters/ride"
+	"navmate-backend/internal/models"
+)
+
+// attempt is the provider/quote a booking was (last) sent to.
+type attempt struct {
+	provider ride.RideProvider
+	quote    models.RideQuote
+	result   ride.BookingResult
+	n        int
+}
+
+func isQuoteErr(err error) bool {
+	return errors.Is(err, ride.ErrQuoteNotFound) || errors.Is(err, ride.ErrQuoteExpired)
+}
+
+// book sends the booking to the provider and, if the provider fails it, applies
+// the user's ride_fallback policy until BOOKING_MAX_ATTEMPTS is used up. Each
+// failed attempt is recorded in the booking history. An error means the first
+// attempt could not be made at all (e.g. the quote expired); exhausting the
+// attempts is not an error and yields a failed result. All attempts, and the
+// waits between them, end by BOOKING_ATTEMPTS_TIMEOUT or ctx's deadline.
+func (h *Handler) book(ctx context.Context, uid uint, p models.TripPlan, it models.Itinerary, leg *models.Leg,
+	b *models.RideBooking, provider ride.RideProvider, quote models.RideQuote) (attempt, error) {
+	pref, err := h.prefs.Get(ctx, uid)
+	if err != nil {
+		return attempt{}, err
+	}
+	// the booking history is still written once the attempts ran out of time
+	notes := ctx
+	if t := h.cfg.Booking.AttemptsTimeout; t > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t)
+		defer cancel()
+	}
+
+	cur := attempt{provider: provider, quote: quote}
+	tried := map[string]bool{}
+	backoff := h.cfg.Booking.RetryBackoff
+	for cur.n = 1; ; cur.n++ {
+		br, err := cur.provider.Book(ctx, ride.BookRequest{QuoteID: cur.quote.QuoteID, From: leg.FromName, To: leg.ToName})
+		if err != nil && cur.n == 1 && isQuoteErr(err) {
+			return cur, err
+		}
+		if err == nil && br.Status != models.BookingFailed {
+			cur.result = br
+			return cur, nil
+		}
+
+		outcome := "failed"
+		if err != nil {
+			outcome = err.Error()
+		}
+		_ = h.bookings.AddNote(notes, b, "system", fmt.Sprintf("attempt %d via %s: %s", cur.n, cur.provider.Name(), outcome))
+		tried[cur.provider.Name()] = true
+		cur.result = ride.BookingResult{Provider: cur.provider.Name(), Status: models.BookingFailed, FareCents: cur.quote.FareCents}
+		if cur.n >= h.cfg.Booking.MaxAttempts {
+			return cur, nil
+		}
+
+		switch pref.RideFallback {
+		case models.RideFallbackRetry:
+			if isQuoteErr(err) {
+				return cur, nil // the same quote won't book on a retry either
+			}
+			if !wait(ctx, backoff) {
+				return cur, nil
+			}
+			backoff *= 2
+		case models.RideFallbackNextProvider:
+			next, ok := h.nextProvider(ctx, uid, p, it, leg, tried)
+			if !ok {
+				return cur, nil
+			}
+			next.n = cur.n
+			cur = next
+		default:
+			return cur, nil
+		}
+	}
+}
+
+// wait sleeps for d, or returns false if ctx is done first or its deadline
+// comes before d is up.
+func wait(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return false
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// nextProvider quotes every provider not tried yet and returns the cheapest.
+func (h *Handler) nextProvider(ctx context.Context, uid uint, p models.TripPlan, it models.Itinerary, leg *models.Leg,
+	tried map[string]bool) (attempt, bool) {
+	for _, r := range h.rides.CompareQuotes(ctx, quoteRequest(it, leg), h.quoteTimeout) {
+		if r.Quote == nil || tried[r.Provider] {
+			continue
+		}
+		provider, found := h.rides.Get(r.Provider)
+		if !found {
+			continue
+		}
+		rq, err := h.saveQuote(ctx, uid, p, it, &leg.ID, *r.Quote)
+		if err != nil {
+			continue
+		}
+		return attempt{provider: provider, quote: rq}, true
+	}
+	return attempt{}, false
+}
+
+// proposeAlternative returns the plan's best itinerary without a ride, or nil.
+// It is only proposed; the user selects it through the trips API.
+func (h *Handler) proposeAlternative(ctx context.Context, planID uint) *models.Itinerary {
+	alt, err := h.trips.BestNonRideItinerary(ctx, planID, time.Now())
+	if err != nil {
+		return nil
+	}
+	return alt
+}
@@ -1,23 +1,325 @@
 package booking
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
+	"navmate-backend/config"
+	"navmate-backend/internal/adapters/fx"
+	paymentadapter "navmate-backend/internal/adapters/payment"
 	"navmate-backend/internal/adapters/ride"
+	"navmate-backend/internal/events"
 	"navmate-backend/internal/models"
+	"navmate-backend/internal/money"
+	"navmate-backend/internal/repository"
+	"navmate-backend/internal/utils"
 )
 
-type Handler struct{ db *gorm.DB }
+type Handler struct {
+	db           *gorm.DB
+	rides        *ride.Registry
+	payments     paymentadapter.PaymentGateway
+	rates        fx.RateSource
+	paymentRepo  *repository.PaymentRepository
+	trips        *repository.TripRepository
+	bookings     *repository.BookingRepository
+	prefs        *repository.PreferenceRepository
+	promos       *repository.PromotionRepository
+	events       events.Publisher
+	cfg          config.Config
+	quoteTimeout time.Duration
+}
+
+func New(db *gorm.DB, rides *ride.Registry, payments paymentadapter.PaymentGateway, rates fx.RateSource, pub events.Publisher, cfg *config.Config) *Handler {
+	return &Handler{
+		db:           db,
+		rides:        rides,
+		payments:     payments,
+		rates:        rates,
+		paymentRepo:  repository.NewPaymentRepository(db, pub),
+		trips:        repository.NewTripRepository(db),
+		bookings:     repository.NewBookingRepository(db, pub),
+		prefs:        repository.NewPreferenceRepository(db),
+		promos:       repository.NewPromotionRepository(db, rates),
+		events:       pub,
+		cfg:          *cfg,
+		quoteTimeout: cfg.Ride.QuoteTimeout,
+	}
+}
+
+// loadLegs loads the user's plan, its selected itinerary and the itinerary's
+// bookable legs. On failure it writes the error response and returns ok=false;
+// no legs with ok=true means the itinerary has nothing to book.
+func (h *Handler) loadLegs(c *gin.Context, uid, planID uint) (p models.TripPlan, it models.Itinerary, legs []*models.Leg, ok bool) {
+	// load plan (owner only) + selected itinerary
+	if err := h.db.Where("id = ? AND user_id = ?", planID, uid).First(&p).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "plan not found"})
+		return p, it, nil, false
+	}
+	if p.SelectedItineraryID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "plan not selected"})
+		return p, it, nil, false
+	}
+	if err := h.db.Where("id = ?", *p.SelectedItineraryID).First(&it).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "itinerary missing"})
+		return p, it, nil, false
+	}
+	if it.Expired(time.Now()) {
+		c.JSON(http.StatusGone, gin.H{
+			"error": "itinerary expired", "code": "itinerary_expired",
+			"message": "fares and ETAs are out of date; please re-plan the trip",
+		})
+		return p, it, nil, false
+	}
+
+	// ถ้ามี leg ที่จองได้ให้จองทุก leg, ถ้าไม่มีถือว่า transit-only (ไม่ต้อง book)
+	return p, it, h.bookableLegs(it.ID), true
+}
+
+// bookableLegs returns the itinerary's legs that have a provider to book
+// them with, in travel order. Routing only assigns providers to RIDE legs.
+func (h *Handler) bookableLegs(itineraryID uint) []*models.Leg {
+	var legs []models.Leg
+	_ = h.db.Where("itinerary_id = ?", itineraryID).Order("index ASC").Find(&legs).Error
+	var bookable []*models.Leg
+	for i := range legs {
+		if legs[i].Provider != nil {
+			bookable = append(bookable, &legs[i])
+		}
+	}
+	return bookable
+}
+
+// quoteRequest prices the leg at its own fare; legs planned before per-leg
+// fares existed fall back to the itinerary's cost.
+func quoteRequest(it models.Itinerary, leg *models.Leg) ride.QuoteRequest {
+	fare := leg.FareCents
+	if fare == 0 {
+		fare = it.RoughCostCents
+	}
+	return ride.QuoteRequest{
+		From: leg.FromName, To: leg.ToName, DistanceM: leg.DistanceM, Minutes: leg.Minutes,
+		RoughFareCents: fare, Currency: it.Currency,
+	}
+}
+
+type quotesReq struct {
+	PlanID    uint   `json:"plan_id" binding:"required"`
+	LegID     uint   `json:"leg_id"`     // optional: defaults to the first bookable leg
+	Provider  string `json:"provider"`   // optional: quote a single provider instead of all
+	PromoCode string `json:"promo_code"` // optional: show each quote's discount
+}
+
+// POST /v1/bookings/quotes
+// Asks the configured providers for a quote on one bookable leg of the plan.
+// Quotes are stored so that POST /v1/bookings can book one by quote_id until
+// it expires.
+func (h *Handler) CompareQuotes(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	var req quotesReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	p, it, legs, ok := h.loadLegs(c, uid, req.PlanID)
+	if !ok {
+		return
+	}
+	if len(legs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no ride legs to quote"})
+		return
+	}
+	leg := legs[0]
+	if req.LegID != 0 {
+		if leg = findLeg(legs, req.LegID); leg == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "leg is not a bookable leg of the selected itinerary"})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	var results []ride.QuoteResult
+	if req.Provider != "" {
+		provider, found := h.rides.Get(req.Provider)
+		if !found {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown provider"})
+			return
+		}
+		qctx, cancel := context.WithTimeout(ctx, h.quoteTimeout)
+		q, err := provider.Quote(qctx, quoteRequest(it, leg))
+		cancel()
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "provider quote failed"})
+			return
+		}
+		results = []ride.QuoteResult{{Provider: provider.Name(), Quote: &q}}
+	} else {
+		results = h.rides.CompareQuotes(ctx, quoteRequest(it, leg), h.quoteTimeout)
+	}
+
+	var promo *models.Promotion
+	promoErr := ""
+	if req.PromoCode != "" {
+		pr, err := h.lookupPromo(ctx, uid, req.PromoCode, it)
+		var pe *repository.PromoError
+		switch {
+		case errors.As(err, &pe):
+			promoErr = pe.Code
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "load promo code failed"})
+			return
+		default:
+			promo = &pr
+		}
+	}
+
+	for i, r := range results {
+		if r.Quote == nil {
+			continue
+		}
+		if promo != nil && promo.AllowsProvider(r.Provider) {
+			// a rate missing for the promotion's currency just shows no discount
+			results[i].DiscountCents, _ = h.promos.Discount(ctx, *promo, money.New(r.Quote.FareCents, r.Quote.Currency))
+		}
+		rq, err := h.saveQuote(ctx, uid, p, it, &leg.ID, *r.Quote)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "save quote failed"})
+			return
+		}
+		if rq.DisplayCurrency != "" {
+			results[i].Display = &money.Money{Cents: rq.DisplayFareCents, Currency: rq.DisplayCurrency}
+		}
+	}
+	resp := gin.H{"plan_id": p.ID, "itinerary_id": it.ID, "leg_id": leg.ID, "quotes": results}
+	if promoErr != "" {
+		resp["promo_error"] = promoErr
+	}
+	c.JSON(http.StatusOK, resp)
+}
 
-func New(db *gorm.DB) *Handler { return &Handler{db: db} }
+func findLeg(legs []*models.Leg, id uint) *models.Leg {
+	for _, l := range legs {
+		if l.ID == id {
+			return l
+		}
+	}
+	return nil
+}
+
+// saveQuote stores a provider quote. When the plan is shown in another
+// currency the fare is converted now, at the current rate, and the rate is
+// kept for the booking made from it.
+func (h *Handler) saveQuote(ctx context.Context, uid uint, p models.TripPlan, it models.Itinerary, legID *uint, q ride.Quote) (models.RideQuote, error) {
+	rq := models.RideQuote{
+		QuoteID: q.ID, UserID: uid, PlanID: p.ID, ItineraryID: it.ID, LegID: legID, Provider: q.Provider,
+		FareCents: q.FareCents, Currency: q.Currency, EtaMinutes: q.EtaMinutes, SurgeMultiplier: q.SurgeMultiplier, ExpiresAt: q.ExpiresAt,
+	}
+	if rq.Currency == "" {
+		rq.Currency = it.Currency
+	}
+	if it.DisplayCurrency != "" && it.DisplayCurrency != rq.Currency {
+		// without a rate the fare is just shown in its own currency
+		if m, rate, err := fx.Convert(ctx, h.rates, money.New(rq.FareCents, rq.Currency), it.DisplayCurrency); err == nil {
+			rq.DisplayCurrency, rq.DisplayFareCents, rq.FxRate = m.Currency, m.Cents, rate
+		}
+	}
+	return rq, h.db.WithContext(ctx).Create(&rq).Error
+}
+
+var errUnknownProvider = errors.New("unknown provider")
+
+// quoteLeg asks the leg's own provider for a fresh quote and stores it.
+func (h *Handler) quoteLeg(ctx context.Context, uid uint, p models.TripPlan, it models.Itinerary, leg *models.Leg) (models.RideQuote, error) {
+	provider, found := h.rides.Get(*leg.Provider)
+	if !found {
+		return models.RideQuote{}, errUnknownProvider
+	}
+	q, err := provider.Quote(ctx, quoteRequest(it, leg))
+	if err != nil {
+		return models.RideQuote{}, err
+	}
+	return h.saveQuote(ctx, uid, p, it, &leg.ID, q)
+}
 
 type createReq struct {
-	PlanID uint `json:"plan_id" binding:"required"`
+	PlanID   uint       `json:"plan_id" binding:"required"`
+	QuoteID  string     `json:"quote_id"`  // optional: from POST /v1/bookings/quotes, for the leg it was quoted for; other legs are quoted on the fly
+	PickupAt *time.Time `json:"pickup_at"` // optional: far enough ahead, the rides are booked in advance and dispatched later
+	// optional: a promo code for the first leg it applies to, and whether to pay what's left with credit
+	PromoCode  string `json:"promo_code"`
+	UseCredits bool   `json:"use_credits"`
+}
+
+func bookingResp(b models.RideBooking) gin.H {
+	resp := gin.H{
+		"booking_id": b.ID, "status": b.Status, "eta_minutes": b.EtaMinutes, "fare_cents": b.FareCents, "currency": b.Currency,
+		"provider": b.Provider, "quote_id": b.QuoteID, "leg_id": b.LegID,
+	}
+	if m, ok := b.DisplayFare(); ok {
+		resp["display"] = m
+	}
+	if b.Status == models.BookingNeedsConfirmation {
+		surge := gin.H{"multiplier": b.SurgeMultiplier, "fare_cents": b.SurgeFareCents}
+		if _, ok := b.DisplayFare(); ok {
+			surge["display"] = money.Convert(money.New(b.SurgeFareCents, b.Currency), b.DisplayCurrency, b.FxRate)
+		}
+		resp["surge"] = surge
+	}
+	if b.PickupAt != nil {
+		resp["pickup_at"] = b.PickupAt
+	}
+	if b.PromotionID != nil {
+		resp["promo_code"], resp["discount_cents"] = b.PromoCode, b.DiscountCents
+	}
+	if b.CreditCents > 0 {
+		resp["credit_cents"] = b.CreditCents
+	}
+	if b.PaymentID != nil {
+		resp["payment_id"], resp["payment_status"] = *b.PaymentID, b.PaymentStatus
+	}
+	return resp
+}
+
+// loadBooking fetches a booking owned by uid, writing a 404 when it is not.
+func (h *Handler) loadBooking(c *gin.Context, uid uint) (models.RideBooking, bool) {
+	var b models.RideBooking
+	err := h.db.Joins("JOIN trip_plans ON trip_plans.id = ride_bookings.plan_id").
+		Where("ride_bookings.id = ? AND trip_plans.user_id = ?", c.Param("id"), uid).
+		First(&b).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return b, false
+	}
+	return b, true
+}
+
+// findExisting returns a booking that already claimed this quote or idempotency key.
+func (h *Handler) findExisting(planID uint, quoteID, idemKey string) (*models.RideBooking, bool) {
+	var b models.RideBooking
+	if idemKey != "" {
+		if err := h.db.Where("plan_id = ? AND idempotency_key = ?", planID, idemKey).First(&b).Error; err == nil {
+			return &b, true
+		}
+	}
+	if quoteID != "" {
+		if err := h.db.Where("plan_id = ? AND quote_id = ?", planID, quoteID).First(&b).Error; err == nil {
+			return &b, true
+		}
+	}
+	return nil, false
 }
 
+// POST /v1/bookings
+// Books every bookable leg of the selected itinerary, all-or-nothing. Retries
+// with the same Idempotency-Key header (or the same quote_id) return the
+// original bookings instead of booking a second time.
 func (h *Handler) Create(c *gin.Context) {
 	uid := uint(c.GetInt("user_id"))
 	var req createReq
@@ -25,68 +327,247 @@ func (h *Handler) Create(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	// load plan (owner only) + selected itinerary
-	var p models.TripPlan
-	if err := h.db.Where("id = ? AND user_id = ?", req.PlanID, uid).First(&p).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "plan not found"})
+	idemKey := c.GetHeader("Idempotency-Key")
+	if len(idemKey) > 255 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key too long"})
 		return
 	}
-	if p.SelectedItineraryID == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "plan not selected"})
+
+	if existing, found := h.findExisting(req.PlanID, req.QuoteID, idemKey); found {
+		// ownership is checked through the plan before replaying
+		var cnt int64
+		h.db.Model(&models.TripPlan{}).Where("id = ? AND user_id = ?", existing.PlanID, uid).Count(&cnt)
+		if cnt > 0 {
+			c.Header("Idempotent-Replayed", "true")
+			c.JSON(http.StatusOK, groupResp(h.loadGroup(*existing), nil))
+			return
+		}
+	}
+
+	p, it, legs, ok := h.loadLegs(c, uid, req.PlanID)
+	if !ok {
 		return
 	}
-	var it models.Itinerary
-	if err := h.db.Where("id = ?", *p.SelectedItineraryID).First(&it).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "itinerary missing"})
+	if len(legs) == 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "no ride legs; nothing to book", "plan_id": p.ID, "itinerary_id": it.ID})
 		return
 	}
 
-	// ถ้ามี RIDE leg ให้จอง, ถ้าไม่มีถือว่า transit-only (ไม่ต้อง book)
-	var rideProvider *string
-	var rideFound bool
-	var fare = it.RoughCostCents
-	var legs []models.Leg
-	_ = h.db.Where("itinerary_id = ?", it.ID).Order("index ASC").Find(&legs).Error
-	for _, l := range legs {
-		if l.Mode == "RIDE" && l.Provider != nil {
-			rideProvider = l.Provider
-			rideFound = true
-			break
+	ctx := c.Request.Context()
+	var promo *models.Promotion
+	if req.PromoCode != "" {
+		pr, err := h.lookupPromo(ctx, uid, req.PromoCode, it)
+		if err != nil {
+			promoFailed(c, err)
+			return
 		}
+		promo = &pr
 	}
-	if !rideFound {
-		c.JSON(http.StatusOK, gin.H{"message": "no ride legs; nothing to book", "plan_id": p.ID, "itinerary_id": it.ID})
+	if req.PickupAt != nil && req.PickupAt.After(time.Now().Add(h.cfg.Booking.DispatchLead)) {
+		h.createScheduled(c, p, it, legs, req, promo, idemKey)
 		return
 	}
 
-	br := ride.Book(*rideProvider, fare)
+	var agreed models.RideQuote
+	agreedLeg := legs[0] // quotes from before per-leg quoting are for the first leg
+	if req.QuoteID != "" {
+		if err := h.db.Where("quote_id = ? AND user_id = ? AND plan_id = ?", req.QuoteID, uid, p.ID).First(&agreed).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid quote_id"})
+			return
+		}
+		if agreed.LegID != nil {
+			if agreedLeg = findLeg(legs, *agreed.LegID); agreedLeg == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "quote is not for a leg of the selected itinerary"})
+				return
+			}
+		}
+		if time.Now().After(agreed.ExpiresAt) {
+			c.JSON(http.StatusConflict, gin.H{"error": "quote expired", "code": "quote_expired"})
+			return
+		}
+	}
 
-	b := models.RideBooking{
-		PlanID: p.ID, ItineraryID: it.ID, Provider: br.Provider,
-		Status: br.Status, EtaMinutes: br.EtaMinutes, FareCents: br.FareCents,
+	groupRef := utils.RandomToken(16)
+	lbs := make([]*legBooking, 0, len(legs))
+	for _, leg := range legs {
+		lb := &legBooking{leg: leg}
+		if req.QuoteID != "" && leg == agreedLeg {
+			lb.quote = agreed
+		} else {
+			q, err := h.quoteLeg(ctx, uid, p, it, leg)
+			if errors.Is(err, errUnknownProvider) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown provider"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusBadGateway, gin.H{"error": "provider quote failed"})
+				return
+			}
+			lb.quote = q
+		}
+		if _, found := h.rides.Get(lb.quote.Provider); !found {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown provider"})
+			return
+		}
+		lb.b = models.RideBooking{
+			PlanID: p.ID, ItineraryID: it.ID, LegID: &leg.ID, GroupRef: groupRef, Provider: lb.quote.Provider,
+			Status: models.BookingPending, FareCents: lb.quote.FareCents, QuoteID: &lb.quote.QuoteID, PickupAt: req.PickupAt,
+			Currency: lb.quote.Currency, DisplayCurrency: lb.quote.DisplayCurrency, FxRate: lb.quote.FxRate,
+		}
+		if idemKey != "" {
+			lb.b.IdempotencyKey = &idemKey
+		}
+		lbs = append(lbs, lb)
 	}
-	if err := h.db.Create(&b).Error; err != nil {
+
+	// Claim every leg's quote/idempotency key first so concurrent retries cannot book twice.
+	if err := h.bookings.CreateGroup(ctx, claims(lbs), "user"); err != nil {
+		if existing, found := h.findExisting(p.ID, req.QuoteID, idemKey); found {
+			c.Header("Idempotent-Replayed", "true")
+			c.JSON(http.StatusOK, groupResp(h.loadGroup(*existing), nil))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "create booking failed"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"booking_id": b.ID, "status": b.Status, "eta_minutes": b.EtaMinutes, "fare_cents": b.FareCents,
-	})
+	fares := make([]int, len(lbs))
+	for i, lb := range lbs {
+		fares[i] = lb.b.FareCents
+	}
+	if err := h.applyDiscounts(ctx, uid, claims(lbs), fares, promo, req.UseCredits); err != nil {
+		for _, b := range claims(lbs) {
+			_ = h.bookings.Delete(ctx, b)
+		}
+		promoFailed(c, err)
+		return
+	}
+
+	failed := h.bookLegs(ctx, uid, p, it, lbs)
+	if first := lbs[0]; failed == 0 && first.err != nil && first.b.Status == models.BookingPending {
+		// nothing reached a provider: release the claims so the client can retry with a fresh quote
+		for _, b := range claims(lbs) {
+			_ = h.bookings.Delete(ctx, b)
+		}
+		var fc *fareChangedError
+		switch {
+		case isQuoteErr(first.err):
+			c.JSON(http.StatusConflict, gin.H{"error": "quote expired", "code": "quote_expired"})
+		case errors.As(first.err, &fc):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "fare changed", "code": "fare_changed",
+				"quoted_fare_cents": fc.quoted, "fare_cents": fc.fare,
+			})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": "provider booking failed"})
+		}
+		return
+	}
+	if failed >= 0 {
+		h.compensate(ctx, lbs, failed)
+	}
+
+	bookings := make([]models.RideBooking, len(lbs))
+	attempts := make([]int, len(lbs))
+	for i, lb := range lbs {
+		bookings[i], attempts[i] = lb.b, lb.attempts
+	}
+	resp := groupResp(bookings, attempts)
+	if failed >= 0 {
+		if len(lbs) > 1 {
+			resp["failed_leg_id"] = lbs[failed].leg.ID
+		}
+		resp["fallback_itinerary"] = h.proposeAlternative(ctx, p.ID)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// settle applies the provider's answer to a pending booking, handling surges,
+// and moves it to the resulting status.
+func (h *Handler) settle(ctx context.Context, uid uint, p models.TripPlan, it models.Itinerary,
+	b *models.RideBooking, res attempt) error {
+	br := res.result
+	b.Provider = res.provider.Name()
+	b.EtaMinutes = br.EtaMinutes
+	b.FareCents = br.FareCents
+	b.ExternalRef = br.ExternalRef
+	status := br.Status
+	if br.Status == "surge_too_high" {
+		var err error
+		if status, err = h.handleSurge(ctx, uid, p, it, res.provider, b, br); err != nil {
+			return err
+		}
+	}
+	note := ""
+	if res.n > 1 {
+		note = fmt.Sprintf("attempt %d via %s", res.n, b.Provider)
+	}
+	return h.bookings.Transition(ctx, b, status, "provider", note)
 }
 
 func (h *Handler) Get(c *gin.Context) {
 	uid := uint(c.GetInt("user_id"))
-	id := c.Param("id")
-	var b models.RideBooking
 
 	// Verify ownership and fetch booking in a single query
-	err := h.db.Joins("JOIN trip_plans ON trip_plans.id = ride_bookings.plan_id").
-		Where("ride_bookings.id = ? AND trip_plans.user_id = ?", id, uid).
-		First(&b).Error
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	b, ok := h.loadBooking(c, uid)
+	if !ok {
 		return
 	}
 	c.JSON(http.StatusOK, b)
 }
+
+// GET /v1/bookings/:id/events
+// Status transition history, oldest first.
+func (h *Handler) Events(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	b, ok := h.loadBooking(c, uid)
+	if !ok {
+		return
+	}
+	events, err := h.bookings.Events(c.Request.Context(), b.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load events failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"booking_id": b.ID, "status": b.Status, "items": events})
+}
+
+// POST /v1/rides/webhook/:provider (public endpoint, signed with the provider's webhook secret)
+func (h *Handler) ProviderWebhook(c *gin.Context) {
+	provider, found := h.rides.Get(c.Param("provider"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown provider"})
+		return
+	}
+	u, err := provider.ParseWebhook(c.Request)
+	switch {
+	case errors.Is(err, ride.ErrBadSignature), errors.Is(err, ride.ErrStaleWebhook):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	case err != nil || u.ExternalRef == "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	var b models.RideBooking
+	err = h.db.Where("provider = ? AND external_ref = ?", provider.Name(), u.ExternalRef).First(&b).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+
+	err = h.bookings.ApplyProviderUpdate(c.Request.Context(), &b, u)
+	switch {
+	case errors.Is(err, repository.ErrInvalidTransition), errors.Is(err, repository.ErrStaleBooking):
+		// out-of-order or duplicate delivery; acknowledge so the provider stops retrying
+		c.JSON(http.StatusOK, gin.H{"received": true, "applied": false, "status": b.Status})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"received": true, "applied": true, "status": b.Status})
+}
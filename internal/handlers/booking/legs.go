@@ -0,0 +1,170 @@
+package booking
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"navmate-backend/internal/models"
+)
+
+// legBooking is one bookable leg of an itinerary and its booking record.
+type legBooking struct {
+	leg      *models.Leg
+	quote    models.RideQuote
+	b        models.RideBooking
+	attempts int
+	err      error // why the leg could not be booked, when the provider gave no answer
+}
+
+type fareChangedError struct{ quoted, fare int }
+
+func (e *fareChangedError) Error() string {
+	return fmt.Sprintf("fare changed from %d to %d", e.quoted, e.fare)
+}
+
+func claims(legs []*legBooking) []*models.RideBooking {
+	bs := make([]*models.RideBooking, len(legs))
+	for i, lb := range legs {
+		bs[i] = &lb.b
+	}
+	return bs
+}
+
+// bookLegs books the claimed legs in travel order and stops at the first one
+// that cannot be booked, returning its index (-1 when every leg was booked).
+// The caller decides whether to compensate or release the claims.
+func (h *Handler) bookLegs(ctx context.Context, uid uint, p models.TripPlan, it models.Itinerary, legs []*legBooking) int {
+	for i, lb := range legs {
+		if !h.bookLeg(ctx, uid, p, it, lb) {
+			return i
+		}
+	}
+	return -1
+}
+
+// bookLeg sends one leg to its provider under the fallback and surge rules.
+// A leg waiting for surge confirmation counts as booked.
+func (h *Handler) bookLeg(ctx context.Context, uid uint, p models.TripPlan, it models.Itinerary, lb *legBooking) bool {
+	provider, found := h.rides.Get(lb.quote.Provider)
+	if !found {
+		lb.err = errUnknownProvider
+		return false
+	}
+	res, err := h.book(ctx, uid, p, it, lb.leg, &lb.b, provider, lb.quote)
+	lb.attempts = res.n
+	if err != nil {
+		lb.err = err
+		return false
+	}
+	if br := res.result; br.Status == "confirmed" && br.FareCents != res.quote.FareCents {
+		// the provider moved the price after quoting; don't hold a ride the user didn't agree to
+		_ = res.provider.Cancel(ctx, br.ExternalRef)
+		lb.err = &fareChangedError{quoted: res.quote.FareCents, fare: br.FareCents}
+		return false
+	}
+	if err := h.settle(ctx, uid, p, it, &lb.b, res); err != nil {
+		lb.err = err
+		return false
+	}
+	return lb.b.Status != models.BookingFailed
+}
+
+// compensate undoes a partly booked itinerary after legs[failed] could not be
+// booked: the failed leg is marked failed and every other leg is cancelled,
+// at its provider too if it got that far.
+func (h *Handler) compensate(ctx context.Context, legs []*legBooking, failed int) {
+	lb := legs[failed]
+	if lb.b.Status == models.BookingPending {
+		note := "could not be booked"
+		if lb.err != nil {
+			note = lb.err.Error()
+		}
+		_ = h.bookings.Transition(ctx, &lb.b, models.BookingFailed, "system", note)
+	}
+	reason := fmt.Sprintf("leg %d of the itinerary could not be booked", lb.leg.Index+1)
+	for i, other := range legs {
+		if i != failed {
+			h.cancelGroupLeg(ctx, &other.b, reason)
+		}
+	}
+}
+
+// cancelGroupLeg cancels a booking because another leg of its group failed
+// or was declined. If the provider refuses, the booking is left as it is and
+// the refusal is noted in its history.
+func (h *Handler) cancelGroupLeg(ctx context.Context, b *models.RideBooking, reason string) {
+	if !models.CanTransition(b.Status, models.BookingCancelled) {
+		return
+	}
+	if b.ExternalRef != "" {
+		provider, found := h.rides.Get(b.Provider)
+		if !found {
+			_ = h.bookings.AddNote(ctx, b, "system", "compensating cancel failed: unknown provider")
+			return
+		}
+		if err := provider.Cancel(ctx, b.ExternalRef); err != nil {
+			_ = h.bookings.AddNote(ctx, b, "system", "compensating cancel failed: "+err.Error())
+			return
+		}
+	}
+	now := time.Now()
+	b.CancelledAt = &now
+	b.CancelledBy = "system"
+	b.CancelReason = reason
+	_ = h.bookings.Transition(ctx, b, models.BookingCancelled, "system", "compensation: "+reason)
+}
+
+// loadGroup returns every booking made together with b, in leg order.
+func (h *Handler) loadGroup(b models.RideBooking) []models.RideBooking {
+	if b.GroupRef == "" {
+		return []models.RideBooking{b}
+	}
+	var group []models.RideBooking
+	if err := h.db.Where("group_ref = ?", b.GroupRef).Order("id ASC").Find(&group).Error; err != nil || len(group) == 0 {
+		return []models.RideBooking{b}
+	}
+	return group
+}
+
+// groupStatusOrder ranks statuses from the one that most needs the user's
+// attention; a group reports the first one any of its bookings is in.
+var groupStatusOrder = []string{
+	models.BookingFailed, models.BookingDeclined, models.BookingCancelled, models.BookingNeedsConfirmation,
+	models.BookingPending, models.BookingScheduled, models.BookingConfirmed, models.BookingDriverAssigned,
+	models.BookingInProgress, models.BookingCompleted,
+}
+
+func groupStatus(bs []models.RideBooking) string {
+	for _, s := range groupStatusOrder {
+		for _, b := range bs {
+			if b.Status == s {
+				return s
+			}
+		}
+	}
+	return bs[0].Status
+}
+
+// groupResp keeps the single-booking response for itineraries with one
+// bookable leg; several legs are wrapped with the group's status and total
+// fare. attempts is only known right after booking and may be nil.
+func groupResp(bs []models.RideBooking, attempts []int) gin.H {
+	items := make([]gin.H, len(bs))
+	total := 0
+	for i, b := range bs {
+		items[i] = bookingResp(b)
+		if attempts != nil {
+			items[i]["attempts"] = attempts[i]
+		}
+		total += b.FareCents
+	}
+	if len(bs) == 1 {
+		return items[0]
+	}
+	return gin.H{
+		"group_ref": bs[0].GroupRef, "status": groupStatus(bs), "fare_cents": total, "bookings": items,
+	}
+}
@@ -0,0 +1,83 @@
+package booking
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/money"
+	"navmate-backend/internal/repository"
+)
+
+// lookupPromo checks a promo code against the user and the itinerary, before
+// anything is booked. The provider restriction is checked per leg later.
+func (h *Handler) lookupPromo(ctx context.Context, uid uint, code string, it models.Itinerary) (models.Promotion, error) {
+	promo, err := h.promos.Lookup(ctx, code, uid, time.Now())
+	if err != nil {
+		return promo, err
+	}
+	if !promo.AllowsModeMix(it.ModeMix) {
+		return promo, &repository.PromoError{Code: "promo_mode"}
+	}
+	return promo, nil
+}
+
+// promoFailed answers a promo code that can't be used with 409 and its code.
+func promoFailed(c *gin.Context, err error) {
+	var pe *repository.PromoError
+	if errors.As(err, &pe) {
+		c.JSON(http.StatusConflict, gin.H{"error": pe.Error(), "code": pe.Code})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "apply promo code failed"})
+}
+
+// applyDiscounts redeems promo (nil for none) on the first booking whose
+// provider qualifies, and reserves the user's credit for what is left of each
+// booking's fare. fares are the amounts to discount, per booking: the quoted
+// fare, or the planned one for advance bookings.
+func (h *Handler) applyDiscounts(ctx context.Context, uid uint, bs []*models.RideBooking, fares []int,
+	promo *models.Promotion, useCredits bool) error {
+	target := -1
+	if promo != nil {
+		for i, b := range bs {
+			if promo.AllowsProvider(b.Provider) {
+				target = i
+				break
+			}
+		}
+		if target < 0 {
+			return &repository.PromoError{Code: "promo_provider"}
+		}
+	}
+
+	for i, b := range bs {
+		var p *models.Promotion
+		discount := 0
+		if i == target {
+			var err error
+			if discount, err = h.promos.Discount(ctx, *promo, money.New(fares[i], b.Currency)); err != nil {
+				return err
+			}
+			if discount == 0 && promo.MinFareCents > 0 {
+				return &repository.PromoError{Code: "promo_min_fare"}
+			}
+			p = promo
+		}
+		credit := 0
+		if useCredits {
+			credit = fares[i] - discount
+		}
+		if p == nil && credit <= 0 {
+			continue
+		}
+		if err := h.promos.Apply(ctx, uid, b, p, discount, credit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
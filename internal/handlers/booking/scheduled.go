@@ -0,0 +1,203 @@
+package booking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/repository"
+	"navmate-backend/internal/utils"
+)
+
+// createScheduled stores an advance booking for every bookable leg. Nothing
+// is sent to the providers until the dispatcher picks them up
+// BOOKING_DISPATCH_LEAD before pickup. Discounts are priced on the planned
+// fares and repriced on the final fare at payment.
+func (h *Handler) createScheduled(c *gin.Context, p models.TripPlan, it models.Itinerary, legs []*models.Leg,
+	req createReq, promo *models.Promotion, idemKey string) {
+	pickupAt := *req.PickupAt
+	if req.QuoteID != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quote_id cannot be used with a future pickup_at; the ride is quoted at dispatch"})
+		return
+	}
+
+	groupRef := utils.RandomToken(16)
+	bs := make([]*models.RideBooking, len(legs))
+	for i, leg := range legs {
+		bs[i] = &models.RideBooking{
+			PlanID: p.ID, ItineraryID: it.ID, LegID: &leg.ID, GroupRef: groupRef, Provider: *leg.Provider,
+			Status: models.BookingScheduled, PickupAt: &pickupAt,
+			Currency: it.Currency, DisplayCurrency: it.DisplayCurrency, FxRate: it.FxRate,
+		}
+		if idemKey != "" {
+			bs[i].IdempotencyKey = &idemKey
+		}
+	}
+	ctx := c.Request.Context()
+	if err := h.bookings.CreateGroup(ctx, bs, "user"); err != nil {
+		if existing, found := h.findExisting(p.ID, "", idemKey); found {
+			c.Header("Idempotent-Replayed", "true")
+			c.JSON(http.StatusOK, groupResp(h.loadGroup(*existing), nil))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create booking failed"})
+		return
+	}
+	fares := make([]int, len(legs))
+	for i, leg := range legs {
+		fares[i] = leg.FareCents
+	}
+	if err := h.applyDiscounts(ctx, p.UserID, bs, fares, promo, req.UseCredits); err != nil {
+		for _, b := range bs {
+			_ = h.bookings.Delete(ctx, b)
+		}
+		promoFailed(c, err)
+		return
+	}
+	bookings := make([]models.RideBooking, len(bs))
+	for i, b := range bs {
+		bookings[i] = *b
+	}
+	resp := groupResp(bookings, nil)
+	resp["dispatch_at"] = pickupAt.Add(-h.cfg.Booking.DispatchLead)
+	c.JSON(http.StatusCreated, resp)
+}
+
+type rescheduleReq struct {
+	PickupAt time.Time `json:"pickup_at" binding:"required"`
+}
+
+// PATCH /v1/bookings/:id
+// Moves the pickup of an advance booking that has not been dispatched yet.
+func (h *Handler) Reschedule(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	var req rescheduleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	b, ok := h.loadBooking(c, uid)
+	if !ok {
+		return
+	}
+	if b.Status != models.BookingScheduled {
+		c.JSON(http.StatusConflict, gin.H{"error": "only scheduled bookings can be modified", "status": b.Status})
+		return
+	}
+	if !req.PickupAt.After(time.Now().Add(h.cfg.Booking.DispatchLead)) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("pickup_at must be more than %s ahead; cancel and book now instead", h.cfg.Booking.DispatchLead),
+		})
+		return
+	}
+
+	// the scheduled legs of an itinerary share one pickup and move together
+	var group []models.RideBooking
+	for _, g := range h.loadGroup(b) {
+		if g.Status == models.BookingScheduled {
+			g.PickupAt = &req.PickupAt
+			group = append(group, g)
+		}
+	}
+	bs := make([]*models.RideBooking, len(group))
+	for i := range group {
+		bs[i] = &group[i]
+	}
+	ctx := c.Request.Context()
+	if err := h.bookings.TransitionAll(ctx, bs, models.BookingScheduled, "user", ""); err != nil {
+		if errors.Is(err, repository.ErrInvalidTransition) || errors.Is(err, repository.ErrStaleBooking) {
+			c.JSON(http.StatusConflict, gin.H{"error": "only scheduled bookings can be modified"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update booking failed"})
+		return
+	}
+	for _, g := range bs {
+		_ = h.bookings.AddNote(ctx, g, "user", "pickup moved to "+req.PickupAt.Format(time.RFC3339))
+	}
+
+	resp := groupResp(group, nil)
+	resp["dispatch_at"] = req.PickupAt.Add(-h.cfg.Booking.DispatchLead)
+	c.JSON(http.StatusOK, resp)
+}
+
+// Dispatch sends a due advance booking to its provider together with the
+// other scheduled legs of its group: each leg is quoted now and the legs are
+// booked all-or-nothing under the same fallback and surge rules as an
+// immediate booking. It returns repository.ErrStaleBooking if another worker
+// dispatched it first. A booking that ends up failed, or cancelled because
+// another leg failed, is not an error; check b.Status.
+func (h *Handler) Dispatch(ctx context.Context, b *models.RideBooking) error {
+	var p models.TripPlan
+	if err := h.db.WithContext(ctx).First(&p, b.PlanID).Error; err != nil {
+		return err
+	}
+	var it models.Itinerary
+	if err := h.db.WithContext(ctx).First(&it, b.ItineraryID).Error; err != nil {
+		return err
+	}
+
+	var due []models.RideBooking
+	q := h.db.WithContext(ctx).Where("status = ?", models.BookingScheduled)
+	if b.GroupRef != "" {
+		q = q.Where("group_ref = ?", b.GroupRef)
+	} else {
+		q = q.Where("id = ?", b.ID)
+	}
+	if err := q.Order("id ASC").Find(&due).Error; err != nil {
+		return err
+	}
+	self := -1
+	for i := range due {
+		if due[i].ID == b.ID {
+			self = i
+		}
+	}
+	if self < 0 {
+		return repository.ErrStaleBooking
+	}
+
+	legs := h.bookableLegs(it.ID)
+	lbs := make([]*legBooking, len(due))
+	for i := range due {
+		lbs[i] = &legBooking{b: due[i], leg: &models.Leg{}}
+		if len(legs) > 0 {
+			lbs[i].leg = legs[0] // advance bookings from before per-leg booking
+		}
+		if due[i].LegID != nil {
+			lbs[i].leg = findLeg(legs, *due[i].LegID)
+		}
+	}
+	defer func() { *b = lbs[self].b }()
+
+	// claim them before talking to the providers
+	if err := h.bookings.TransitionAll(ctx, claims(lbs), models.BookingPending, "system", "dispatch"); err != nil {
+		return err
+	}
+
+	for i, lb := range lbs {
+		if lb.leg == nil || lb.leg.Provider == nil {
+			lb.leg = &models.Leg{}
+			lb.err = errors.New("itinerary has no ride leg")
+			h.compensate(ctx, lbs, i)
+			return nil
+		}
+		quote, err := h.quoteLeg(ctx, p.UserID, p, it, lb.leg)
+		if err != nil {
+			lb.err = fmt.Errorf("quote failed: %w", err)
+			h.compensate(ctx, lbs, i)
+			return nil
+		}
+		lb.quote = quote
+		lb.b.QuoteID = &lb.quote.QuoteID
+	}
+	if failed := h.bookLegs(ctx, p.UserID, p, it, lbs); failed >= 0 {
+		h.compensate(ctx, lbs, failed)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package booking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"navmate-backend/internal/adapters/ride"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/repository"
+)
+
+// handleSurge decides what to do with a surge_too_high result: surges within
+// the user's max_surge_multiplier are booked straight away at the surged quote,
+// anything higher parks the booking in needs_confirmation. It returns the
+// status the booking should move to.
+func (h *Handler) handleSurge(ctx context.Context, uid uint, p models.TripPlan, it models.Itinerary,
+	provider ride.RideProvider, b *models.RideBooking, br ride.BookingResult) (string, error) {
+	sq := br.SurgeQuote
+	if sq == nil {
+		return "", errors.New("surge without quote")
+	}
+	if _, err := h.saveQuote(ctx, uid, p, it, b.LegID, *sq); err != nil {
+		return "", err
+	}
+
+	pref, err := h.prefs.Get(ctx, uid)
+	if err != nil {
+		return "", err
+	}
+	if sq.SurgeMultiplier <= pref.MaxSurgeMultiplier {
+		status, resurge, err := h.bookSurge(ctx, provider, b, sq.ID)
+		if err == nil && resurge != nil {
+			// surged again: left to the user, whatever the preference
+			_, err = h.saveQuote(ctx, uid, p, it, b.LegID, *resurge)
+		}
+		return status, err
+	}
+
+	b.SurgeMultiplier = sq.SurgeMultiplier
+	b.SurgeFareCents = sq.FareCents
+	b.SurgeQuoteID = sq.ID
+	return models.BookingNeedsConfirmation, nil
+}
+
+// bookSurge re-books at the surged quote the user (or their preference)
+// accepted and returns the status the booking should move to. When the
+// provider surges again the new surge is put on b, to be confirmed like the
+// first, and its quote is returned.
+func (h *Handler) bookSurge(ctx context.Context, provider ride.RideProvider, b *models.RideBooking, quoteID string) (string, *ride.Quote, error) {
+	br, err := provider.Book(ctx, ride.BookRequest{QuoteID: quoteID, AcceptSurge: true})
+	if err != nil {
+		return "", nil, err
+	}
+	switch br.Status {
+	case "confirmed":
+		b.EtaMinutes = br.EtaMinutes
+		b.FareCents = br.FareCents
+		b.ExternalRef = br.ExternalRef
+		b.SurgeQuoteID = quoteID
+		return models.BookingConfirmed, nil, nil
+	case "surge_too_high":
+		sq := br.SurgeQuote
+		if sq == nil {
+			return "", nil, errors.New("surge without quote")
+		}
+		b.SurgeMultiplier = sq.SurgeMultiplier
+		b.SurgeFareCents = sq.FareCents
+		b.SurgeQuoteID = sq.ID
+		return models.BookingNeedsConfirmation, sq, nil
+	case "failed":
+		return models.BookingFailed, nil, nil
+	}
+	return "", nil, fmt.Errorf("provider %s: unexpected booking status %q", provider.Name(), br.Status)
+}
+
+// POST /v1/bookings/:id/surge/accept
+func (h *Handler) AcceptSurge(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	b, ok := h.loadBooking(c, uid)
+	if !ok {
+		return
+	}
+	if b.Status != models.BookingNeedsConfirmation {
+		c.JSON(http.StatusConflict, gin.H{"error": "booking is not awaiting surge confirmation"})
+		return
+	}
+
+	var sq models.RideQuote
+	if err := h.db.Where("quote_id = ?", b.SurgeQuoteID).First(&sq).Error; err != nil || time.Now().After(sq.ExpiresAt) {
+		c.JSON(http.StatusConflict, gin.H{"error": "quote expired", "code": "quote_expired"})
+		return
+	}
+	provider, found := h.rides.Get(b.Provider)
+	if !found {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown provider"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	status, resurge, err := h.bookSurge(ctx, provider, &b, sq.QuoteID)
+	if err != nil {
+		if errors.Is(err, ride.ErrQuoteExpired) || errors.Is(err, ride.ErrQuoteNotFound) {
+			c.JSON(http.StatusConflict, gin.H{"error": "quote expired", "code": "quote_expired"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "provider booking failed"})
+		return
+	}
+	if resurge != nil {
+		h.resurged(c, uid, &b, *resurge)
+		return
+	}
+	if err := h.bookings.Transition(ctx, &b, status, "user", "surge accepted"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update booking failed"})
+		return
+	}
+	c.JSON(http.StatusOK, bookingResp(b))
+}
+
+// resurged answers an accepted surge the provider surged again: the booking
+// stays in needs_confirmation with the new fare, which the user has to accept
+// in turn.
+func (h *Handler) resurged(c *gin.Context, uid uint, b *models.RideBooking, sq ride.Quote) {
+	ctx := c.Request.Context()
+	var p models.TripPlan
+	var it models.Itinerary
+	if err := h.db.First(&p, b.PlanID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update booking failed"})
+		return
+	}
+	if err := h.db.First(&it, b.ItineraryID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update booking failed"})
+		return
+	}
+	quote, err := h.saveQuote(ctx, uid, p, it, b.LegID, sq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save quote failed"})
+		return
+	}
+	if err := h.bookings.Transition(ctx, b, models.BookingNeedsConfirmation, "provider", ""); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "booking is not awaiting surge confirmation"})
+		return
+	}
+	_ = h.bookings.AddNote(ctx, b, "provider", fmt.Sprintf("surged again to %.2fx", sq.SurgeMultiplier))
+	c.JSON(http.StatusConflict, gin.H{
+		"error": "surge changed", "code": "surge_changed",
+		"message": "the provider raised the fare again; accept or decline the new surge",
+		"booking": bookingResp(*b), "surge_quote": quote,
+	})
+}
+
+// POST /v1/bookings/:id/surge/decline
+// Declines the surge, cancels the other legs booked with it, and switches the
+// plan to its best itinerary without a ride, if any.
+func (h *Handler) DeclineSurge(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	b, ok := h.loadBooking(c, uid)
+	if !ok {
+		return
+	}
+	if b.Status != models.BookingNeedsConfirmation {
+		c.JSON(http.StatusConflict, gin.H{"error": "booking is not awaiting surge confirmation"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := h.bookings.Transition(ctx, &b, models.BookingDeclined, "user", "surge declined"); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "booking is not awaiting surge confirmation"})
+		return
+	}
+	group := h.loadGroup(b)
+	for i := range group {
+		if group[i].ID != b.ID {
+			h.cancelGroupLeg(ctx, &group[i], "surge declined on another leg")
+		}
+	}
+
+	fallback, err := h.trips.BestNonRideItinerary(ctx, b.PlanID, time.Now())
+	if errors.Is(err, repository.ErrItineraryNotFound) {
+		c.JSON(http.StatusOK, gin.H{
+			"booking": bookingResp(b), "fallback_itinerary": nil,
+			"message": "no itinerary without a ride is available; please re-plan",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load fallback failed"})
+		return
+	}
+	if _, err := h.trips.SelectItinerary(ctx, uid, b.PlanID, fallback.ID, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "select fallback failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"booking": bookingResp(b), "fallback_itinerary": fallback})
+}
@@ -0,0 +1,95 @@
+package dev
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"navmate-backend/internal/adapters/ride"
+)
+
+// Handler exposes the ride simulators for demos and integration tests.
+// Routes are only registered when APP_ENV=development.
+type Handler struct {
+	rides *ride.Registry
+	clock *ride.SimClock
+}
+
+func New(rides *ride.Registry, clock *ride.SimClock) *Handler {
+	return &Handler{rides: rides, clock: clock}
+}
+
+func (h *Handler) simulator(c *gin.Context) (*ride.Simulator, bool) {
+	p, found := h.rides.Get(c.Param("provider"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown provider"})
+		return nil, false
+	}
+	sim, ok := p.(*ride.Simulator)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provider is not simulated"})
+		return nil, false
+	}
+	return sim, true
+}
+
+type outcomeReq struct {
+	Outcome string `json:"outcome" binding:"required"` // confirm|surge|fail|cancel_rejected
+}
+
+// POST /v1/dev/rides/:provider/next
+func (h *Handler) ForceOutcome(c *gin.Context) {
+	sim, ok := h.simulator(c)
+	if !ok {
+		return
+	}
+	var req outcomeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := sim.Force(req.Outcome); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"provider": sim.Name(), "queued": req.Outcome})
+}
+
+type scenarioReq struct {
+	Scenario string `json:"scenario" binding:"required"` // random|confirm|surge|fail:N
+}
+
+// PUT /v1/dev/rides/:provider/scenario
+func (h *Handler) SetScenario(c *gin.Context) {
+	sim, ok := h.simulator(c)
+	if !ok {
+		return
+	}
+	var req scenarioReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := sim.SetScenario(req.Scenario); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"provider": sim.Name(), "scenario": req.Scenario})
+}
+
+type advanceReq struct {
+	Minutes int `json:"minutes" binding:"required,min=1"`
+}
+
+// POST /v1/dev/rides/clock/advance
+// Moves simulated time forward for every simulated provider.
+func (h *Handler) AdvanceClock(c *gin.Context) {
+	var req advanceReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.clock.Advance(time.Duration(req.Minutes) * time.Minute)
+	c.JSON(http.StatusOK, gin.H{"now": h.clock.Now()})
+}
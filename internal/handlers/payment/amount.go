@@ -0,0 +1,93 @@
+package payment
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+
+	"navmate-backend/internal/adapters/fx"
+	paymentadapter "navmate-backend/internal/adapters/payment"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/money"
+)
+
+// breakdown is what the server charges for a booking. Clients never choose
+// the amount; they may only add a tip.
+type breakdown struct {
+	FareCents     int
+	FeeCents      int
+	TipCents      int
+	DiscountCents int    // the booking's promotion, on the final fare
+	CreditCents   int    // the user's credit reserved at booking
+	Currency      string // the booking's; every amount is in it
+}
+
+func (bd breakdown) total() int {
+	return bd.FareCents + bd.FeeCents + bd.TipCents - bd.DiscountCents - bd.CreditCents
+}
+
+func (bd breakdown) json() gin.H {
+	return gin.H{
+		"fare_cents": bd.FareCents, "fee_cents": bd.FeeCents, "tip_cents": bd.TipCents,
+		"discount_cents": bd.DiscountCents, "credit_cents": bd.CreditCents,
+		"amount_cents": bd.total(), "currency": bd.Currency,
+	}
+}
+
+// apply records the breakdown the payment is based on.
+func (bd breakdown) apply(p *models.Payment) {
+	p.AmountCents = bd.total()
+	p.Currency = bd.Currency
+	p.FareCents, p.FeeCents, p.TipCents = bd.FareCents, bd.FeeCents, bd.TipCents
+	p.DiscountCents, p.CreditCents = bd.DiscountCents, bd.CreditCents
+}
+
+// chargeable prices booking b in its own currency: its agreed fare (the surge
+// fare once accepted) plus the service fee and the rider's tip, less its
+// promotion and credit. The fee is configured in CURRENCY and converted at
+// the current rate. The promotion is repriced on the final fare and credit
+// covers at most the fare and fee; the booking keeps what was reserved.
+func (h *Handler) chargeable(ctx context.Context, b models.RideBooking, tipCents int) (breakdown, error) {
+	bd := breakdown{FareCents: b.FareCents, TipCents: tipCents, Currency: b.Currency}
+	if bd.Currency == "" {
+		bd.Currency = h.currency
+	}
+	fee, _, err := fx.Convert(ctx, h.rates, money.New(h.bookingFeeCents, h.currency), bd.Currency)
+	if err != nil {
+		return bd, err
+	}
+	bd.FeeCents = fee.Cents
+
+	if b.PromotionID != nil {
+		promo, err := h.promos.Get(ctx, *b.PromotionID)
+		if err != nil {
+			return bd, err
+		}
+		// a fallback provider the promotion doesn't cover gets no discount
+		if promo.AllowsProvider(b.Provider) {
+			if bd.DiscountCents, err = h.promos.Discount(ctx, promo, money.New(b.FareCents, bd.Currency)); err != nil {
+				return bd, err
+			}
+		}
+	}
+	bd.CreditCents = max(0, min(b.CreditCents, bd.FareCents+bd.FeeCents-bd.DiscountCents))
+	return bd, nil
+}
+
+// payableStatuses are the booking statuses whose fare is settled: the ride is
+// booked. A scheduled booking has no fare until it is dispatched.
+var payableStatuses = map[string]bool{
+	models.BookingConfirmed: true, models.BookingDriverAssigned: true,
+	models.BookingInProgress: true, models.BookingCompleted: true,
+}
+
+// livePayment reports whether p still holds or took money, so the booking
+// must not be authorized again. A declined, voided or expired payment can be
+// replaced, e.g. with another card.
+func livePayment(p models.Payment) bool {
+	switch p.Status {
+	case paymentadapter.StatusDeclined, paymentadapter.StatusVoided, paymentadapter.StatusExpired:
+		return false
+	}
+	return true
+}
@@ -1,25 +1,80 @@
 package payment
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
-	"navmate-backend/internal/adapters/payment"
+	"navmate-backend/config"
+	"navmate-backend/internal/adapters/fx"
+	"navmate-backend/internal/adapters/mail"
+	paymentadapter "navmate-backend/internal/adapters/payment"
+	"navmate-backend/internal/events"
 	"navmate-backend/internal/models"
+	"navmate-backend/internal/receipts"
+	"navmate-backend/internal/repository"
 )
 
-type Handler struct{ db *gorm.DB }
+type Handler struct {
+	db              *gorm.DB
+	gateway         paymentadapter.PaymentGateway
+	payments        *repository.PaymentRepository
+	methods         *repository.PaymentMethodRepository
+	promos          *repository.PromotionRepository
+	receipts        *receipts.Issuer
+	splits          *repository.SplitRepository
+	rates           fx.RateSource
+	currency        string // of bookingFeeCents
+	bookingFeeCents int
+	splitTimeout    time.Duration
+	splitMax        int // participants per split
+}
+
+func New(db *gorm.DB, gateway paymentadapter.PaymentGateway, rates fx.RateSource, sender mail.Sender, pub events.Publisher, cfg *config.Config) *Handler {
+	return &Handler{
+		db:              db,
+		gateway:         gateway,
+		rates:           rates,
+		currency:        cfg.Money.Currency,
+		payments:        repository.NewPaymentRepository(db, pub),
+		methods:         repository.NewPaymentMethodRepository(db),
+		promos:          repository.NewPromotionRepository(db, rates),
+		receipts:        receipts.NewIssuer(db, sender, cfg),
+		splits:          repository.NewSplitRepository(db, pub),
+		bookingFeeCents: cfg.Payment.BookingFeeCents,
+		splitTimeout:    cfg.Payment.SplitTimeout,
+		splitMax:        cfg.Payment.SplitMaxParticipants,
+	}
+}
 
-func New(db *gorm.DB) *Handler { return &Handler{db: db} }
+// gatewayFailed answers a failed gateway call: a rejected operation is a
+// conflict with the payment's state, anything else is the gateway's fault.
+func gatewayFailed(c *gin.Context, err error) {
+	var ge *paymentadapter.GatewayError
+	if errors.As(err, &ge) && ge.StatusCode < http.StatusInternalServerError {
+		c.JSON(http.StatusConflict, gin.H{"error": "payment gateway rejected the request", "code": ge.Code, "message": ge.Message})
+		return
+	}
+	c.JSON(http.StatusBadGateway, gin.H{"error": "payment gateway failed"})
+}
 
 type authorizeReq struct {
-	BookingID   uint `json:"booking_id" binding:"required"`
-	AmountCents int  `json:"amount_cents" binding:"required"`
+	BookingID uint `json:"booking_id" binding:"required"`
+	// optional: the amount the client showed the user; rejected if the server prices it differently
+	AmountCents *int `json:"amount_cents"`
+	TipCents    int  `json:"tip_cents" binding:"gte=0"`
+	// how to pay: a one-time card token, or a saved method (default: the user's default method)
+	Source          string `json:"source"`
+	PaymentMethodID *uint  `json:"payment_method_id"`
 }
 
 // POST /v1/payments/authorize
+// The amount is derived from the booking, see chargeable.
 func (h *Handler) Authorize(c *gin.Context) {
 	uid := uint(c.GetInt("user_id"))
 	var req authorizeReq
@@ -27,6 +82,8 @@ func (h *Handler) Authorize(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	ctx := c.Request.Context()
+	idemKey := c.GetHeader("Idempotency-Key")
 
 	// Verify booking ownership
 	var b models.RideBooking
@@ -42,29 +99,140 @@ func (h *Handler) Authorize(c *gin.Context) {
 		return
 	}
 
-	// Call payment adapter
-	idStr, status := payment.Authorize(req.AmountCents)
+	// A split booking is paid share by share, see CreateSplit
+	if s, err := h.splits.Active(ctx, b.ID); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "booking fare is split", "split_id": s.ID, "status": s.Status})
+		return
+	}
+
+	// One live payment per booking; a retry with the same Idempotency-Key gets it back
+	var prev *models.Payment
+	if b.PaymentID != nil {
+		var existing models.Payment
+		if err := h.db.First(&existing, *b.PaymentID).Error; err == nil {
+			if idemKey != "" && existing.IdempotencyKey == idemKey {
+				// finishes a request that authorized but failed to save the discounts
+				if livePayment(existing) {
+					if err := h.promos.Settle(ctx, &b, existing.DiscountCents, existing.CreditCents); err != nil {
+						c.JSON(http.StatusInternalServerError, gin.H{"error": "save discounts failed"})
+						return
+					}
+				}
+				authorizeResp(c, existing, "")
+				return
+			}
+			if livePayment(existing) {
+				c.JSON(http.StatusConflict, gin.H{"error": "booking already has a payment", "payment_id": existing.ID, "status": existing.Status})
+				return
+			}
+			prev = &existing
+		}
+	}
+
+	if !payableStatuses[b.Status] {
+		c.JSON(http.StatusConflict, gin.H{"error": "booking is not payable", "status": b.Status})
+		return
+	}
+	bd, err := h.chargeable(ctx, b, req.TipCents)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "price booking failed"})
+		return
+	}
+	if req.AmountCents != nil && *req.AmountCents != bd.total() {
+		c.JSON(http.StatusConflict, gin.H{"error": "amount mismatch", "code": "amount_mismatch", "expected": bd.json()})
+		return
+	}
+	if bd.total() <= 0 && bd.DiscountCents+bd.CreditCents == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "nothing to charge", "expected": bd.json()})
+		return
+	}
+	if bd.total() <= 0 {
+		// covered by the promotion and credit: there is nothing for the gateway to do
+		if err := h.promos.Settle(ctx, &b, bd.DiscountCents, bd.CreditCents); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "save discounts failed"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"covered": true, "breakdown": bd.json()})
+		return
+	}
+
+	ar := paymentadapter.AuthRequest{Currency: bd.Currency, Description: fmt.Sprintf("NavMate booking %d", b.ID)}
+	methodID, ok := h.paymentSource(c, uid, req, &ar)
+	if !ok {
+		return
+	}
 
 	pay := models.Payment{
-		AmountCents: req.AmountCents,
-		Currency:    "THB",
-		Status:      status,
-		ExternalRef: idStr,
+		Status:          paymentadapter.StatusPending,
+		DisplayCurrency: b.DisplayCurrency,
+		FxRate:          b.FxRate,
+		Gateway:         h.gateway.Name(),
+		IdempotencyKey:  idemKey,
+		PaymentMethodID: methodID,
 	}
-	if err := h.db.Create(&pay).Error; err != nil {
+	bd.apply(&pay)
+	if err := h.payments.Claim(ctx, &pay, &b); err != nil {
+		if errors.Is(err, repository.ErrPaymentExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "booking already has a payment"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "create payment failed"})
 		return
 	}
 
-	// Link payment to booking
-	b.PaymentID = &pay.ID
-	_ = h.db.Save(&b).Error
+	// Call payment gateway; our payment id keeps a retried call on the same charge
+	ar.AmountCents = pay.AmountCents
+	ar.IdempotencyKey = fmt.Sprintf("payment-%d", pay.ID)
+	ch, err := h.gateway.Authorize(ctx, ar)
+	if err != nil {
+		_ = h.payments.Release(ctx, &pay, &b, prev)
+		gatewayFailed(c, err)
+		return
+	}
+
+	pay.ExternalRef = ch.ExternalRef
+	if err := h.payments.ApplyCharge(ctx, &pay, ch, repository.LedgerMeta{Actor: "user"}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create payment failed"})
+		return
+	}
+	// the promotion and credit are used once a payment stands behind them; a
+	// declined card leaves them with the booking for the next attempt
+	if pay.Status != paymentadapter.StatusDeclined {
+		if err := h.promos.Settle(ctx, &b, bd.DiscountCents, bd.CreditCents); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "save discounts failed"})
+			return
+		}
+	}
+	authorizeResp(c, pay, ch.AuthorizeURI)
+}
 
-	if status == "declined" {
+// authorizeResp answers with the payment's outcome and the breakdown it was based on.
+func authorizeResp(c *gin.Context, pay models.Payment, authorizeURI string) {
+	bd := breakdown{
+		FareCents: pay.FareCents, FeeCents: pay.FeeCents, TipCents: pay.TipCents,
+		DiscountCents: pay.DiscountCents, CreditCents: pay.CreditCents, Currency: pay.Currency,
+	}
+	breakdown := bd.json()
+	if m, ok := pay.DisplayAmount(); ok {
+		breakdown["display"] = m // the total in the user's preferred currency, at the booking's quoted rate
+	}
+	switch pay.Status {
+	case paymentadapter.StatusDeclined:
 		c.JSON(http.StatusPaymentRequired, gin.H{
-			"error":      "payment declined",
-			"payment_id": pay.ID,
-			"status":     pay.Status,
+			"error":        "payment declined",
+			"payment_id":   pay.ID,
+			"status":       pay.Status,
+			"failure_code": pay.FailureCode,
+			"breakdown":    breakdown,
+		})
+		return
+	case paymentadapter.StatusPending:
+		// e.g. 3-D Secure: the client sends the user to authorize_uri
+		c.JSON(http.StatusAccepted, gin.H{
+			"payment_id":    pay.ID,
+			"status":        pay.Status,
+			"authorize_uri": authorizeURI,
+			"breakdown":     breakdown,
 		})
 		return
 	}
@@ -73,101 +241,256 @@ func (h *Handler) Authorize(c *gin.Context) {
 		"payment_id":   pay.ID,
 		"status":       pay.Status,
 		"external_ref": pay.ExternalRef,
+		"breakdown":    breakdown,
 	})
 }
 
-// POST /v1/payments/:id/capture
-func (h *Handler) Capture(c *gin.Context) {
-	uid := uint(c.GetInt("user_id"))
-	id := c.Param("id")
-
+// loadPayment fetches a payment whose booking (or split share) belongs to
+// uid, writing the error response when it does not.
+func (h *Handler) loadPayment(c *gin.Context, uid uint) (models.Payment, bool) {
 	var p models.Payment
-	if err := h.db.First(&p, id).Error; err != nil {
+	if err := h.db.First(&p, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-		return
+		return p, false
 	}
 
-	// Verify ownership via booking
+	// Verify ownership via booking, or via the share of a split fare it pays
 	var b models.RideBooking
 	if err := h.db.Where("payment_id = ?", p.ID).First(&b).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
-		return
+		var share models.SplitShare
+		if err := h.db.Where("payment_id = ?", p.ID).First(&share).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+			return p, false
+		}
+		if share.UserID != uid {
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return p, false
+		}
+		return p, true
 	}
 
 	var plan models.TripPlan
 	if err := h.db.First(&plan, b.PlanID).Error; err != nil || plan.UserID != uid {
 		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
-		return
+		return p, false
 	}
+	return p, true
+}
 
-	if p.Status != "authorized" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "payment not authorized"})
+// GET /v1/payments/:id
+// Refreshes the payment from the gateway before returning it.
+func (h *Handler) Get(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	p, ok := h.loadPayment(c, uid)
+	if !ok {
 		return
 	}
 
-	p.Status = "captured"
-	if err := h.db.Save(&p).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "capture failed"})
+	ch, err := h.gateway.Status(c.Request.Context(), p.ExternalRef)
+	if err != nil {
+		gatewayFailed(c, err)
 		return
 	}
+	if ch.Status != p.Status || ch.CapturedCents != p.CapturedCents || ch.RefundedCents != p.RefundedCents {
+		if err := h.payments.ApplyCharge(c.Request.Context(), &p, ch, repository.LedgerMeta{Actor: "gateway", Note: "status refresh"}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "update payment failed"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, p)
+}
 
+// GET /v1/payments/:id/transactions
+// The payment's ledger and the balances derived from it.
+func (h *Handler) Transactions(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	p, ok := h.loadPayment(c, uid)
+	if !ok {
+		return
+	}
+	ledger, err := h.payments.Transactions(c.Request.Context(), p.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load transactions failed"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"payment_id": p.ID,
-		"status":     p.Status,
+		"payment_id": p.ID, "status": p.Status, "balance": balanceResp(models.Balance(ledger)), "transactions": ledger,
 	})
 }
 
-// POST /v1/payments/:id/refund
-func (h *Handler) Refund(c *gin.Context) {
-	uid := uint(c.GetInt("user_id"))
-	id := c.Param("id")
+func balanceResp(bal models.PaymentBalance) gin.H {
+	return gin.H{
+		"authorized_cents": bal.AuthorizedCents, "captured_cents": bal.CapturedCents, "refunded_cents": bal.RefundedCents,
+		"capturable_cents": bal.Capturable(), "refundable_cents": bal.Refundable(),
+	}
+}
 
+var (
+	errNotAuthorized = errors.New("payment not authorized")
+	errNotCaptured   = errors.New("payment not captured")
+	errBadAmount     = errors.New("amount_cents must be between 1 and the available amount")
+)
+
+// adminPayment fetches the payment of the :id parameter, writing a 404 when
+// there is none.
+func (h *Handler) adminPayment(c *gin.Context) (models.Payment, bool) {
 	var p models.Payment
-	if err := h.db.First(&p, id).Error; err != nil {
+	if err := h.db.First(&p, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-		return
+		return p, false
 	}
+	return p, true
+}
 
-	// Verify ownership via booking
+// bindOptional reads a JSON body into req; an empty body leaves it zero.
+func bindOptional(c *gin.Context, req any) bool {
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+	}
+	return true
+}
+
+// captureAmount is what the payment is for: the chargeable total of its
+// booking with the tip it was authorized with, or the share it pays.
+func (h *Handler) captureAmount(ctx context.Context, p models.Payment) (int, error) {
 	var b models.RideBooking
-	if err := h.db.Where("payment_id = ?", p.ID).First(&b).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
-		return
+	err := h.db.WithContext(ctx).Where("payment_id = ?", p.ID).First(&b).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return p.AmountCents, nil
+	}
+	if err != nil {
+		return 0, err
 	}
+	bd, err := h.chargeable(ctx, b, p.TipCents)
+	return bd.total(), err
+}
 
-	var plan models.TripPlan
-	if err := h.db.First(&plan, b.PlanID).Error; err != nil || plan.UserID != uid {
-		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+type captureReq struct {
+	Reason string `json:"reason"`
+}
+
+// POST /v1/admin/payments/:id/capture
+// Captures the booking's chargeable total, at most the authorized amount; the
+// rest of the hold is released.
+func (h *Handler) Capture(c *gin.Context) {
+	p, ok := h.adminPayment(c)
+	if !ok {
 		return
 	}
-
-	if p.Status != "captured" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "payment not captured"})
+	var req captureReq
+	if !bindOptional(c, &req) {
+		return
+	}
+	ctx := c.Request.Context()
+	amount, err := h.captureAmount(ctx, p)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "price booking failed"})
 		return
 	}
 
-	p.Status = "refunded"
-	if err := h.db.Save(&p).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "refund failed"})
+	var gwErr error
+	meta := repository.LedgerMeta{Actor: c.GetString("email"), Note: req.Reason}
+	err = h.payments.Settle(ctx, &p, meta, func(cur models.Payment, bal models.PaymentBalance) (paymentadapter.Charge, error) {
+		if cur.Status != paymentadapter.StatusAuthorized {
+			return paymentadapter.Charge{}, errNotAuthorized
+		}
+		if amount = min(amount, bal.Capturable()); amount <= 0 {
+			return paymentadapter.Charge{}, errBadAmount
+		}
+		var ch paymentadapter.Charge
+		ch, gwErr = h.gateway.Capture(ctx, cur.ExternalRef, amount)
+		return ch, gwErr
+	})
+	switch {
+	case errors.Is(err, errNotAuthorized), errors.Is(err, errBadAmount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case gwErr != nil:
+		gatewayFailed(c, gwErr)
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "capture failed"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"payment_id": p.ID,
-		"status":     p.Status,
+		"payment_id":     p.ID,
+		"status":         p.Status,
+		"captured_cents": p.CapturedCents,
 	})
 }
 
-// POST /v1/payments/webhook (public endpoint)
-func (h *Handler) Webhook(c *gin.Context) {
-	// TODO: Verify webhook signature
-	var payload map[string]interface{}
-	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+type refundReq struct {
+	AmountCents *int   `json:"amount_cents"` // omitted = everything that is left
+	Reason      string `json:"reason"`
+}
+
+// POST /v1/admin/payments/:id/refund
+// Refunds all or part of what is left; can be called again until nothing is.
+// Refunds of a payment run one at a time, and a retry with the same
+// Idempotency-Key refunds once, here and at the gateway.
+func (h *Handler) Refund(c *gin.Context) {
+	p, ok := h.adminPayment(c)
+	if !ok {
+		return
+	}
+	var req refundReq
+	if !bindOptional(c, &req) {
+		return
+	}
+	ctx := c.Request.Context()
+	idemKey := c.GetHeader("Idempotency-Key")
+
+	var (
+		gwErr     error
+		available int
+	)
+	meta := repository.LedgerMeta{Actor: c.GetString("email"), Note: req.Reason, IdempotencyKey: idemKey}
+	err := h.payments.Settle(ctx, &p, meta, func(cur models.Payment, bal models.PaymentBalance) (paymentadapter.Charge, error) {
+		if cur.Status != paymentadapter.StatusCaptured && cur.Status != paymentadapter.StatusPartiallyRefunded {
+			return paymentadapter.Charge{}, errNotCaptured
+		}
+		available = bal.Refundable()
+		amount := available
+		if req.AmountCents != nil {
+			amount = *req.AmountCents
+		}
+		if amount <= 0 || amount > available {
+			return paymentadapter.Charge{}, errBadAmount
+		}
+		// without a client key the ledger state names the refund, so a
+		// retry after a lost answer is not refunded twice either
+		key := fmt.Sprintf("refund-%d-%d", cur.ID, bal.RefundedCents)
+		if idemKey != "" {
+			key = fmt.Sprintf("refund-%d-%s", cur.ID, idemKey)
+		}
+		var ch paymentadapter.Charge
+		ch, gwErr = h.gateway.Refund(ctx, cur.ExternalRef, amount, key)
+		return ch, gwErr
+	})
+	switch {
+	case errors.Is(err, repository.ErrAlreadyApplied):
+		// a retry: answer with the payment as it is now
+	case errors.Is(err, errNotCaptured):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errBadAmount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "available_cents": available})
+		return
+	case gwErr != nil:
+		gatewayFailed(c, gwErr)
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "refund failed"})
 		return
 	}
 
-	// TODO: Process webhook events (payment status updates)
-	// For now, just acknowledge receipt
-	c.JSON(http.StatusOK, gin.H{"received": true})
+	c.JSON(http.StatusOK, gin.H{
+		"payment_id":     p.ID,
+		"status":         p.Status,
+		"refunded_cents": p.RefundedCents,
+	})
 }
@@ -0,0 +1,204 @@
+package payment
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	paymentadapter "navmate-backend/internal/adapters/payment"
+	"navmate-backend/internal/models"
+)
+
+// walletProviders are the e-wallets the gateway can charge, by source type.
+var walletProviders = map[string]bool{"truemoney": true, "rabbit_linepay": true, "shopeepay": true}
+
+// GET /v1/me/payment-methods
+func (h *Handler) ListMethods(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	list, err := h.methods.List(c.Request.Context(), uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load payment methods failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"payment_methods": list})
+}
+
+type createMethodReq struct {
+	Type     string `json:"type" binding:"required"` // card|promptpay|wallet
+	Token    string `json:"token"`                   // card: one-time token from the gateway's client SDK
+	Provider string `json:"provider"`                // wallet: e.g. truemoney
+	Label    string `json:"label" binding:"max=100"`
+	Default  bool   `json:"default"`
+}
+
+// POST /v1/me/payment-methods
+// A card token is exchanged for a saved card at the gateway; the card
+// number never reaches us.
+func (h *Handler) CreateMethod(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	var req createMethodReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	m := models.PaymentMethod{
+		UserID: uid, Type: req.Type, Gateway: h.gateway.Name(), Label: req.Label, IsDefault: req.Default,
+	}
+	switch req.Type {
+	case models.PaymentMethodCard:
+		if req.Token == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token is required for cards"})
+			return
+		}
+		card, err := h.gateway.SaveCard(c.Request.Context(), req.Token, "NavMate user "+strconv.Itoa(int(uid)))
+		if err != nil {
+			gatewayFailed(c, err)
+			return
+		}
+		m.GatewayRef, m.Brand, m.Last4, m.ExpMonth, m.ExpYear = card.Ref, card.Brand, card.Last4, card.ExpMonth, card.ExpYear
+	case models.PaymentMethodPromptPay:
+		// nothing to save: every payment gets its own QR code
+	case models.PaymentMethodWallet:
+		if !walletProviders[req.Provider] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "provider must be truemoney, rabbit_linepay or shopeepay"})
+			return
+		}
+		m.Provider = req.Provider
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be card, promptpay or wallet"})
+		return
+	}
+
+	if err := h.methods.Create(c.Request.Context(), &m); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save payment method failed"})
+		return
+	}
+	c.JSON(http.StatusCreated, m)
+}
+
+// loadMethod fetches one of the user's payment methods, writing the error
+// response when it is not found.
+func (h *Handler) loadMethod(c *gin.Context, uid uint) (models.PaymentMethod, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return models.PaymentMethod{}, false
+	}
+	m, err := h.methods.Get(c.Request.Context(), uid, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "payment method not found"})
+		return m, false
+	}
+	return m, true
+}
+
+// Pointers so that omitted fields keep their current value.
+type updateMethodReq struct {
+	Label   *string `json:"label" binding:"omitempty,max=100"`
+	Default *bool   `json:"default"`
+}
+
+// PATCH /v1/me/payment-methods/:id
+func (h *Handler) UpdateMethod(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	var req updateMethodReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	m, ok := h.loadMethod(c, uid)
+	if !ok {
+		return
+	}
+	if req.Default != nil && !*req.Default && m.IsDefault {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "make another method the default instead"})
+		return
+	}
+	if req.Label != nil {
+		m.Label = *req.Label
+	}
+	if req.Default != nil && *req.Default {
+		m.IsDefault = true
+	}
+	if err := h.methods.Save(c.Request.Context(), &m); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save payment method failed"})
+		return
+	}
+	c.JSON(http.StatusOK, m)
+}
+
+// DELETE /v1/me/payment-methods/:id
+// Saved cards are removed from the gateway too.
+func (h *Handler) DeleteMethod(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	m, ok := h.loadMethod(c, uid)
+	if !ok {
+		return
+	}
+	if m.GatewayRef != "" && m.Gateway == h.gateway.Name() {
+		if err := h.gateway.RemoveCard(c.Request.Context(), m.GatewayRef); err != nil && !errors.Is(err, paymentadapter.ErrNotFound) {
+			gatewayFailed(c, err)
+			return
+		}
+	}
+	if err := h.methods.Delete(c.Request.Context(), &m); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete payment method failed"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// paymentSource fills in how the payment is made: a one-time token as sent,
+// otherwise the chosen saved method or the user's default one. ok=false means
+// the error response was written.
+func (h *Handler) paymentSource(c *gin.Context, uid uint, req authorizeReq, ar *paymentadapter.AuthRequest) (methodID *uint, ok bool) {
+	if req.Source != "" {
+		if req.PaymentMethodID != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "send either source or payment_method_id"})
+			return nil, false
+		}
+		ar.Source = req.Source
+		return nil, true
+	}
+
+	var m models.PaymentMethod
+	if req.PaymentMethodID != nil {
+		var err error
+		if m, err = h.methods.Get(c.Request.Context(), uid, *req.PaymentMethodID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "payment method not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "load payment method failed"})
+			}
+			return nil, false
+		}
+	} else {
+		def, found, err := h.methods.Default(c.Request.Context(), uid)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "load payment method failed"})
+			return nil, false
+		}
+		if !found {
+			return nil, true // the gateway decides what an empty source means
+		}
+		m = def
+	}
+
+	if m.Gateway != h.gateway.Name() {
+		c.JSON(http.StatusConflict, gin.H{"error": "payment method was saved with another payment gateway", "payment_method_id": m.ID})
+		return nil, false
+	}
+	switch m.Type {
+	case models.PaymentMethodCard:
+		ar.Customer = m.GatewayRef
+	case models.PaymentMethodPromptPay:
+		ar.SourceType = "promptpay"
+	case models.PaymentMethodWallet:
+		ar.SourceType = m.Provider
+	}
+	return &m.ID, true
+}
@@ -0,0 +1,111 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"navmate-backend/internal/adapters/mail"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/receipts"
+)
+
+// issueReceipt returns the payment's receipt, issuing it on first request,
+// writing the error response when there is none.
+func (h *Handler) issueReceipt(c *gin.Context, p models.Payment) (models.Receipt, bool) {
+	rec, _, err := h.receipts.Issue(c.Request.Context(), p)
+	if err != nil {
+		if errors.Is(err, receipts.ErrNotCaptured) {
+			c.JSON(http.StatusConflict, gin.H{"error": "payment has not been captured", "status": p.Status})
+			return rec, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "issue receipt failed"})
+		return rec, false
+	}
+	return rec, true
+}
+
+// GET /v1/payments/:id/receipt?format=json|html|pdf
+// The receipt is issued once, when the payment is first captured, and is
+// returned unchanged afterwards.
+func (h *Handler) Receipt(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "html" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, html or pdf"})
+		return
+	}
+	p, ok := h.loadPayment(c, uid)
+	if !ok {
+		return
+	}
+	rec, ok := h.issueReceipt(c, p)
+	if !ok {
+		return
+	}
+	doc, err := h.receipts.Document(rec)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load receipt failed"})
+		return
+	}
+
+	switch format {
+	case "html":
+		page, err := receipts.HTML(doc)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "render receipt failed"})
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+	case "pdf":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, rec.Number))
+		c.Data(http.StatusOK, "application/pdf", receipts.PDF(doc))
+	default:
+		c.JSON(http.StatusOK, gin.H{"receipt": rec, "document": doc})
+	}
+}
+
+type emailReceiptReq struct {
+	To string `json:"to" binding:"omitempty,email"` // default: the account's email
+}
+
+// POST /v1/payments/:id/receipt/email
+func (h *Handler) EmailReceipt(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	var req emailReceiptReq
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	p, ok := h.loadPayment(c, uid)
+	if !ok {
+		return
+	}
+	rec, ok := h.issueReceipt(c, p)
+	if !ok {
+		return
+	}
+
+	to := req.To
+	if to == "" {
+		var user models.User
+		if err := h.db.First(&user, uid).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "load user failed"})
+			return
+		}
+		to = user.Email
+	}
+	if err := h.receipts.Email(c.Request.Context(), &rec, to); err != nil {
+		if errors.Is(err, mail.ErrDisabled) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "email is not configured"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "send receipt failed"})
+		return
+	}
+	c.JSON(http.StatusOK, rec)
+}
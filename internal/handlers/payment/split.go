@@ -0,0 +1,507 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	paymentadapter "navmate-backend/internal/adapters/payment"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/repository"
+)
+
+// errGateway marks the errors of authorizeShare that come from the gateway.
+var errGateway = errors.New("payment gateway failed")
+
+type splitParticipant struct {
+	Email       string `json:"email" binding:"required,email"`
+	AmountCents *int   `json:"amount_cents" binding:"omitempty,gt=0"` // omitted for everyone = equal shares
+}
+
+type splitReq struct {
+	Participants []splitParticipant `json:"participants" binding:"required,min=1,dive"`
+	TipCents     int                `json:"tip_cents" binding:"gte=0"`
+	OnDecline    string             `json:"on_decline"` // owner_covers (default) | void
+	// how the owner pays their share, as in authorize
+	Source          string `json:"source"`
+	PaymentMethodID *uint  `json:"payment_method_id"`
+}
+
+// POST /v1/bookings/:id/split
+// Splits what the booking is charged between its owner and other NavMate
+// users. Every share is paid with its own payment; the owner's is authorized
+// right away, the participants pay theirs with POST /v1/splits/:id/accept.
+func (h *Handler) CreateSplit(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	var req splitReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.OnDecline == "" {
+		req.OnDecline = models.SplitOwnerCovers
+	}
+	if req.OnDecline != models.SplitOwnerCovers && req.OnDecline != models.SplitVoid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "on_decline must be owner_covers or void"})
+		return
+	}
+	if len(req.Participants) > h.splitMax {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d participants", h.splitMax)})
+		return
+	}
+	ctx := c.Request.Context()
+
+	var b models.RideBooking
+	if err := h.db.First(&b, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+		return
+	}
+	var plan models.TripPlan
+	if err := h.db.First(&plan, b.PlanID).Error; err != nil || plan.UserID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+	if b.PaymentID != nil {
+		var existing models.Payment
+		if err := h.db.First(&existing, *b.PaymentID).Error; err == nil && livePayment(existing) {
+			c.JSON(http.StatusConflict, gin.H{"error": "booking already has a payment", "payment_id": existing.ID, "status": existing.Status})
+			return
+		}
+	}
+	if !payableStatuses[b.Status] {
+		c.JSON(http.StatusConflict, gin.H{"error": "booking is not payable", "status": b.Status})
+		return
+	}
+
+	users, ok := h.splitUsers(c, uid, req.Participants)
+	if !ok {
+		return
+	}
+	bd, err := h.chargeable(ctx, b, req.TipCents)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "price booking failed"})
+		return
+	}
+	total := bd.total()
+	if total <= 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "nothing to charge", "expected": bd.json()})
+		return
+	}
+
+	// the owner's share comes first and takes what the participants don't pay
+	amounts := make([]int, len(req.Participants)+1)
+	given := 0
+	for i, p := range req.Participants {
+		if p.AmountCents != nil {
+			amounts[i+1] = *p.AmountCents
+			given++
+		}
+	}
+	switch given {
+	case 0:
+		weights := make([]int, len(amounts))
+		for i := range weights {
+			weights[i] = 1
+		}
+		amounts = models.Allocate(total, weights)
+	case len(req.Participants):
+		amounts[0] = total
+		for _, a := range amounts[1:] {
+			amounts[0] -= a
+		}
+		if amounts[0] < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "shares add up to more than the fare", "expected": bd.json()})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "give amount_cents for every participant or for none"})
+		return
+	}
+	if slices.Min(amounts[1:]) <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fare too small to split between this many people", "expected": bd.json()})
+		return
+	}
+
+	var (
+		ar       paymentadapter.AuthRequest
+		methodID *uint
+	)
+	if amounts[0] > 0 {
+		if methodID, ok = h.paymentSource(c, uid, authorizeReq{Source: req.Source, PaymentMethodID: req.PaymentMethodID}, &ar); !ok {
+			return
+		}
+	}
+
+	// the breakdown is persisted on the booking before money moves, as in Authorize
+	if err := h.promos.Settle(ctx, &b, bd.DiscountCents, bd.CreditCents); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save discounts failed"})
+		return
+	}
+	s := models.FareSplit{
+		BookingID: b.ID, OwnerID: uid, Status: models.SplitOpen, OnDecline: req.OnDecline,
+		Currency: bd.Currency, TotalCents: total, ExpiresAt: time.Now().Add(h.splitTimeout),
+	}
+	var shares []models.SplitShare
+	if amounts[0] > 0 {
+		shares = append(shares, models.SplitShare{UserID: uid, Role: models.ShareOwner, AmountCents: amounts[0], Status: models.ShareInvited})
+	}
+	for i, u := range users {
+		shares = append(shares, models.SplitShare{UserID: u.ID, Role: models.ShareParticipant, AmountCents: amounts[i+1], Status: models.ShareInvited})
+	}
+	if err := h.splits.Create(ctx, &s, shares); err != nil {
+		if errors.Is(err, repository.ErrSplitExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create split failed"})
+		return
+	}
+
+	extra := gin.H{}
+	if amounts[0] > 0 {
+		// the split stands even if this fails; the owner can pay with accept
+		pay, uri, err := h.authorizeShare(ctx, &s, &shares[0], ar, methodID)
+		if err != nil {
+			extra["owner_payment_error"] = err.Error()
+		} else {
+			extra["owner_payment"] = sharePaymentResp(pay, uri)
+		}
+	}
+	h.splitResp(c, http.StatusCreated, s, shares, extra)
+}
+
+// splitUsers looks up the invited users, writing the error response when
+// one is not a NavMate user, is the owner or is invited twice.
+func (h *Handler) splitUsers(c *gin.Context, ownerID uint, ps []splitParticipant) ([]models.User, bool) {
+	emails := make([]string, len(ps))
+	seen := map[string]bool{}
+	for i, p := range ps {
+		emails[i] = strings.ToLower(strings.TrimSpace(p.Email))
+		if seen[emails[i]] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "participant invited twice", "email": emails[i]})
+			return nil, false
+		}
+		seen[emails[i]] = true
+	}
+	var found []models.User
+	if err := h.db.Where("email IN ?", emails).Find(&found).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load users failed"})
+		return nil, false
+	}
+	byEmail := map[string]models.User{}
+	for _, u := range found {
+		byEmail[u.Email] = u
+	}
+	users := make([]models.User, len(emails))
+	for i, e := range emails {
+		u, ok := byEmail[e]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no NavMate user with this email", "email": e})
+			return nil, false
+		}
+		if u.ID == ownerID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot invite yourself"})
+			return nil, false
+		}
+		users[i] = u
+	}
+	return users, true
+}
+
+// authorizeShare pays share with the source in ar. The payment is linked to
+// the share before the gateway is called, like Authorize does for bookings.
+func (h *Handler) authorizeShare(ctx context.Context, s *models.FareSplit, share *models.SplitShare, ar paymentadapter.AuthRequest, methodID *uint) (models.Payment, string, error) {
+	pay := models.Payment{
+		Status:          paymentadapter.StatusPending,
+		Currency:        s.Currency,
+		AmountCents:     share.AmountCents,
+		FareCents:       share.AmountCents,
+		Gateway:         h.gateway.Name(),
+		PaymentMethodID: methodID,
+	}
+	prev := *share
+	if err := h.splits.ClaimShare(ctx, &pay, share); err != nil {
+		return pay, "", err
+	}
+
+	ar.AmountCents, ar.Currency = pay.AmountCents, pay.Currency
+	ar.Description = fmt.Sprintf("NavMate booking %d, split %d", s.BookingID, s.ID)
+	ar.IdempotencyKey = fmt.Sprintf("payment-%d", pay.ID)
+	ch, err := h.gateway.Authorize(ctx, ar)
+	if err != nil {
+		_ = h.splits.ReleaseShare(ctx, &pay, share, prev)
+		return pay, "", fmt.Errorf("%w: %w", errGateway, err)
+	}
+	pay.ExternalRef = ch.ExternalRef
+	if err := h.payments.ApplyCharge(ctx, &pay, ch, repository.LedgerMeta{Actor: "user", Note: "split share"}); err != nil {
+		return pay, "", err
+	}
+	share.PaymentStatus = pay.Status
+	h.splits.Notify(ctx, s)
+	return pay, ch.AuthorizeURI, nil
+}
+
+func sharePaymentResp(pay models.Payment, authorizeURI string) gin.H {
+	resp := gin.H{"payment_id": pay.ID, "status": pay.Status, "amount_cents": pay.AmountCents, "currency": pay.Currency}
+	if pay.FailureCode != "" {
+		resp["failure_code"] = pay.FailureCode
+	}
+	if authorizeURI != "" {
+		resp["authorize_uri"] = authorizeURI
+	}
+	return resp
+}
+
+// shareView is a share as the split's members see it.
+type shareView struct {
+	models.SplitShare
+	Email string `json:"email"`
+	Paid  bool   `json:"paid"`
+}
+
+// splitView is the split with who has paid what.
+func (h *Handler) splitView(ctx context.Context, s models.FareSplit, shares []models.SplitShare) (gin.H, error) {
+	ids := make([]uint, 0, len(shares))
+	for _, sh := range shares {
+		ids = append(ids, sh.UserID)
+	}
+	var users []models.User
+	if err := h.db.WithContext(ctx).Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	emails := map[uint]string{}
+	for _, u := range users {
+		emails[u.ID] = u.Email
+	}
+
+	views := make([]shareView, len(shares))
+	paid := 0
+	for i, sh := range shares {
+		views[i] = shareView{SplitShare: sh, Email: emails[sh.UserID], Paid: sh.Paid()}
+		if sh.Paid() {
+			paid += sh.AmountCents
+		}
+	}
+	return gin.H{
+		"split": s, "shares": views,
+		"paid_cents": paid, "remaining_cents": max(0, s.TotalCents-paid),
+	}, nil
+}
+
+func (h *Handler) splitResp(c *gin.Context, code int, s models.FareSplit, shares []models.SplitShare, extra gin.H) {
+	view, err := h.splitView(c.Request.Context(), s, shares)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load split failed"})
+		return
+	}
+	for k, v := range extra {
+		view[k] = v
+	}
+	c.JSON(code, view)
+}
+
+// loadSplit fetches a split uid owns or has a share in, writing the error
+// response when it does not.
+func (h *Handler) loadSplit(c *gin.Context, uid uint) (models.FareSplit, []models.SplitShare, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return models.FareSplit{}, nil, false
+	}
+	s, shares, err := h.splits.Get(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "split not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "load split failed"})
+		}
+		return s, nil, false
+	}
+	if s.OwnerID == uid {
+		return s, shares, true
+	}
+	for _, sh := range shares {
+		if sh.UserID == uid {
+			return s, shares, true
+		}
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+	return s, nil, false
+}
+
+// ownShare is uid's share to pay; the owner's cover share is never paid by hand.
+func ownShare(shares []models.SplitShare, uid uint) *models.SplitShare {
+	for i := range shares {
+		if shares[i].UserID == uid && shares[i].Role != models.ShareCover {
+			return &shares[i]
+		}
+	}
+	return nil
+}
+
+// GET /v1/splits/:id
+// Who has paid their share; for the owner and the participants.
+func (h *Handler) GetSplit(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	s, shares, ok := h.loadSplit(c, uid)
+	if !ok {
+		return
+	}
+	h.splitResp(c, http.StatusOK, s, shares, nil)
+}
+
+// GET /v1/me/splits
+// Splits the user owns or was invited to, newest first.
+func (h *Handler) ListSplits(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	ctx := c.Request.Context()
+	list, err := h.splits.ForUser(ctx, uid, 20)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load splits failed"})
+		return
+	}
+	views := make([]gin.H, 0, len(list))
+	for _, s := range list {
+		shares, err := h.splits.Shares(ctx, s.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "load splits failed"})
+			return
+		}
+		view, err := h.splitView(ctx, s, shares)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "load splits failed"})
+			return
+		}
+		views = append(views, view)
+	}
+	c.JSON(http.StatusOK, gin.H{"splits": views})
+}
+
+type payShareReq struct {
+	// as in authorize: a one-time card token, or a saved method (default: the user's default method)
+	Source          string `json:"source"`
+	PaymentMethodID *uint  `json:"payment_method_id"`
+}
+
+// POST /v1/splits/:id/accept
+// Authorizes the user's share; can be retried with another source after a
+// decline, until the split's deadline.
+func (h *Handler) AcceptSplit(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	var req payShareReq
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	s, shares, ok := h.loadSplit(c, uid)
+	if !ok {
+		return
+	}
+	share := ownShare(shares, uid)
+	if share == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "you have no share to pay"})
+		return
+	}
+	if s.Status != models.SplitOpen || !time.Now().Before(s.ExpiresAt) {
+		c.JSON(http.StatusConflict, gin.H{"error": "split is no longer open", "status": s.Status})
+		return
+	}
+	if !share.Open() || share.PaymentStatus == paymentadapter.StatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "share already paid or answered", "share_status": share.Status, "payment_status": share.PaymentStatus})
+		return
+	}
+
+	var ar paymentadapter.AuthRequest
+	methodID, ok := h.paymentSource(c, uid, authorizeReq{Source: req.Source, PaymentMethodID: req.PaymentMethodID}, &ar)
+	if !ok {
+		return
+	}
+	pay, uri, err := h.authorizeShare(c.Request.Context(), &s, share, ar, methodID)
+	if err != nil {
+		if errors.Is(err, repository.ErrShareNotOpen) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, errGateway) {
+			gatewayFailed(c, err)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "pay share failed"})
+		return
+	}
+
+	code := http.StatusOK
+	switch pay.Status {
+	case paymentadapter.StatusDeclined:
+		code = http.StatusPaymentRequired
+	case paymentadapter.StatusPending:
+		code = http.StatusAccepted // e.g. 3-D Secure: the client sends the user to authorize_uri
+	}
+	h.splitResp(c, code, s, shares, gin.H{"payment": sharePaymentResp(pay, uri)})
+}
+
+// POST /v1/splits/:id/decline
+// The participant won't pay; their share is left to the split's on_decline.
+func (h *Handler) DeclineSplit(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	s, shares, ok := h.loadSplit(c, uid)
+	if !ok {
+		return
+	}
+	share := ownShare(shares, uid)
+	if share == nil || share.Role != models.ShareParticipant {
+		c.JSON(http.StatusConflict, gin.H{"error": "only participants can decline"})
+		return
+	}
+	if s.Status != models.SplitOpen {
+		c.JSON(http.StatusConflict, gin.H{"error": "split is no longer open", "status": s.Status})
+		return
+	}
+	if err := h.splits.Decline(c.Request.Context(), &s, share); err != nil {
+		if errors.Is(err, repository.ErrShareNotOpen) {
+			c.JSON(http.StatusConflict, gin.H{"error": "share already paid or answered", "share_status": share.Status})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "decline failed"})
+		return
+	}
+	h.splitResp(c, http.StatusOK, s, shares, nil)
+}
+
+// DELETE /v1/splits/:id
+// The owner calls off an open split; shares already authorized are voided
+// by jobs.SplitSettler and the booking can be paid as usual.
+func (h *Handler) CancelSplit(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	s, shares, ok := h.loadSplit(c, uid)
+	if !ok {
+		return
+	}
+	if s.OwnerID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+	if s.Status != models.SplitOpen {
+		c.JSON(http.StatusConflict, gin.H{"error": "only an open split can be cancelled", "status": s.Status})
+		return
+	}
+	if err := h.splits.SetStatus(c.Request.Context(), &s, models.SplitCancelled, "cancelled by the owner"); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusConflict, gin.H{"error": "split changed meanwhile, try again"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cancel split failed"})
+		return
+	}
+	h.splitResp(c, http.StatusOK, s, shares, nil)
+}
@@ -0,0 +1,87 @@
+package payment
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	paymentadapter "navmate-backend/internal/adapters/payment"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/repository"
+)
+
+// POST /v1/payments/webhook (public endpoint)
+// Verifies the gateway's signature and stores the event; the payment is
+// updated by jobs.PaymentWebhookProcessor, so the gateway gets its 200 fast.
+func (h *Handler) Webhook(c *gin.Context) {
+	ev, err := h.gateway.ParseWebhook(c.Request)
+	switch {
+	case errors.Is(err, paymentadapter.ErrBadSignature), errors.Is(err, paymentadapter.ErrStaleWebhook):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	rec := models.PaymentWebhookEvent{
+		Gateway:   h.gateway.Name(),
+		EventID:   ev.ID,
+		Type:      ev.Type,
+		ChargeRef: ev.ChargeRef,
+		Payload:   string(ev.Payload),
+		Status:    models.WebhookReceived,
+	}
+	created, err := h.payments.RecordWebhook(c.Request.Context(), &rec)
+	if err != nil {
+		// not acknowledged, so the gateway delivers it again
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "store webhook failed"})
+		return
+	}
+	if !created {
+		c.JSON(http.StatusOK, gin.H{"received": true, "duplicate": true})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
+
+// GET /v1/admin/payments/webhooks?status=failed
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+		return
+	}
+	list, err := h.payments.Webhooks(c.Request.Context(), c.Query("status"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list webhooks failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"events": list})
+}
+
+// POST /v1/admin/payments/webhooks/:id/replay
+// Queues a failed or ignored event for the processor again.
+func (h *Handler) ReplayWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	ev, err := h.payments.ReplayWebhook(c.Request.Context(), uint(id))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook event not found"})
+		return
+	case errors.Is(err, repository.ErrWebhookNotReplayable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": ev.Status})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "replay webhook failed"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"id": ev.ID, "event_id": ev.EventID, "status": ev.Status})
+}
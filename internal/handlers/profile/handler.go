@@ -0,0 +1,101 @@
+package profile
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/money"
+	"navmate-backend/internal/repository"
+)
+
+type Handler struct {
+	prefs *repository.PreferenceRepository
+}
+
+func New(db *gorm.DB) *Handler { return &Handler{prefs: repository.NewPreferenceRepository(db)} }
+
+// GET /v1/me/preferences
+func (h *Handler) GetPreferences(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	p, err := h.prefs.Get(c.Request.Context(), uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load preferences failed"})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// Pointers so that omitted fields keep their current value.
+type preferencesReq struct {
+	MaxSurgeMultiplier *float64 `json:"max_surge_multiplier"`
+	RideFallback       *string  `json:"ride_fallback"`
+	Currency           *string  `json:"currency"` // "" = local currency only
+	BillingName        *string  `json:"billing_name" binding:"omitempty,max=200"`
+	BillingTaxID       *string  `json:"billing_tax_id" binding:"omitempty,max=20"`
+	BillingAddress     *string  `json:"billing_address" binding:"omitempty,max=500"`
+	EmailReceipts      *bool    `json:"email_receipts"`
+}
+
+// PUT /v1/me/preferences
+func (h *Handler) UpdatePreferences(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	var req preferencesReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	p, err := h.prefs.Get(c.Request.Context(), uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load preferences failed"})
+		return
+	}
+	if req.MaxSurgeMultiplier != nil {
+		if *req.MaxSurgeMultiplier < 1 || *req.MaxSurgeMultiplier > 5 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_surge_multiplier must be between 1 and 5"})
+			return
+		}
+		p.MaxSurgeMultiplier = *req.MaxSurgeMultiplier
+	}
+	if req.RideFallback != nil {
+		if !models.ValidRideFallback(*req.RideFallback) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ride_fallback must be retry, next_provider or alternative"})
+			return
+		}
+		p.RideFallback = *req.RideFallback
+	}
+	if req.Currency != nil {
+		cur := money.Normalize(*req.Currency)
+		if cur != "" && !money.Known(cur) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported currency"})
+			return
+		}
+		p.Currency = cur
+	}
+	if req.BillingName != nil {
+		p.BillingName = strings.TrimSpace(*req.BillingName)
+	}
+	if req.BillingTaxID != nil {
+		p.BillingTaxID = strings.TrimSpace(*req.BillingTaxID)
+	}
+	if req.BillingAddress != nil {
+		p.BillingAddress = strings.TrimSpace(*req.BillingAddress)
+	}
+	if p.BillingTaxID != "" && p.BillingName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "billing_name is required with billing_tax_id"})
+		return
+	}
+	if req.EmailReceipts != nil {
+		p.EmailReceipts = *req.EmailReceipts
+	}
+
+	if err := h.prefs.Save(c.Request.Context(), &p); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save preferences failed"})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
@@ -0,0 +1,264 @@
+package promo
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/adapters/fx"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/money"
+	"navmate-backend/internal/repository"
+)
+
+type Handler struct {
+	db       *gorm.DB
+	promos   *repository.PromotionRepository
+	currency string // default of new promotions
+}
+
+func New(db *gorm.DB, rates fx.RateSource, cfg *config.Config) *Handler {
+	return &Handler{
+		db:       db,
+		promos:   repository.NewPromotionRepository(db, rates),
+		currency: cfg.Money.Currency,
+	}
+}
+
+type createReq struct {
+	Code             string     `json:"code" binding:"required,max=64"`
+	Description      string     `json:"description"`
+	Kind             string     `json:"kind" binding:"required,oneof=percent fixed"`
+	Value            int        `json:"value" binding:"required,gt=0"` // percent, or minor units of currency
+	Currency         string     `json:"currency"`                      // default: CURRENCY
+	MaxDiscountCents int        `json:"max_discount_cents" binding:"gte=0"`
+	MinFareCents     int        `json:"min_fare_cents" binding:"gte=0"`
+	StartsAt         *time.Time `json:"starts_at"`
+	EndsAt           *time.Time `json:"ends_at"`
+	MaxUses          int        `json:"max_uses" binding:"gte=0"`
+	MaxUsesPerUser   *int       `json:"max_uses_per_user" binding:"omitempty,gte=0"` // default 1
+	Providers        []string   `json:"providers"`
+	ModeMixes        []string   `json:"mode_mixes"`
+	Active           *bool      `json:"active"`
+}
+
+// validate checks the settings a promotion can't be redeemed with.
+func validate(p models.Promotion) string {
+	switch {
+	case strings.TrimSpace(p.Code) == "":
+		return "code is required"
+	case p.Kind == models.PromoPercent && p.Value > 100:
+		return "a percent value is at most 100"
+	case !money.Known(p.Currency):
+		return "unsupported currency"
+	case p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt):
+		return "ends_at must be after starts_at"
+	}
+	return ""
+}
+
+func joinList(list []string) string {
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return strings.Join(out, ",")
+}
+
+// POST /v1/admin/promotions
+func (h *Handler) Create(c *gin.Context) {
+	var req createReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p := models.Promotion{
+		Code: repository.NormalizeCode(req.Code), Description: req.Description, Kind: req.Kind, Value: req.Value,
+		Currency: money.Normalize(req.Currency), MaxDiscountCents: req.MaxDiscountCents, MinFareCents: req.MinFareCents,
+		StartsAt: req.StartsAt, EndsAt: req.EndsAt, MaxUses: req.MaxUses, MaxUsesPerUser: 1,
+		Providers: joinList(req.Providers), ModeMixes: joinList(req.ModeMixes), Active: true,
+	}
+	if p.Currency == "" {
+		p.Currency = h.currency
+	}
+	if req.MaxUsesPerUser != nil {
+		p.MaxUsesPerUser = *req.MaxUsesPerUser
+	}
+	if req.Active != nil {
+		p.Active = *req.Active
+	}
+	if msg := validate(p); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if err := h.promos.Create(c.Request.Context(), &p); err != nil {
+		if errors.Is(err, repository.ErrPromoExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create promotion failed"})
+		return
+	}
+	c.JSON(http.StatusCreated, p)
+}
+
+// GET /v1/admin/promotions
+func (h *Handler) List(c *gin.Context) {
+	list, err := h.promos.List(c.Request.Context(), 200)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list promotions failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": list})
+}
+
+type updateReq struct {
+	Description      *string    `json:"description"`
+	Value            *int       `json:"value" binding:"omitempty,gt=0"`
+	MaxDiscountCents *int       `json:"max_discount_cents" binding:"omitempty,gte=0"`
+	MinFareCents     *int       `json:"min_fare_cents" binding:"omitempty,gte=0"`
+	StartsAt         *time.Time `json:"starts_at"`
+	EndsAt           *time.Time `json:"ends_at"`
+	MaxUses          *int       `json:"max_uses" binding:"omitempty,gte=0"`
+	MaxUsesPerUser   *int       `json:"max_uses_per_user" binding:"omitempty,gte=0"`
+	Providers        *[]string  `json:"providers"`
+	ModeMixes        *[]string  `json:"mode_mixes"`
+	Active           *bool      `json:"active"`
+}
+
+// PATCH /v1/admin/promotions/:id
+// Code, kind and currency are fixed once created; redemptions already made keep their discount.
+func (h *Handler) Update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req updateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	p, err := h.promos.Get(ctx, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "promotion not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load promotion failed"})
+		return
+	}
+
+	if req.Description != nil {
+		p.Description = *req.Description
+	}
+	if req.Value != nil {
+		p.Value = *req.Value
+	}
+	if req.MaxDiscountCents != nil {
+		p.MaxDiscountCents = *req.MaxDiscountCents
+	}
+	if req.MinFareCents != nil {
+		p.MinFareCents = *req.MinFareCents
+	}
+	if req.StartsAt != nil {
+		p.StartsAt = req.StartsAt
+	}
+	if req.EndsAt != nil {
+		p.EndsAt = req.EndsAt
+	}
+	if req.MaxUses != nil {
+		p.MaxUses = *req.MaxUses
+	}
+	if req.MaxUsesPerUser != nil {
+		p.MaxUsesPerUser = *req.MaxUsesPerUser
+	}
+	if req.Providers != nil {
+		p.Providers = joinList(*req.Providers)
+	}
+	if req.ModeMixes != nil {
+		p.ModeMixes = joinList(*req.ModeMixes)
+	}
+	if req.Active != nil {
+		p.Active = *req.Active
+	}
+	if msg := validate(p); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if err := h.promos.Save(ctx, &p); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update promotion failed"})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+type grantReq struct {
+	AmountCents int    `json:"amount_cents" binding:"required,gt=0"`
+	Currency    string `json:"currency"` // default: CURRENCY
+	Note        string `json:"note"`
+}
+
+// POST /v1/admin/users/:id/credits
+func (h *Handler) GrantCredit(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req grantReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cur := money.Normalize(req.Currency)
+	if cur == "" {
+		cur = h.currency
+	}
+	if !money.Known(cur) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported currency"})
+		return
+	}
+	var cnt int64
+	h.db.Model(&models.User{}).Where("id = ?", id).Count(&cnt)
+	if cnt == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	t := models.CreditTransaction{
+		UserID: uint(id), AmountCents: req.AmountCents, Currency: cur, Actor: c.GetString("email"), Note: req.Note,
+	}
+	if err := h.promos.GrantCredit(c.Request.Context(), &t); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "grant credit failed"})
+		return
+	}
+	c.JSON(http.StatusCreated, t)
+}
+
+// GET /v1/me/credits
+// Balances per currency and the latest changes.
+func (h *Handler) Credits(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	ctx := c.Request.Context()
+	balances, err := h.promos.CreditBalances(ctx, uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load credits failed"})
+		return
+	}
+	items, err := h.promos.Credits(ctx, uid, 50)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load credits failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"balances": balances, "items": items})
+}
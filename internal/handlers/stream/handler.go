@@ -0,0 +1,59 @@
+package stream
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/events"
+)
+
+type Handler struct {
+	bus       events.Bus
+	keepAlive time.Duration
+}
+
+func New(bus events.Bus, cfg *config.Config) *Handler {
+	keepAlive := cfg.Events.KeepAlive
+	if keepAlive <= 0 {
+		keepAlive = 25 * time.Second
+	}
+	return &Handler{bus: bus, keepAlive: keepAlive}
+}
+
+// GET /v1/events/stream
+// Server-Sent Events stream of the user's booking, trip, safety and payment
+// updates. Events are live only; clients re-read state after reconnecting.
+func (h *Handler) Stream(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	ch, unsubscribe := h.bus.Subscribe(uid)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // disable proxy buffering (nginx)
+	c.Status(http.StatusOK)
+	c.SSEvent("ready", gin.H{"user_id": uid})
+	c.Writer.Flush()
+
+	ping := time.NewTicker(h.keepAlive)
+	defer ping.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case e, ok := <-ch:
+			if !ok {
+				return false
+			}
+			c.SSEvent(e.Type, e)
+		case <-ping.C:
+			_, _ = io.WriteString(w, ": ping\n\n")
+		}
+		return true
+	})
+}
@@ -1,35 +1,51 @@
 package travel
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 
 	"navmate-backend/config" // Import config to get API Key
+	"navmate-backend/internal/adapters/fx"
 	"navmate-backend/internal/adapters/maps"
 	"navmate-backend/internal/models"
+	"navmate-backend/internal/repository"
 )
 
 type Handler struct {
-	db          *gorm.DB
-	mapsAdapter *maps.GoogleMapsAdapter // NEW: Use the new adapter
+	db           *gorm.DB
+	mapsAdapter  *maps.GoogleMapsAdapter // NEW: Use the new adapter
+	trips        *repository.TripRepository
+	prefs        *repository.PreferenceRepository
+	rates        fx.RateSource
+	log          *zap.SugaredLogger
+	itineraryTTL time.Duration
 }
 
 // New handler now needs the app config to get the API Key
-func New(db *gorm.DB, cfg *config.Config) *Handler {
+func New(db *gorm.DB, rates fx.RateSource, cfg *config.Config, logger *zap.SugaredLogger) *Handler {
 	// NEW: Initialize the Google Maps Adapter using the key from config
-	adapter, err := maps.NewGoogleMapsAdapter(cfg.Google.GoogleMapsAPIKey)
+	adapter, err := maps.NewGoogleMapsAdapter(cfg.Google.GoogleMapsAPIKey, cfg.Ride.DefaultProvider, cfg.Money.DefaultRegion)
 	if err != nil {
 		// Log a fatal error if the adapter can't be created, as it's critical.
 		log.Fatalf("Failed to create maps adapter: %v", err)
 	}
 
 	return &Handler{
-		db:          db,
-		mapsAdapter: adapter,
+		db:           db,
+		mapsAdapter:  adapter,
+		trips:        repository.NewTripRepository(db),
+		prefs:        repository.NewPreferenceRepository(db),
+		rates:        rates,
+		log:          logger,
+		itineraryTTL: cfg.Trips.ItineraryTTL,
 	}
 }
 
@@ -57,40 +73,39 @@ func (h *Handler) Plan(c *gin.Context) {
 	// NEW: generate options using the real adapter
 	opts := h.mapsAdapter.EstimateItineraries(req.Origin, req.Destination, tptr)
 
-	plan := models.TripPlan{UserID: uid, Origin: req.Origin, Destination: req.Destination, DepartAt: tptr}
-	if err := h.db.Create(&plan).Error; err != nil {
+	var expiresAt *time.Time
+	if h.itineraryTTL > 0 {
+		t := time.Now().Add(h.itineraryTTL)
+		expiresAt = &t
+	}
+	plan := repository.BuildPlan(uid, req.Origin, req.Destination, tptr, opts, expiresAt)
+	if pref, err := h.prefs.Get(c.Request.Context(), uid); err == nil {
+		if err := repository.LocalizePlan(c.Request.Context(), h.rates, &plan, pref.Currency); err != nil {
+			// fares are still shown in the local currency
+			h.log.Warnw("travel: convert plan", "user_id", uid, "currency", pref.Currency, "err", err)
+		}
+	}
+	if err := h.trips.CreatePlan(c.Request.Context(), &plan); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "create plan failed"})
 		return
 	}
 
 	type optResp struct {
-		ItineraryID    uint   `json:"itinerary_id"`
-		ModeMix        string `json:"mode_mix"`
-		TotalMinutes   int    `json:"total_minutes"`
-		RoughCostCents int    `json:"rough_cost_cents"`
+		ItineraryID      uint       `json:"itinerary_id"`
+		ModeMix          string     `json:"mode_mix"`
+		TotalMinutes     int        `json:"total_minutes"`
+		RoughCostCents   int        `json:"rough_cost_cents"`
+		Currency         string     `json:"currency"`
+		DisplayCurrency  string     `json:"display_currency,omitempty"`
+		DisplayCostCents int        `json:"display_cost_cents,omitempty"`
+		ExpiresAt        *time.Time `json:"expires_at,omitempty"`
 	}
-	resp := make([]optResp, 0, len(opts))
-
-	for _, o := range opts {
-		it := models.Itinerary{
-			PlanID: plan.ID, ModeMix: o.ModeMix, TotalMinutes: o.TotalMinutes, RoughCostCents: o.RoughCostCents,
-		}
-		if err := h.db.Create(&it).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "create itinerary failed"})
-			return
-		}
-		for i, l := range o.Legs {
-			leg := models.Leg{
-				ItineraryID: it.ID, Index: i, Mode: l.Mode, FromName: l.From, ToName: l.To,
-				Minutes: l.Minutes, DistanceM: l.DistanceM, Provider: l.Provider,
-			}
-			if err := h.db.Create(&leg).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "create leg failed"})
-				return
-			}
-		}
+	resp := make([]optResp, 0, len(plan.Itineraries))
+	for _, it := range plan.Itineraries {
 		resp = append(resp, optResp{
 			ItineraryID: it.ID, ModeMix: it.ModeMix, TotalMinutes: it.TotalMinutes, RoughCostCents: it.RoughCostCents,
+			Currency: it.Currency, DisplayCurrency: it.DisplayCurrency, DisplayCostCents: it.DisplayCostCents,
+			ExpiresAt: it.ExpiresAt,
 		})
 	}
 	c.JSON(http.StatusOK, gin.H{
@@ -99,22 +114,103 @@ func (h *Handler) Plan(c *gin.Context) {
 	})
 }
 
+type legResp struct {
+	ID        uint    `json:"id"`
+	Index     int     `json:"index"`
+	Mode      string  `json:"mode"`
+	FromName  string  `json:"from_name"`
+	ToName    string  `json:"to_name"`
+	Minutes   int     `json:"minutes"`
+	DistanceM int64   `json:"distance_m"`
+	Provider  *string `json:"provider,omitempty"`
+	FareCents int     `json:"fare_cents"`
+	Currency  string  `json:"currency"` // the itinerary's
+}
+
+type itineraryResp struct {
+	ID               uint       `json:"id"`
+	ModeMix          string     `json:"mode_mix"`
+	TotalMinutes     int        `json:"total_minutes"`
+	RoughCostCents   int        `json:"rough_cost_cents"`
+	Currency         string     `json:"currency"`
+	DisplayCurrency  string     `json:"display_currency,omitempty"`
+	DisplayCostCents int        `json:"display_cost_cents,omitempty"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	Expired          bool       `json:"expired"`
+	Selected         bool       `json:"selected"`
+	Legs             []legResp  `json:"legs,omitempty"`
+}
+
+// parseInclude reads ?include=itineraries,legs. Without the parameter the full
+// plan is returned; include=none returns only the summary fields.
+func parseInclude(c *gin.Context) (itineraries, legs bool) {
+	v, ok := c.GetQuery("include")
+	if !ok {
+		return true, true
+	}
+	for _, part := range strings.Split(v, ",") {
+		switch strings.TrimSpace(strings.ToLower(part)) {
+		case "itineraries":
+			itineraries = true
+		case "legs":
+			// legs are nested in itineraries, so asking for legs implies both
+			itineraries, legs = true, true
+		}
+	}
+	return itineraries, legs
+}
+
+// GET /v1/trips/plans/:id?include=itineraries,legs
 func (h *Handler) GetPlan(c *gin.Context) {
 	uid := uint(c.GetInt("user_id"))
 	id := c.Param("id")
+	withItins, withLegs := parseInclude(c)
 
 	var p models.TripPlan
 	if err := h.db.Where("id = ? AND user_id = ?", id, uid).First(&p).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 		return
 	}
+	q := h.db.Where("plan_id = ?", p.ID).Order("id ASC")
+	if withLegs {
+		q = q.Preload("Legs", func(db *gorm.DB) *gorm.DB { return db.Order("index ASC") })
+	}
 	var itins []models.Itinerary
-	_ = h.db.Where("plan_id = ?", p.ID).Order("id ASC").Find(&itins).Error
+	if err := q.Find(&itins).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load itineraries failed"})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
+	resp := gin.H{
 		"id": p.ID, "origin": p.Origin, "destination": p.Destination, "status": p.Status,
+		"depart_at": p.DepartAt, "created_at": p.CreatedAt,
 		"selected_itinerary_id": p.SelectedItineraryID, "itinerary_count": len(itins),
-	})
+	}
+	if withItins {
+		now := time.Now()
+		out := make([]itineraryResp, 0, len(itins))
+		for _, it := range itins {
+			ir := itineraryResp{
+				ID: it.ID, ModeMix: it.ModeMix, TotalMinutes: it.TotalMinutes, RoughCostCents: it.RoughCostCents,
+				Currency: it.Currency, DisplayCurrency: it.DisplayCurrency, DisplayCostCents: it.DisplayCostCents,
+				ExpiresAt: it.ExpiresAt, Expired: it.Expired(now),
+				Selected: p.SelectedItineraryID != nil && *p.SelectedItineraryID == it.ID,
+			}
+			if withLegs {
+				ir.Legs = make([]legResp, 0, len(it.Legs))
+				for _, l := range it.Legs {
+					ir.Legs = append(ir.Legs, legResp{
+						ID: l.ID, Index: l.Index, Mode: l.Mode, FromName: l.FromName, ToName: l.ToName,
+						Minutes: l.Minutes, DistanceM: l.DistanceM, Provider: l.Provider,
+						FareCents: l.FareCents, Currency: it.Currency,
+					})
+				}
+			}
+			out = append(out, ir)
+		}
+		resp["itineraries"] = out
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 type selectReq struct {
@@ -131,25 +227,205 @@ func (h *Handler) SelectItinerary(c *gin.Context) {
 		return
 	}
 
-	var p models.TripPlan
-	if err := h.db.Where("id = ? AND user_id = ?", id, uid).First(&p).Error; err != nil {
+	planID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "plan not found"})
 		return
 	}
 
-	var cnt int64
-	if err := h.db.Model(&models.Itinerary{}).
-		Where("id = ? AND plan_id = ?", req.ItineraryID, p.ID).
-		Count(&cnt).Error; err != nil || cnt == 0 {
+	_, err = h.trips.SelectItinerary(c.Request.Context(), uid, uint(planID), req.ItineraryID, time.Now())
+	switch {
+	case errors.Is(err, repository.ErrPlanNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "plan not found"})
+		return
+	case errors.Is(err, repository.ErrItineraryNotFound):
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid itinerary_id"})
 		return
-	}
-
-	p.SelectedItineraryID = &req.ItineraryID
-	p.Status = "selected"
-	if err := h.db.Save(&p).Error; err != nil {
+	case errors.Is(err, repository.ErrItineraryExpired):
+		c.JSON(http.StatusGone, gin.H{
+			"error": "itinerary expired", "code": "itinerary_expired",
+			"message": "fares and ETAs are out of date; please re-plan the trip",
+		})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
 		return
 	}
 	c.Status(http.StatusNoContent)
 }
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// GET /v1/trips/plans?status=&from=&to=&destination=&cursor=&limit=
+// Plans are returned newest first; next_cursor is the id to pass as cursor for the next page.
+func (h *Handler) ListPlans(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+
+	limit := defaultListLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		if n > maxListLimit {
+			n = maxListLimit
+		}
+		limit = n
+	}
+
+	q := h.db.Where("user_id = ?", uid)
+	if v := c.Query("cursor"); v != "" {
+		cur, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		q = q.Where("id < ?", cur)
+	}
+	if v := c.Query("status"); v != "" {
+		q = q.Where("status = ?", v)
+	}
+	if v := c.Query("from"); v != "" {
+		t, err := parseDateParam(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+			return
+		}
+		q = q.Where("created_at >= ?", t)
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := parseDateParam(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+			return
+		}
+		// a bare date means "up to the end of that day"
+		if !strings.Contains(v, "T") {
+			t = t.AddDate(0, 0, 1)
+		}
+		q = q.Where("created_at < ?", t)
+	}
+	if v := strings.TrimSpace(c.Query("destination")); v != "" {
+		q = q.Where(`destination ILIKE ? ESCAPE '\'`, "%"+escapeLike(v)+"%")
+	}
+
+	var plans []models.TripPlan
+	if err := q.Order("id DESC").Limit(limit + 1).Find(&plans).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list plans failed"})
+		return
+	}
+
+	var next *uint
+	if len(plans) > limit {
+		plans = plans[:limit]
+		next = &plans[limit-1].ID
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"items":       plans,
+		"next_cursor": next,
+	})
+}
+
+// escapeLike makes v match itself literally in a LIKE pattern with ESCAPE '\'.
+func escapeLike(v string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(v)
+}
+
+// parseDateParam accepts either RFC3339 or a plain YYYY-MM-DD date.
+func parseDateParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", v, time.Local)
+}
+
+// co2GramsPerKm are rough per-passenger emission factors used for the stats estimate.
+var co2GramsPerKm = map[string]float64{
+	"WALK":    0,
+	"TRANSIT": 40,
+	"RIDE":    170,
+}
+
+// GET /v1/me/stats
+func (h *Handler) Stats(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+
+	// a trip counts once the user has chosen an itinerary for it
+	var totalTrips int64
+	if err := h.db.Model(&models.TripPlan{}).
+		Where("user_id = ? AND selected_itinerary_id IS NOT NULL AND status <> ?", uid, "cancelled").
+		Count(&totalTrips).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "stats failed"})
+		return
+	}
+
+	type modeRow struct {
+		Mode      string
+		DistanceM int64
+		Minutes   int
+	}
+	var rows []modeRow
+	if err := h.db.Table("legs").
+		Select("legs.mode AS mode, COALESCE(SUM(legs.distance_m), 0) AS distance_m, COALESCE(SUM(legs.minutes), 0) AS minutes").
+		Joins("JOIN trip_plans ON trip_plans.selected_itinerary_id = legs.itinerary_id").
+		Where("trip_plans.user_id = ? AND trip_plans.status <> ?", uid, "cancelled").
+		Group("legs.mode").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "stats failed"})
+		return
+	}
+
+	// what was actually charged, net of refunds: fares after discount and
+	// credit, and cancellation fees, for the user's bookings and the shares
+	// of split fares they paid. Payments are summed per currency.
+	type spentRow struct {
+		Currency string
+		Cents    int64
+	}
+	var spent []spentRow
+	ownBookings := h.db.Model(&models.RideBooking{}).Select("ride_bookings.payment_id").
+		Joins("JOIN trip_plans ON trip_plans.id = ride_bookings.plan_id").
+		Where("trip_plans.user_id = ? AND ride_bookings.payment_id IS NOT NULL", uid)
+	ownShares := h.db.Model(&models.SplitShare{}).Select("payment_id").
+		Where("user_id = ? AND payment_id IS NOT NULL", uid)
+	if err := h.db.Model(&models.Payment{}).
+		Select("currency, COALESCE(SUM(captured_cents - refunded_cents), 0) AS cents").
+		Where("id IN (?) OR id IN (?)", ownBookings, ownShares).
+		Where("captured_cents > 0").
+		Group("currency").
+		Scan(&spent).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "stats failed"})
+		return
+	}
+	rideSpent := make(map[string]int64, len(spent))
+	for _, s := range spent {
+		rideSpent[s.Currency] = s.Cents
+	}
+
+	type modeStats struct {
+		DistanceM int64   `json:"distance_m"`
+		Minutes   int     `json:"minutes"`
+		CO2Grams  float64 `json:"co2_grams"`
+	}
+	byMode := make(map[string]modeStats, len(rows))
+	var totalMinutes int
+	var totalCO2 float64
+	for _, r := range rows {
+		co2 := float64(r.DistanceM) / 1000.0 * co2GramsPerKm[r.Mode]
+		byMode[r.Mode] = modeStats{DistanceM: r.DistanceM, Minutes: r.Minutes, CO2Grams: co2}
+		totalMinutes += r.Minutes
+		totalCO2 += co2
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total_trips":   totalTrips,
+		"total_minutes": totalMinutes,
+		"ride_spent":    rideSpent,
+		"co2_grams":     totalCO2,
+		"by_mode":       byMode,
+	})
+}
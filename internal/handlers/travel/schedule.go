@@ -0,0 +1,127 @@
+package travel
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"navmate-backend/internal/models"
+)
+
+type scheduleReq struct {
+	Origin      string   `json:"origin" binding:"required"`
+	Destination string   `json:"destination" binding:"required"`
+	Weekdays    []string `json:"weekdays" binding:"required"`    // ["mon","tue",...]
+	DepartTime  string   `json:"depart_time" binding:"required"` // HH:MM
+	Timezone    string   `json:"timezone"`
+	LeadMinutes int      `json:"lead_minutes"`
+	AutoSelect  string   `json:"auto_select"` // ""|fastest|cheapest
+	Active      *bool    `json:"active"`      // false pauses the schedule; unchanged when omitted
+}
+
+// toSchedule validates the request and fills a schedule, returning a user-facing error message.
+func (r scheduleReq) toSchedule(s *models.TripSchedule) string {
+	if r.Timezone == "" {
+		r.Timezone = "Asia/Bangkok"
+	}
+	if r.LeadMinutes <= 0 {
+		r.LeadMinutes = 60
+	}
+	switch r.AutoSelect {
+	case "", "fastest", "cheapest":
+	default:
+		return "auto_select must be fastest or cheapest"
+	}
+
+	s.Origin = r.Origin
+	s.Destination = r.Destination
+	s.Weekdays = strings.ToLower(strings.Join(r.Weekdays, ","))
+	s.DepartTime = r.DepartTime
+	s.Timezone = r.Timezone
+	s.LeadMinutes = r.LeadMinutes
+	s.AutoSelect = r.AutoSelect
+	if r.Active != nil {
+		s.Active = *r.Active
+	}
+
+	// NextDeparture checks weekdays, time and timezone in one go
+	if _, err := s.NextDeparture(time.Now()); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// POST /v1/trips/schedules
+func (h *Handler) CreateSchedule(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	var req scheduleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s := models.TripSchedule{UserID: uid, Active: true}
+	if msg := req.toSchedule(&s); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if err := h.db.Create(&s).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create schedule failed"})
+		return
+	}
+	next, _ := s.NextDeparture(time.Now())
+	c.JSON(http.StatusCreated, gin.H{"schedule": s, "next_departure": next})
+}
+
+// GET /v1/trips/schedules
+func (h *Handler) ListSchedules(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	var list []models.TripSchedule
+	if err := h.db.Where("user_id = ?", uid).Order("id ASC").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list schedules failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": list})
+}
+
+// PUT /v1/trips/schedules/:id
+func (h *Handler) UpdateSchedule(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	var s models.TripSchedule
+	if err := h.db.Where("id = ? AND user_id = ?", c.Param("id"), uid).First(&s).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+		return
+	}
+	var req scheduleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := req.toSchedule(&s); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if err := h.db.Save(&s).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update schedule failed"})
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+// DELETE /v1/trips/schedules/:id
+// Plans already created from the schedule are kept.
+func (h *Handler) DeleteSchedule(c *gin.Context) {
+	uid := uint(c.GetInt("user_id"))
+	res := h.db.Where("id = ? AND user_id = ?", c.Param("id"), uid).Delete(&models.TripSchedule{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete schedule failed"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
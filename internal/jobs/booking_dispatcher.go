@@ -0,0 +1,95 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/notify"
+	"navmate-backend/internal/repository"
+)
+
+// Dispatcher books a due advance booking with its provider (booking.Handler).
+type Dispatcher interface {
+	Dispatch(ctx context.Context, b *models.RideBooking) error
+}
+
+// BookingDispatcher sends scheduled bookings to their provider once pickup is
+// within the dispatch lead, and tells the user when that fails.
+type BookingDispatcher struct {
+	db         *gorm.DB
+	dispatcher Dispatcher
+	notifier   notify.Notifier
+	log        *zap.SugaredLogger
+	lead       time.Duration
+	interval   time.Duration
+}
+
+func NewBookingDispatcher(db *gorm.DB, d Dispatcher, n notify.Notifier, cfg *config.Config, log *zap.SugaredLogger) *BookingDispatcher {
+	return &BookingDispatcher{
+		db:         db,
+		dispatcher: d,
+		notifier:   n,
+		log:        log,
+		lead:       cfg.Booking.DispatchLead,
+		interval:   cfg.Booking.DispatchInterval,
+	}
+}
+
+// Run blocks until ctx is cancelled.
+func (d *BookingDispatcher) Run(ctx context.Context) {
+	t := time.NewTicker(d.interval)
+	defer t.Stop()
+	for {
+		d.tick(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (d *BookingDispatcher) tick(ctx context.Context, now time.Time) {
+	var due []models.RideBooking
+	if err := d.db.WithContext(ctx).
+		Where("status = ? AND pickup_at <= ?", models.BookingScheduled, now.Add(d.lead)).
+		Order("pickup_at ASC").
+		Find(&due).Error; err != nil {
+		d.log.Warnw("booking dispatcher: load due bookings", "err", err)
+		return
+	}
+
+	for i := range due {
+		b := &due[i]
+		err := d.dispatcher.Dispatch(ctx, b)
+		if errors.Is(err, repository.ErrStaleBooking) {
+			continue // cancelled, moved or taken by another instance meanwhile
+		}
+		if err == nil && b.Status != models.BookingFailed && b.Status != models.BookingCancelled {
+			continue
+		}
+
+		reason := "provider could not book the ride"
+		switch {
+		case err != nil:
+			d.log.Warnw("booking dispatcher: dispatch", "booking_id", b.ID, "err", err)
+			reason = err.Error()
+		case b.Status == models.BookingCancelled:
+			reason = b.CancelReason // another leg of the itinerary failed
+		}
+		var userID uint
+		if err := d.db.WithContext(ctx).Model(&models.TripPlan{}).Where("id = ?", b.PlanID).
+			Pluck("user_id", &userID).Error; err != nil || userID == 0 {
+			continue
+		}
+		_ = d.notifier.Notify(ctx, userID, notify.KindDispatchFailed, map[string]any{
+			"booking_id": b.ID, "plan_id": b.PlanID, "pickup_at": b.PickupAt, "reason": reason,
+		})
+	}
+}
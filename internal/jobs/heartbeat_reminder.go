@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/notify"
+)
+
+// HeartbeatReminder nudges users whose safety heartbeat has come due and not
+// been acknowledged yet. Each heartbeat is reminded once per process.
+type HeartbeatReminder struct {
+	db       *gorm.DB
+	notifier notify.Notifier
+	log      *zap.SugaredLogger
+	interval time.Duration
+
+	reminded map[uint]time.Time // heartbeat id -> due time
+}
+
+func NewHeartbeatReminder(db *gorm.DB, n notify.Notifier, cfg *config.Config, log *zap.SugaredLogger) *HeartbeatReminder {
+	return &HeartbeatReminder{
+		db:       db,
+		notifier: n,
+		log:      log,
+		interval: cfg.Safety.ReminderInterval,
+		reminded: map[uint]time.Time{},
+	}
+}
+
+// Run blocks until ctx is cancelled.
+func (r *HeartbeatReminder) Run(ctx context.Context) {
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+	for {
+		r.tick(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+type dueHeartbeat struct {
+	HeartbeatID uint
+	SessionID   uint
+	PlanID      uint
+	UserID      uint
+	DueAt       time.Time
+}
+
+func (r *HeartbeatReminder) tick(ctx context.Context, now time.Time) {
+	var due []dueHeartbeat
+	err := r.db.WithContext(ctx).Table("heartbeats").
+		Select("heartbeats.id AS heartbeat_id, heartbeats.session_id, safety_sessions.plan_id, trip_plans.user_id, heartbeats.due_at").
+		Joins("JOIN safety_sessions ON safety_sessions.id = heartbeats.session_id").
+		Joins("JOIN trip_plans ON trip_plans.id = safety_sessions.plan_id").
+		Where("heartbeats.status = ? AND heartbeats.due_at <= ? AND safety_sessions.active = true", "due", now).
+		Scan(&due).Error
+	if err != nil {
+		r.log.Warnw("heartbeat reminder: load due heartbeats", "err", err)
+		return
+	}
+
+	for _, hb := range due {
+		if _, ok := r.reminded[hb.HeartbeatID]; ok {
+			continue
+		}
+		r.reminded[hb.HeartbeatID] = hb.DueAt
+		_ = r.notifier.Notify(ctx, hb.UserID, notify.KindHeartbeatDue, map[string]any{
+			"session_id": hb.SessionID, "plan_id": hb.PlanID, "due_at": hb.DueAt,
+		})
+	}
+
+	for id, dueAt := range r.reminded {
+		if now.Sub(dueAt) > 24*time.Hour {
+			delete(r.reminded, id)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/adapters/payment"
+	"navmate-backend/internal/events"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/repository"
+)
+
+// PaymentReconciler settles authorizations nobody else will: it captures
+// completed rides, voids the holds of bookings that were cancelled, declined
+// or failed (capturing the cancellation fee if one is due), and releases
+// holds older than the gateway keeps them.
+type PaymentReconciler struct {
+	db       *gorm.DB
+	gateway  payment.PaymentGateway
+	payments *repository.PaymentRepository
+	bookings *repository.BookingRepository
+	log      *zap.SugaredLogger
+	hold     time.Duration
+	interval time.Duration
+	after    uint // booking id the last batch ended at
+}
+
+func NewPaymentReconciler(db *gorm.DB, gateway payment.PaymentGateway, pub events.Publisher, cfg *config.Config, log *zap.SugaredLogger) *PaymentReconciler {
+	return &PaymentReconciler{
+		db:       db,
+		gateway:  gateway,
+		payments: repository.NewPaymentRepository(db, pub),
+		bookings: repository.NewBookingRepository(db, pub),
+		log:      log,
+		hold:     cfg.Payment.AuthHold,
+		interval: cfg.Payment.ReconcileInterval,
+	}
+}
+
+// Run blocks until ctx is cancelled.
+func (r *PaymentReconciler) Run(ctx context.Context) {
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+	for {
+		r.Tick(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+const reconcileBatch = 100
+
+// Tick reconciles a batch of open holds that need it as of now: the booking
+// ended, or the hold is older than the gateway keeps it. Batches continue
+// after the last booking id, so holds the gateway keeps refusing don't hold
+// up the rest.
+func (r *PaymentReconciler) Tick(ctx context.Context, now time.Time) {
+	// payment_status mirrors the payment, so open holds are found from the booking side
+	var list []models.RideBooking
+	err := r.db.WithContext(ctx).Select("ride_bookings.*").
+		Joins("JOIN payments ON payments.id = ride_bookings.payment_id").
+		Where("ride_bookings.payment_status IN ?", []string{payment.StatusAuthorized, payment.StatusPending}).
+		Where("((ride_bookings.status = ? AND ride_bookings.payment_status = ?) OR ride_bookings.status IN ? OR payments.created_at < ?)",
+			models.BookingCompleted, payment.StatusAuthorized,
+			[]string{models.BookingCancelled, models.BookingDeclined, models.BookingFailed}, now.Add(-r.hold)).
+		Where("ride_bookings.id > ?", r.after).
+		Order("ride_bookings.id ASC").Limit(reconcileBatch).Find(&list).Error
+	if err != nil {
+		r.log.Warnw("payment reconciler: load bookings", "err", err)
+		return
+	}
+	r.after = 0
+	if len(list) == reconcileBatch {
+		r.after = list[len(list)-1].ID
+	}
+	for i := range list {
+		r.reconcile(ctx, &list[i], now)
+	}
+}
+
+func (r *PaymentReconciler) reconcile(ctx context.Context, b *models.RideBooking, now time.Time) {
+	var p models.Payment
+	if err := r.db.WithContext(ctx).First(&p, *b.PaymentID).Error; err != nil {
+		r.log.Warnw("payment reconciler: load payment", "booking_id", b.ID, "err", err)
+		return
+	}
+	if p.Status != payment.StatusAuthorized && p.Status != payment.StatusPending {
+		return
+	}
+	if p.Status == payment.StatusPending && now.Sub(p.UpdatedAt) < time.Minute {
+		return // the Authorize request that created it may still be waiting for the gateway
+	}
+
+	var (
+		action string
+		ch     payment.Charge
+		err    error
+	)
+	switch {
+	case b.Status == models.BookingCompleted && p.Status == payment.StatusAuthorized:
+		action = "ride completed, captured"
+		ch, err = r.gateway.Capture(ctx, p.ExternalRef, p.AmountCents)
+	case models.IsTerminal(b.Status) && b.Status != models.BookingCompleted:
+		if fee := min(b.CancellationFeeCents, p.AmountCents); fee > 0 && p.Status == payment.StatusAuthorized {
+			action = fmt.Sprintf("booking %s, captured the cancellation fee", b.Status)
+			ch, err = r.gateway.Capture(ctx, p.ExternalRef, fee)
+		} else {
+			action = fmt.Sprintf("booking %s, voided", b.Status)
+			ch, err = r.gateway.Void(ctx, p.ExternalRef)
+		}
+	case now.Sub(p.CreatedAt) > r.hold:
+		action = "authorization expired, voided"
+		ch, err = r.gateway.Void(ctx, p.ExternalRef)
+		if err == nil {
+			ch.Status = payment.StatusExpired
+		}
+	default:
+		return
+	}
+	if err != nil {
+		// e.g. the gateway already expired the hold: take its word for the state
+		var serr error
+		if ch, serr = r.gateway.Status(ctx, p.ExternalRef); serr != nil || ch.Status == p.Status {
+			r.log.Warnw("payment reconciler: gateway", "payment_id", p.ID, "booking_id", b.ID, "action", action, "err", err)
+			return
+		}
+		action = "gateway reports " + ch.Status
+	}
+
+	note := "reconciliation: " + action
+	if err := r.payments.ApplyCharge(ctx, &p, ch, repository.LedgerMeta{Actor: "system", Note: note}); err != nil {
+		r.log.Warnw("payment reconciler: save payment", "payment_id", p.ID, "err", err)
+		return
+	}
+	_ = r.bookings.AddNote(ctx, b, "system", fmt.Sprintf("payment %s (%s)", p.Status, note))
+	r.log.Infow("payment reconciler", "payment_id", p.ID, "booking_id", b.ID, "action", action, "status", p.Status)
+}
@@ -0,0 +1,136 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/adapters/payment"
+	"navmate-backend/internal/events"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/repository"
+)
+
+// webhookStuckAfter is how long an event may stay in processing before
+// another tick picks it up again (the worker died halfway).
+const webhookStuckAfter = 5 * time.Minute
+
+// PaymentWebhookProcessor applies stored gateway webhooks to payments and
+// their bookings. Charge events are not trusted as-is: the charge is re-read
+// from the gateway, so events arriving out of order still end in the right state.
+type PaymentWebhookProcessor struct {
+	db       *gorm.DB
+	gateway  payment.PaymentGateway
+	payments *repository.PaymentRepository
+	bookings *repository.BookingRepository
+	log      *zap.SugaredLogger
+	interval time.Duration
+}
+
+func NewPaymentWebhookProcessor(db *gorm.DB, gateway payment.PaymentGateway, pub events.Publisher, cfg *config.Config, log *zap.SugaredLogger) *PaymentWebhookProcessor {
+	return &PaymentWebhookProcessor{
+		db:       db,
+		gateway:  gateway,
+		payments: repository.NewPaymentRepository(db, pub),
+		bookings: repository.NewBookingRepository(db, pub),
+		log:      log,
+		interval: cfg.Payment.WebhookInterval,
+	}
+}
+
+// Run blocks until ctx is cancelled.
+func (w *PaymentWebhookProcessor) Run(ctx context.Context) {
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+	for {
+		w.tick(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (w *PaymentWebhookProcessor) tick(ctx context.Context) {
+	list, err := w.payments.PendingWebhooks(ctx, webhookStuckAfter, 100)
+	if err != nil {
+		w.log.Warnw("payment webhooks: load events", "err", err)
+		return
+	}
+	for i := range list {
+		ev := &list[i]
+		if err := w.payments.ClaimWebhook(ctx, ev); err != nil {
+			if !errors.Is(err, repository.ErrWebhookNotClaimed) {
+				w.log.Warnw("payment webhooks: claim", "event_id", ev.EventID, "err", err)
+			}
+			continue
+		}
+		status, err := w.process(ctx, ev)
+		if err != nil {
+			status = models.WebhookFailed
+			w.log.Warnw("payment webhooks: process", "event_id", ev.EventID, "type", ev.Type, "attempt", ev.Attempts, "err", err)
+		}
+		if err := w.payments.FinishWebhook(ctx, ev, status, err); err != nil {
+			w.log.Warnw("payment webhooks: save outcome", "event_id", ev.EventID, "err", err)
+		}
+	}
+}
+
+// process returns processed, or ignored for events about charges we don't
+// know (e.g. made from the gateway's dashboard).
+func (w *PaymentWebhookProcessor) process(ctx context.Context, ev *models.PaymentWebhookEvent) (string, error) {
+	if ev.ChargeRef == "" {
+		return models.WebhookIgnored, nil
+	}
+	p, err := w.payments.ByExternalRef(ctx, ev.Gateway, ev.ChargeRef)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.WebhookIgnored, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	before := p.Status
+	switch ev.Type {
+	case "dispute.create", "dispute.update":
+		// the charge itself still reads as captured while the dispute is open
+		if before == payment.StatusDisputed {
+			return models.WebhookProcessed, nil
+		}
+		p.Status = payment.StatusDisputed
+		if err := w.payments.Save(ctx, &p); err != nil {
+			return "", err
+		}
+	default:
+		ch, err := w.gateway.Status(ctx, p.ExternalRef)
+		if err != nil {
+			return "", fmt.Errorf("gateway status: %w", err)
+		}
+		if ev.Type == "dispute.close" && p.Status == payment.StatusDisputed {
+			p.Status = ch.Status // a lost dispute shows up as a refund in the ledger
+		}
+		if err := w.payments.ApplyCharge(ctx, &p, ch, repository.LedgerMeta{Actor: "gateway", Note: ev.Type}); err != nil {
+			return "", err
+		}
+	}
+	if p.Status == before {
+		return models.WebhookProcessed, nil
+	}
+
+	var bs []models.RideBooking
+	if err := w.db.WithContext(ctx).Where("payment_id = ?", p.ID).Find(&bs).Error; err != nil {
+		return "", err
+	}
+	note := fmt.Sprintf("payment %s (%s)", strings.ReplaceAll(p.Status, "_", " "), ev.Type)
+	for i := range bs {
+		_ = w.bookings.AddNote(ctx, &bs[i], "system", note)
+	}
+	return models.WebhookProcessed, nil
+}
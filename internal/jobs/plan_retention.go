@@ -0,0 +1,92 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/models"
+)
+
+// PlanRetention cleans up plans the user never selected an itinerary for.
+// In "archive" mode the plan row is kept with status=archived and only its
+// itineraries/legs are removed; in "delete" mode the whole plan goes.
+type PlanRetention struct {
+	db       *gorm.DB
+	log      *zap.SugaredLogger
+	maxAge   time.Duration
+	mode     string
+	interval time.Duration
+}
+
+func NewPlanRetention(db *gorm.DB, cfg *config.Config, log *zap.SugaredLogger) *PlanRetention {
+	return &PlanRetention{
+		db:       db,
+		log:      log,
+		maxAge:   cfg.Trips.PlanRetention,
+		mode:     cfg.Trips.RetentionMode,
+		interval: cfg.Trips.RetentionInterval,
+	}
+}
+
+// Run blocks until ctx is cancelled.
+func (r *PlanRetention) Run(ctx context.Context) {
+	if r.maxAge <= 0 {
+		r.log.Infow("plan retention disabled")
+		return
+	}
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+	for {
+		if n, err := r.Sweep(ctx, time.Now()); err != nil {
+			r.log.Warnw("plan retention: sweep", "err", err)
+		} else if n > 0 {
+			r.log.Infow("plan retention: swept plans", "count", n, "mode", r.mode)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Sweep cleans up one batch of plans older than the retention period as of
+// now and returns how many it took.
+func (r *PlanRetention) Sweep(ctx context.Context, now time.Time) (int, error) {
+	var ids []uint
+	// plans referenced by a safety session are kept for the share link, and
+	// plans with bookings for the bookings and the payments made for them
+	if err := r.db.WithContext(ctx).Model(&models.TripPlan{}).
+		Where("status = ? AND selected_itinerary_id IS NULL AND created_at < ?", "planned", now.Add(-r.maxAge)).
+		Where("NOT EXISTS (SELECT 1 FROM safety_sessions WHERE safety_sessions.plan_id = trip_plans.id)").
+		Where("NOT EXISTS (SELECT 1 FROM ride_bookings WHERE ride_bookings.plan_id = trip_plans.id)").
+		Limit(500).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		itinIDs := tx.Model(&models.Itinerary{}).Select("id").Where("plan_id IN ?", ids)
+		if err := tx.Where("itinerary_id IN (?)", itinIDs).Delete(&models.Leg{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("plan_id IN ?", ids).Delete(&models.Itinerary{}).Error; err != nil {
+			return err
+		}
+		if r.mode == "delete" {
+			return tx.Where("id IN ?", ids).Delete(&models.TripPlan{}).Error
+		}
+		return tx.Model(&models.TripPlan{}).Where("id IN ?", ids).Update("status", "archived").Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/adapters/mail"
+	"navmate-backend/internal/receipts"
+	"navmate-backend/internal/repository"
+)
+
+// ReceiptIssuer issues receipts for payments once they are captured, by
+// whichever path (user, reconciler or webhook), and emails them to users who
+// asked for it.
+type ReceiptIssuer struct {
+	receipts *repository.ReceiptRepository
+	issuer   *receipts.Issuer
+	log      *zap.SugaredLogger
+	interval time.Duration
+}
+
+func NewReceiptIssuer(db *gorm.DB, sender mail.Sender, cfg *config.Config, log *zap.SugaredLogger) *ReceiptIssuer {
+	return &ReceiptIssuer{
+		receipts: repository.NewReceiptRepository(db),
+		issuer:   receipts.NewIssuer(db, sender, cfg),
+		log:      log,
+		interval: cfg.Receipt.Interval,
+	}
+}
+
+// Run blocks until ctx is cancelled.
+func (r *ReceiptIssuer) Run(ctx context.Context) {
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+	for {
+		r.tick(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (r *ReceiptIssuer) tick(ctx context.Context) {
+	list, err := r.receipts.Unissued(ctx, 100)
+	if err != nil {
+		r.log.Warnw("receipt issuer: load payments", "err", err)
+		return
+	}
+	for _, p := range list {
+		rec, created, err := r.issuer.Issue(ctx, p)
+		if err != nil {
+			r.log.Warnw("receipt issuer: issue", "payment_id", p.ID, "err", err)
+			continue
+		}
+		if !created {
+			continue
+		}
+		if err := r.issuer.EmailIfWanted(ctx, &rec); err != nil {
+			r.log.Warnw("receipt issuer: email", "receipt", rec.Number, "err", err)
+		}
+	}
+}
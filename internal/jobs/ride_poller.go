@@ -0,0 +1,76 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/adapters/ride"
+	"navmate-backend/internal/events"
+	"navmate-backend/internal/repository"
+)
+
+// RidePoller asks providers for the status of active bookings, for providers
+// that don't send webhooks (or when one gets lost).
+type RidePoller struct {
+	bookings *repository.BookingRepository
+	rides    *ride.Registry
+	log      *zap.SugaredLogger
+	interval time.Duration
+}
+
+func NewRidePoller(db *gorm.DB, rides *ride.Registry, pub events.Publisher, cfg *config.Config, log *zap.SugaredLogger) *RidePoller {
+	return &RidePoller{
+		bookings: repository.NewBookingRepository(db, pub),
+		rides:    rides,
+		log:      log,
+		interval: cfg.Ride.PollInterval,
+	}
+}
+
+// Run blocks until ctx is cancelled.
+func (p *RidePoller) Run(ctx context.Context) {
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+	for {
+		p.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (p *RidePoller) poll(ctx context.Context) {
+	list, err := p.bookings.Active(ctx)
+	if err != nil {
+		p.log.Warnw("ride poller: load bookings", "err", err)
+		return
+	}
+	for i := range list {
+		b := &list[i]
+		provider, ok := p.rides.Get(b.Provider)
+		if !ok {
+			continue
+		}
+		u, err := provider.Status(ctx, b.ExternalRef)
+		if err != nil {
+			p.log.Warnw("ride poller: provider status", "booking_id", b.ID, "err", err)
+			continue
+		}
+		err = p.bookings.ApplyProviderUpdate(ctx, b, u)
+		switch {
+		case errors.Is(err, repository.ErrInvalidTransition):
+			p.log.Infow("ride poller: ignored status", "booking_id", b.ID, "from", b.Status, "to", u.Status)
+		case errors.Is(err, repository.ErrStaleBooking):
+			// changed by a webhook or the user meanwhile; next tick sees the new state
+		case err != nil:
+			p.log.Warnw("ride poller: update booking", "booking_id", b.ID, "err", err)
+		}
+	}
+}
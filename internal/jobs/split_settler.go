@@ -0,0 +1,337 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/adapters/payment"
+	"navmate-backend/internal/events"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/repository"
+)
+
+// SplitSettler moves split fares along. Once every share is answered or the
+// deadline passes, the split is funded (the owner's saved card covering the
+// unpaid shares if they chose so) or fails and its shares are voided. A
+// funded split is captured share by share when the ride is completed; when
+// the booking ends without a ride each share pays its part of the
+// cancellation fee and the rest is released.
+type SplitSettler struct {
+	db       *gorm.DB
+	gateway  payment.PaymentGateway
+	payments *repository.PaymentRepository
+	splits   *repository.SplitRepository
+	methods  *repository.PaymentMethodRepository
+	bookings *repository.BookingRepository
+	log      *zap.SugaredLogger
+	hold     time.Duration
+	interval time.Duration
+}
+
+func NewSplitSettler(db *gorm.DB, gateway payment.PaymentGateway, pub events.Publisher, cfg *config.Config, log *zap.SugaredLogger) *SplitSettler {
+	return &SplitSettler{
+		db:       db,
+		gateway:  gateway,
+		payments: repository.NewPaymentRepository(db, pub),
+		splits:   repository.NewSplitRepository(db, pub),
+		methods:  repository.NewPaymentMethodRepository(db),
+		bookings: repository.NewBookingRepository(db, pub),
+		log:      log,
+		hold:     cfg.Payment.AuthHold,
+		interval: cfg.Payment.ReconcileInterval,
+	}
+}
+
+// Run blocks until ctx is cancelled.
+func (r *SplitSettler) Run(ctx context.Context) {
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+	for {
+		r.tick(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (r *SplitSettler) tick(ctx context.Context, now time.Time) {
+	list, err := r.splits.Pending(ctx, 100)
+	if err != nil {
+		r.log.Warnw("split settler: load splits", "err", err)
+		return
+	}
+	for i := range list {
+		r.settle(ctx, &list[i], now)
+	}
+}
+
+func (r *SplitSettler) settle(ctx context.Context, s *models.FareSplit, now time.Time) {
+	var b models.RideBooking
+	if err := r.db.WithContext(ctx).First(&b, s.BookingID).Error; err != nil {
+		r.log.Warnw("split settler: load booking", "split_id", s.ID, "err", err)
+		return
+	}
+	shares, err := r.splits.Shares(ctx, s.ID)
+	if err != nil {
+		r.log.Warnw("split settler: load shares", "split_id", s.ID, "err", err)
+		return
+	}
+	unridden := models.IsTerminal(b.Status) && b.Status != models.BookingCompleted
+	live := s.Status == models.SplitOpen || s.Status == models.SplitFunded
+
+	switch {
+	case live && unridden:
+		if r.setStatus(ctx, s, &b, models.SplitCancelled, "booking "+b.Status) {
+			r.release(ctx, s, shares, &b, false)
+		}
+	case s.Status == models.SplitOpen:
+		if now.Before(s.ExpiresAt) && waiting(shares) {
+			return
+		}
+		r.resolve(ctx, s, shares, &b)
+	case s.Status == models.SplitFunded && b.Status == models.BookingCompleted:
+		r.capture(ctx, s, shares, &b)
+	case s.Status == models.SplitFunded:
+		var since *time.Time
+		if err := r.db.WithContext(ctx).Model(&models.Payment{}).
+			Where("id IN (SELECT payment_id FROM split_shares WHERE split_id = ? AND payment_status = ?)", s.ID, payment.StatusAuthorized).
+			Select("MIN(created_at)").Scan(&since).Error; err != nil || since == nil || now.Sub(*since) <= r.hold {
+			return
+		}
+		if r.setStatus(ctx, s, &b, models.SplitFailed, "authorizations expired") {
+			r.release(ctx, s, shares, &b, true)
+		}
+	default:
+		// failed or cancelled with money still held, e.g. a void that failed last time
+		r.release(ctx, s, shares, &b, false)
+	}
+}
+
+// waiting reports whether a share can still be paid before the deadline.
+func waiting(shares []models.SplitShare) bool {
+	for _, sh := range shares {
+		if sh.Role != models.ShareCover && sh.Open() {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve decides an open split once nobody is left to pay or time is up.
+func (r *SplitSettler) resolve(ctx context.Context, s *models.FareSplit, shares []models.SplitShare, b *models.RideBooking) {
+	// payments still waiting for their user (e.g. 3-D Secure) are too late now
+	for i := range shares {
+		if shares[i].PaymentStatus == payment.StatusPending {
+			r.settleShare(ctx, s, &shares[i], 0, "split deadline passed", false)
+		}
+	}
+	if err := r.splits.ExpireInvited(ctx, s); err != nil {
+		r.log.Warnw("split settler: expire shares", "split_id", s.ID, "err", err)
+		return
+	}
+
+	unpaid := 0
+	for _, sh := range shares {
+		if sh.Role != models.ShareCover && !sh.Paid() {
+			unpaid += sh.AmountCents
+		}
+	}
+	switch {
+	case unpaid == 0:
+		r.setStatus(ctx, s, b, models.SplitFunded, "")
+	case s.OnDecline == models.SplitOwnerCovers:
+		if err := r.cover(ctx, s, shares, unpaid); err != nil {
+			if r.setStatus(ctx, s, b, models.SplitFailed, "the owner's card could not cover the unpaid shares: "+err.Error()) {
+				r.release(ctx, s, shares, b, false)
+			}
+			return
+		}
+		r.setStatus(ctx, s, b, models.SplitFunded, fmt.Sprintf("the owner covered %d unpaid", unpaid))
+	default:
+		if r.setStatus(ctx, s, b, models.SplitFailed, "shares were declined or not paid in time") {
+			r.release(ctx, s, shares, b, false)
+		}
+	}
+}
+
+// cover authorizes the unpaid shares on the owner's saved card: the one
+// their share was paid with, else their default. Cards are the only methods
+// that can be charged without the owner at hand.
+func (r *SplitSettler) cover(ctx context.Context, s *models.FareSplit, shares []models.SplitShare, unpaid int) error {
+	var m models.PaymentMethod
+	found := false
+	for _, sh := range shares {
+		if sh.Role == models.ShareOwner && sh.PaymentID != nil {
+			var p models.Payment
+			if err := r.db.WithContext(ctx).First(&p, *sh.PaymentID).Error; err == nil && p.PaymentMethodID != nil {
+				if pm, err := r.methods.Get(ctx, s.OwnerID, *p.PaymentMethodID); err == nil && pm.Type == models.PaymentMethodCard {
+					m, found = pm, true
+				}
+			}
+		}
+	}
+	if !found {
+		def, ok, err := r.methods.Default(ctx, s.OwnerID)
+		if err != nil {
+			return err
+		}
+		if !ok || def.Type != models.PaymentMethodCard {
+			return errors.New("no saved card")
+		}
+		m = def
+	}
+	if m.Gateway != r.gateway.Name() {
+		return errors.New("card saved with another payment gateway")
+	}
+
+	now := time.Now()
+	pay := models.Payment{
+		Status: payment.StatusPending, Currency: s.Currency, AmountCents: unpaid, FareCents: unpaid,
+		Gateway: r.gateway.Name(), PaymentMethodID: &m.ID,
+	}
+	share := models.SplitShare{
+		SplitID: s.ID, UserID: s.OwnerID, Role: models.ShareCover,
+		AmountCents: unpaid, Status: models.ShareAccepted, RespondedAt: &now,
+	}
+	if err := r.splits.AddCover(ctx, &share, &pay); err != nil {
+		return err
+	}
+	ch, err := r.gateway.Authorize(ctx, payment.AuthRequest{
+		AmountCents: unpaid, Currency: s.Currency, Customer: m.GatewayRef,
+		Description:    fmt.Sprintf("NavMate booking %d, split %d: unpaid shares", s.BookingID, s.ID),
+		IdempotencyKey: fmt.Sprintf("payment-%d", pay.ID),
+	})
+	if err != nil {
+		ch = payment.Charge{Status: payment.StatusDeclined, FailureCode: "gateway_error"}
+	}
+	if ch.ExternalRef != "" {
+		pay.ExternalRef = ch.ExternalRef
+	}
+	if err := r.payments.ApplyCharge(ctx, &pay, ch, repository.LedgerMeta{Actor: "system", Note: "split: the owner covers unpaid shares"}); err != nil {
+		return err
+	}
+	if pay.Status != payment.StatusAuthorized {
+		return fmt.Errorf("payment %s %s", pay.Status, pay.FailureCode)
+	}
+	return nil
+}
+
+// capture takes every share's hold once the ride is completed.
+func (r *SplitSettler) capture(ctx context.Context, s *models.FareSplit, shares []models.SplitShare, b *models.RideBooking) {
+	done := true
+	for i := range shares {
+		if shares[i].PaymentStatus != payment.StatusAuthorized {
+			continue
+		}
+		if !r.settleShare(ctx, s, &shares[i], shares[i].AmountCents, "ride completed, captured", false) {
+			done = false
+		}
+	}
+	if done {
+		r.setStatus(ctx, s, b, models.SplitSettled, "")
+	}
+}
+
+// release voids every hold. If the booking ended without a ride its
+// cancellation fee is captured instead, shared in proportion to what each
+// share paid.
+func (r *SplitSettler) release(ctx context.Context, s *models.FareSplit, shares []models.SplitShare, b *models.RideBooking, expire bool) {
+	fee := 0
+	if models.IsTerminal(b.Status) && b.Status != models.BookingCompleted {
+		fee = b.CancellationFeeCents
+	}
+	var (
+		held    []int
+		weights []int
+	)
+	for i, sh := range shares {
+		if sh.Paid() {
+			held = append(held, i)
+			weights = append(weights, sh.AmountCents)
+		}
+	}
+	sum := 0
+	for _, w := range weights {
+		sum += w
+	}
+	parts := models.Allocate(min(fee, sum), weights)
+
+	for j, i := range held {
+		if shares[i].PaymentStatus != payment.StatusAuthorized {
+			continue
+		}
+		note := fmt.Sprintf("split %s, voided", s.Status)
+		if parts[j] > 0 {
+			note = fmt.Sprintf("split %s, captured its part of the cancellation fee", s.Status)
+		}
+		r.settleShare(ctx, s, &shares[i], parts[j], note, expire)
+	}
+	for i := range shares {
+		if shares[i].PaymentStatus == payment.StatusPending {
+			r.settleShare(ctx, s, &shares[i], 0, fmt.Sprintf("split %s, voided", s.Status), expire)
+		}
+	}
+}
+
+// settleShare captures amount of the share's payment, or voids it when
+// amount is 0, and writes the outcome to its ledger. It reports whether the
+// payment moved.
+func (r *SplitSettler) settleShare(ctx context.Context, s *models.FareSplit, sh *models.SplitShare, amount int, action string, expire bool) bool {
+	var p models.Payment
+	if err := r.db.WithContext(ctx).First(&p, *sh.PaymentID).Error; err != nil {
+		r.log.Warnw("split settler: load payment", "split_id", s.ID, "share_id", sh.ID, "err", err)
+		return false
+	}
+	var (
+		ch  payment.Charge
+		err error
+	)
+	if amount > 0 {
+		ch, err = r.gateway.Capture(ctx, p.ExternalRef, amount)
+	} else {
+		ch, err = r.gateway.Void(ctx, p.ExternalRef)
+		if err == nil && expire {
+			ch.Status = payment.StatusExpired
+		}
+	}
+	if err != nil {
+		// take the gateway's word for the state, as PaymentReconciler does
+		var serr error
+		if ch, serr = r.gateway.Status(ctx, p.ExternalRef); serr != nil || ch.Status == p.Status {
+			r.log.Warnw("split settler: gateway", "split_id", s.ID, "payment_id", p.ID, "action", action, "err", err)
+			return false
+		}
+		action = "gateway reports " + ch.Status
+	}
+
+	if err := r.payments.ApplyCharge(ctx, &p, ch, repository.LedgerMeta{Actor: "system", Note: "split settlement: " + action}); err != nil {
+		r.log.Warnw("split settler: save payment", "split_id", s.ID, "payment_id", p.ID, "err", err)
+		return false
+	}
+	sh.PaymentStatus = p.Status
+	r.log.Infow("split settler", "split_id", s.ID, "payment_id", p.ID, "action", action, "status", p.Status)
+	return true
+}
+
+// setStatus records the split's new status, also in the booking's history.
+func (r *SplitSettler) setStatus(ctx context.Context, s *models.FareSplit, b *models.RideBooking, status, note string) bool {
+	if err := r.splits.SetStatus(ctx, s, status, note); err != nil {
+		r.log.Warnw("split settler: save split", "split_id", s.ID, "status", status, "err", err)
+		return false
+	}
+	msg := fmt.Sprintf("fare split %d %s", s.ID, status)
+	if note != "" {
+		msg += ": " + note
+	}
+	_ = r.bookings.AddNote(ctx, b, "system", msg)
+	r.log.Infow("split settler", "split_id", s.ID, "booking_id", b.ID, "status", status, "note", note)
+	return true
+}
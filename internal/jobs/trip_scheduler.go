@@ -0,0 +1,221 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/adapters/fx"
+	"navmate-backend/internal/adapters/maps"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/notify"
+	"navmate-backend/internal/repository"
+)
+
+// TripScheduler turns active TripSchedules into TripPlans ahead of departure and
+// re-estimates upcoming scheduled plans so users hear about material delays.
+type TripScheduler struct {
+	db       *gorm.DB
+	trips    *repository.TripRepository
+	maps     *maps.GoogleMapsAdapter
+	prefs    *repository.PreferenceRepository
+	rates    fx.RateSource
+	notifier notify.Notifier
+	log      *zap.SugaredLogger
+
+	interval      time.Duration
+	recheck       time.Duration
+	changeMinutes int
+	itineraryTTL  time.Duration
+
+	lastChecked map[uint]time.Time // plan id -> last re-estimate
+}
+
+func NewTripScheduler(db *gorm.DB, m *maps.GoogleMapsAdapter, rates fx.RateSource, n notify.Notifier, cfg *config.Config, log *zap.SugaredLogger) *TripScheduler {
+	return &TripScheduler{
+		db:            db,
+		trips:         repository.NewTripRepository(db),
+		maps:          m,
+		prefs:         repository.NewPreferenceRepository(db),
+		rates:         rates,
+		notifier:      n,
+		log:           log,
+		interval:      cfg.Trips.ScheduleInterval,
+		recheck:       cfg.Trips.ScheduleRecheck,
+		changeMinutes: cfg.Trips.ScheduleChangeMinutes,
+		itineraryTTL:  cfg.Trips.ItineraryTTL,
+		lastChecked:   map[uint]time.Time{},
+	}
+}
+
+// Run blocks until ctx is cancelled.
+func (s *TripScheduler) Run(ctx context.Context) {
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+	for {
+		s.tick(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *TripScheduler) tick(ctx context.Context, now time.Time) {
+	var schedules []models.TripSchedule
+	if err := s.db.WithContext(ctx).Where("active = true").Find(&schedules).Error; err != nil {
+		s.log.Warnw("trip scheduler: load schedules", "err", err)
+		return
+	}
+	for _, sch := range schedules {
+		next, err := sch.NextDeparture(now)
+		if err != nil {
+			s.log.Warnw("trip scheduler: bad schedule", "schedule_id", sch.ID, "err", err)
+			continue
+		}
+		if next.Sub(now) > time.Duration(sch.LeadMinutes)*time.Minute {
+			continue
+		}
+		if sch.LastPlannedFor != nil && sch.LastPlannedFor.Equal(next) {
+			continue
+		}
+		if err := s.planAhead(ctx, sch, next); err != nil {
+			s.log.Warnw("trip scheduler: plan ahead", "schedule_id", sch.ID, "err", err)
+		}
+	}
+	s.recheckUpcoming(ctx, now)
+}
+
+func (s *TripScheduler) planAhead(ctx context.Context, sch models.TripSchedule, departAt time.Time) error {
+	// claim the departure first so overlapping ticks or other instances don't plan it twice
+	res := s.db.WithContext(ctx).Model(&models.TripSchedule{}).
+		Where("id = ? AND (last_planned_for IS NULL OR last_planned_for < ?)", sch.ID, departAt).
+		Update("last_planned_for", departAt)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil
+	}
+	if err := s.createPlan(ctx, sch, departAt); err != nil {
+		// give the slot back so the next tick retries it
+		if rerr := s.db.WithContext(ctx).Model(&models.TripSchedule{}).
+			Where("id = ? AND last_planned_for = ?", sch.ID, departAt).
+			Update("last_planned_for", sch.LastPlannedFor).Error; rerr != nil {
+			s.log.Warnw("trip scheduler: release slot", "schedule_id", sch.ID, "err", rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *TripScheduler) createPlan(ctx context.Context, sch models.TripSchedule, departAt time.Time) error {
+	opts := s.maps.EstimateItineraries(sch.Origin, sch.Destination, &departAt)
+
+	// scheduled plans are re-estimated until departure, so they stay valid until shortly after it
+	expiresAt := departAt.Add(s.itineraryTTL)
+	plan := repository.BuildPlan(sch.UserID, sch.Origin, sch.Destination, &departAt, opts, &expiresAt)
+	plan.ScheduleID = &sch.ID
+	if pref, err := s.prefs.Get(ctx, sch.UserID); err == nil {
+		if err := repository.LocalizePlan(ctx, s.rates, &plan, pref.Currency); err != nil {
+			s.log.Warnw("trip scheduler: convert fares", "schedule_id", sch.ID, "currency", pref.Currency, "err", err)
+		}
+	}
+	// the auto-selection is saved with the plan: a plan left unselected by a
+	// failure halfway would be planned again on the next tick
+	if pick := pickItinerary(plan.Itineraries, sch.AutoSelect); pick >= 0 {
+		if err := s.trips.CreateSelectedPlan(ctx, &plan, pick); err != nil {
+			return err
+		}
+	} else if err := s.trips.CreatePlan(ctx, &plan); err != nil {
+		return err
+	}
+
+	_ = s.notifier.Notify(ctx, sch.UserID, notify.KindTripPlanned, map[string]any{
+		"schedule_id": sch.ID, "plan_id": plan.ID, "depart_at": departAt,
+		"selected_itinerary_id": plan.SelectedItineraryID,
+	})
+	return nil
+}
+
+// pickItinerary applies the schedule's auto-select preference and returns the
+// index of the chosen itinerary; -1 means leave it to the user.
+func pickItinerary(itins []models.Itinerary, pref string) int {
+	best := -1
+	for i, it := range itins {
+		switch pref {
+		case "fastest":
+			if best < 0 || it.TotalMinutes < itins[best].TotalMinutes {
+				best = i
+			}
+		case "cheapest":
+			if best < 0 || it.RoughCostCents < itins[best].RoughCostCents {
+				best = i
+			}
+		default:
+			return -1
+		}
+	}
+	return best
+}
+
+// recheckUpcoming re-estimates scheduled plans that have not departed yet and
+// notifies the user when the chosen option's travel time moved materially.
+func (s *TripScheduler) recheckUpcoming(ctx context.Context, now time.Time) {
+	var plans []models.TripPlan
+	if err := s.db.WithContext(ctx).
+		Where("schedule_id IS NOT NULL AND status = ? AND depart_at > ?", "selected", now).
+		Find(&plans).Error; err != nil {
+		s.log.Warnw("trip scheduler: load upcoming plans", "err", err)
+		return
+	}
+
+	for _, p := range plans {
+		if last, ok := s.lastChecked[p.ID]; ok && now.Sub(last) < s.recheck {
+			continue
+		}
+		s.lastChecked[p.ID] = now
+
+		var it models.Itinerary
+		if err := s.db.WithContext(ctx).First(&it, *p.SelectedItineraryID).Error; err != nil {
+			continue
+		}
+		var fresh *maps.ItinOpt
+		for _, o := range s.maps.EstimateItineraries(p.Origin, p.Destination, p.DepartAt) {
+			if o.ModeMix == it.ModeMix {
+				o := o
+				fresh = &o
+				break
+			}
+		}
+		if fresh == nil {
+			continue
+		}
+
+		delta := fresh.TotalMinutes - it.TotalMinutes
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta < s.changeMinutes {
+			continue
+		}
+		_ = s.notifier.Notify(ctx, p.UserID, notify.KindTravelTimeChanged, map[string]any{
+			"plan_id": p.ID, "itinerary_id": it.ID,
+			"previous_minutes": it.TotalMinutes, "current_minutes": fresh.TotalMinutes,
+		})
+		if err := s.db.WithContext(ctx).Model(&it).Update("total_minutes", fresh.TotalMinutes).Error; err != nil {
+			s.log.Warnw("trip scheduler: update itinerary", "itinerary_id", it.ID, "err", err)
+		}
+	}
+
+	// forget plans that have left the window
+	for id, last := range s.lastChecked {
+		if now.Sub(last) > 24*time.Hour {
+			delete(s.lastChecked, id)
+		}
+	}
+}
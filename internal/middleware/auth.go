@@ -16,14 +16,46 @@ func AuthJWT(jwt *jwtauth.Service) gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing bearer token"})
 			return
 		}
-		token := strings.TrimSpace(h[len("Bearer "):])
-		claims, err := jwt.Parse(token)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		authenticate(c, jwt, strings.TrimSpace(h[len("Bearer "):]))
+	}
+}
+
+// AuthJWTStream also accepts ?access_token=, since browser EventSource cannot
+// send an Authorization header. Only use it on streaming endpoints.
+func AuthJWTStream(jwt *jwtauth.Service) gin.HandlerFunc {
+	header := AuthJWT(jwt)
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" && c.Query("access_token") != "" {
+			authenticate(c, jwt, c.Query("access_token"))
+			return
+		}
+		header(c)
+	}
+}
+
+func authenticate(c *gin.Context, jwt *jwtauth.Service, token string) {
+	claims, err := jwt.Parse(token)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return
+	}
+	c.Set("user_id", int(claims.UserID))
+	c.Set("email", claims.Email)
+	c.Next()
+}
+
+// RequireAdmin lets through users whose email is in emails (ADMIN_EMAILS).
+// Use it after AuthJWT.
+func RequireAdmin(emails []string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(emails))
+	for _, e := range emails {
+		allowed[strings.ToLower(e)] = true
+	}
+	return func(c *gin.Context) {
+		if !allowed[strings.ToLower(c.GetString("email"))] {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
 			return
 		}
-		c.Set("user_id", int(claims.UserID))
-		c.Set("email", claims.Email)
 		c.Next()
 	}
 }
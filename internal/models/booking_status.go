@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// RideBooking statuses
+const (
+	BookingScheduled         = "scheduled" // advance booking waiting for its dispatch time
+	BookingPending           = "pending"
+	BookingNeedsConfirmation = "needs_confirmation"
+	BookingConfirmed         = "confirmed"
+	BookingDriverAssigned    = "driver_assigned"
+	BookingInProgress        = "in_progress"
+	BookingCompleted         = "completed"
+	BookingCancelled         = "cancelled"
+	BookingDeclined          = "declined"
+	BookingFailed            = "failed"
+)
+
+// bookingTransitions is the booking state machine: status -> allowed next statuses.
+// completed, cancelled, declined and failed are terminal.
+var bookingTransitions = map[string][]string{
+	BookingScheduled:         {BookingPending, BookingCancelled},
+	BookingPending:           {BookingNeedsConfirmation, BookingConfirmed, BookingFailed, BookingCancelled},
+	BookingNeedsConfirmation: {BookingConfirmed, BookingDeclined, BookingCancelled, BookingFailed},
+	BookingConfirmed:         {BookingDriverAssigned, BookingInProgress, BookingCancelled, BookingFailed},
+	BookingDriverAssigned:    {BookingInProgress, BookingCancelled},
+	BookingInProgress:        {BookingCompleted, BookingCancelled},
+}
+
+// CanTransition reports whether a booking may move from one status to another.
+func CanTransition(from, to string) bool {
+	for _, s := range bookingTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal reports whether no further transitions are possible.
+func IsTerminal(status string) bool {
+	return len(bookingTransitions[status]) == 0
+}
+
+// BookingEvent = ประวัติการเปลี่ยนสถานะของการจอง (หนึ่งแถวต่อหนึ่ง transition)
+// Provider attempts that don't change the status are recorded with FromStatus == ToStatus.
+type BookingEvent struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	BookingID  uint      `gorm:"index;not null" json:"booking_id"`
+	FromStatus string    `gorm:"not null;default:''" json:"from_status"` // "" for the creation event
+	ToStatus   string    `gorm:"not null" json:"to_status"`
+	Actor      string    `gorm:"not null" json:"actor"` // user|system|provider
+	Note       string    `gorm:"not null;default:''" json:"note,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// PaymentTransaction kinds
+const (
+	TxAuthorize = "authorize" // amount held on the card
+	TxCapture   = "capture"
+	TxRefund    = "refund"
+	TxVoid      = "void" // the rest of the hold released
+)
+
+// PaymentTransaction is one money movement of a payment. The ledger is
+// append-only; Payment.CapturedCents and RefundedCents are its sums.
+type PaymentTransaction struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	PaymentID      uint      `gorm:"index;not null" json:"payment_id"`
+	Kind           string    `gorm:"not null" json:"kind"` // authorize|capture|refund|void
+	AmountCents    int       `gorm:"not null" json:"amount_cents"`
+	Actor          string    `gorm:"not null" json:"actor"` // user|system|gateway
+	Note           string    `gorm:"not null;default:''" json:"note,omitempty"`
+	IdempotencyKey string    `gorm:"index;not null;default:''" json:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// PaymentBalance is derived from a payment's ledger.
+type PaymentBalance struct {
+	AuthorizedCents int  `json:"authorized_cents"`
+	CapturedCents   int  `json:"captured_cents"`
+	RefundedCents   int  `json:"refunded_cents"`
+	Voided          bool `json:"voided"`
+}
+
+// Capturable is what can still be captured: the hold, unless it was already
+// captured (once, possibly partially) or released.
+func (b PaymentBalance) Capturable() int {
+	if b.CapturedCents > 0 || b.Voided {
+		return 0
+	}
+	return b.AuthorizedCents
+}
+
+// Refundable is what was captured and not refunded yet.
+func (b PaymentBalance) Refundable() int {
+	return b.CapturedCents - b.RefundedCents
+}
+
+// Balance sums a ledger.
+func Balance(txs []PaymentTransaction) PaymentBalance {
+	var b PaymentBalance
+	for _, t := range txs {
+		switch t.Kind {
+		case TxAuthorize:
+			b.AuthorizedCents += t.AmountCents
+		case TxCapture:
+			b.CapturedCents += t.AmountCents
+		case TxRefund:
+			b.RefundedCents += t.AmountCents
+		case TxVoid:
+			b.Voided = true
+		}
+	}
+	return b
+}
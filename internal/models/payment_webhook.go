@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// PaymentWebhookEvent statuses
+const (
+	WebhookReceived   = "received" // verified and stored, waiting for the processor
+	WebhookProcessing = "processing"
+	WebhookProcessed  = "processed"
+	WebhookFailed     = "failed"  // see LastError; can be replayed
+	WebhookIgnored    = "ignored" // not about a payment we know
+)
+
+// PaymentWebhookEvent is a verified gateway notification. The gateway's event
+// id is unique, so a redelivered event is stored (and processed) once.
+type PaymentWebhookEvent struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Gateway     string     `gorm:"not null;uniqueIndex:uniq_payment_webhook_events_event,priority:1" json:"gateway"`
+	EventID     string     `gorm:"not null;uniqueIndex:uniq_payment_webhook_events_event,priority:2" json:"event_id"`
+	Type        string     `gorm:"not null" json:"type"` // e.g. charge.capture, refund.create, dispute.create
+	ChargeRef   string     `gorm:"index;not null;default:''" json:"charge_ref,omitempty"`
+	Payload     string     `gorm:"type:text;not null" json:"payload"`
+	Status      string     `gorm:"index;not null;default:received" json:"status"` // received|processing|processed|failed|ignored
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	LastError   string     `gorm:"not null;default:''" json:"last_error,omitempty"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"navmate-backend/internal/money"
+)
+
+// Promotion kinds
+const (
+	PromoPercent = "percent" // Value percent off the fare, up to MaxDiscountCents
+	PromoFixed   = "fixed"   // Value off the fare, in Currency
+)
+
+// Promotion = โค้ดส่วนลด ใช้ตอนจอง
+type Promotion struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	Code             string     `gorm:"uniqueIndex;not null" json:"code"` // upper-case
+	Description      string     `gorm:"not null;default:''" json:"description,omitempty"`
+	Kind             string     `gorm:"not null" json:"kind"`                                   // percent|fixed
+	Value            int        `gorm:"not null" json:"value"`                                  // percent, or minor units of Currency
+	Currency         string     `gorm:"not null;default:THB" json:"currency"`                   // of a fixed Value, MaxDiscountCents and MinFareCents
+	MaxDiscountCents int        `gorm:"not null;default:0" json:"max_discount_cents,omitempty"` // cap; 0 = none
+	MinFareCents     int        `gorm:"not null;default:0" json:"min_fare_cents,omitempty"`
+	StartsAt         *time.Time `json:"starts_at,omitempty"`
+	EndsAt           *time.Time `json:"ends_at,omitempty"`
+	MaxUses          int        `gorm:"not null;default:0" json:"max_uses"`              // by everyone; 0 = unlimited
+	MaxUsesPerUser   int        `gorm:"not null;default:1" json:"max_uses_per_user"`     // 0 = unlimited
+	UsedCount        int        `gorm:"not null;default:0" json:"used_count"`            // applied redemptions
+	Providers        string     `gorm:"not null;default:''" json:"providers,omitempty"`  // comma separated; empty = any provider
+	ModeMixes        string     `gorm:"not null;default:''" json:"mode_mixes,omitempty"` // itinerary mode mixes, e.g. RIDE; empty = any
+	Active           bool       `gorm:"not null;default:true" json:"active"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// Unavailable returns why the promotion can't be redeemed at now by anyone,
+// or "" if it can. The codes are returned to clients.
+func (p Promotion) Unavailable(now time.Time) string {
+	switch {
+	case !p.Active:
+		return "promo_inactive"
+	case p.StartsAt != nil && now.Before(*p.StartsAt):
+		return "promo_not_started"
+	case p.EndsAt != nil && !now.Before(*p.EndsAt):
+		return "promo_expired"
+	case p.MaxUses > 0 && p.UsedCount >= p.MaxUses:
+		return "promo_exhausted"
+	}
+	return ""
+}
+
+func inList(list, v string) bool {
+	if list == "" {
+		return true
+	}
+	for _, item := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(item), v) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsProvider reports whether rides with provider qualify.
+func (p Promotion) AllowsProvider(provider string) bool { return inList(p.Providers, provider) }
+
+// AllowsModeMix reports whether itineraries of modeMix qualify.
+func (p Promotion) AllowsModeMix(modeMix string) bool { return inList(p.ModeMixes, modeMix) }
+
+// Discount returns the discount on fare, in fare's currency. rate converts
+// the promotion's currency to the fare's (1 when they are the same). The
+// discount never exceeds the fare; fares below the minimum get none.
+func (p Promotion) Discount(fare money.Money, rate float64) int {
+	local := func(cents int) int { return money.Convert(money.New(cents, p.Currency), fare.Currency, rate).Cents }
+	if p.MinFareCents > 0 && fare.Cents < local(p.MinFareCents) {
+		return 0
+	}
+	var d int
+	switch p.Kind {
+	case PromoPercent:
+		d = int(math.Round(float64(fare.Cents) * float64(p.Value) / 100))
+	case PromoFixed:
+		d = local(p.Value)
+	}
+	if p.MaxDiscountCents > 0 {
+		d = min(d, local(p.MaxDiscountCents))
+	}
+	return max(0, min(d, fare.Cents))
+}
+
+// Redemption statuses
+const (
+	RedemptionApplied  = "applied"
+	RedemptionReleased = "released" // the booking was cancelled or failed
+)
+
+// PromotionRedemption is one use of a promotion, on one booking.
+type PromotionRedemption struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	PromotionID uint      `gorm:"index;not null" json:"promotion_id"`
+	UserID      uint      `gorm:"index;not null" json:"user_id"`
+	BookingID   uint      `gorm:"uniqueIndex;not null" json:"booking_id"`
+	Status      string    `gorm:"not null;default:applied" json:"status"` // applied|released
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Credit transaction kinds
+const (
+	CreditGrant  = "grant"  // added by support or a campaign
+	CreditSpend  = "spend"  // reserved for a booking
+	CreditRefund = "refund" // returned from a booking that didn't need it
+)
+
+// CreditTransaction is one change of a user's credit balance, per currency.
+// The ledger is append-only; the balance is its sum.
+type CreditTransaction struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"index;not null" json:"user_id"`
+	Kind        string    `gorm:"not null" json:"kind"`         // grant|spend|refund
+	AmountCents int       `gorm:"not null" json:"amount_cents"` // negative for spend
+	Currency    string    `gorm:"not null" json:"currency"`
+	BookingID   *uint     `gorm:"index" json:"booking_id,omitempty"`
+	Actor       string    `gorm:"not null" json:"actor"` // admin email, or system
+	Note        string    `gorm:"not null;default:''" json:"note,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
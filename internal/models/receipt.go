@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Receipt = ใบเสร็จรับเงิน/ใบกำกับภาษี ของการชำระเงินที่ตัดเงินแล้ว
+// The document is frozen when issued; later refunds are in the payment's ledger.
+type Receipt struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Number     string     `gorm:"uniqueIndex;not null" json:"number"` // e.g. NM-2026-000042
+	Year       int        `gorm:"not null;uniqueIndex:uniq_receipts_seq,priority:1" json:"-"`
+	Seq        int        `gorm:"not null;uniqueIndex:uniq_receipts_seq,priority:2" json:"-"` // gapless within Year
+	PaymentID  uint       `gorm:"uniqueIndex;not null" json:"payment_id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	Currency   string     `gorm:"not null" json:"currency"`
+	TotalCents int        `gorm:"not null" json:"total_cents"` // captured, tax included
+	TaxCents   int        `gorm:"not null" json:"tax_cents"`
+	Document   string     `gorm:"type:text;not null" json:"-"` // receipts.Document as JSON
+	EmailedAt  *time.Time `json:"emailed_at,omitempty"`
+	EmailedTo  string     `gorm:"not null;default:''" json:"emailed_to,omitempty"`
+	CreatedAt  time.Time  `json:"issued_at"`
+}
+
+// InvoiceCounter is the last receipt number issued in Year; its row lock
+// keeps numbering sequential without gaps.
+type InvoiceCounter struct {
+	Year int `gorm:"primaryKey;autoIncrement:false"`
+	Last int `gorm:"not null;default:0"`
+}
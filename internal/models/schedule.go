@@ -0,0 +1,81 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TripSchedule = ทริปประจำที่ผู้ใช้ตั้งไว้ (เช่น ไปทำงานทุกวันธรรมดา 08:00)
+// scheduler จะสร้าง TripPlan ล่วงหน้าก่อนเวลาออกเดินทาง LeadMinutes นาที
+type TripSchedule struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	UserID         uint       `gorm:"index;not null" json:"user_id"`
+	Origin         string     `gorm:"not null" json:"origin"`
+	Destination    string     `gorm:"not null" json:"destination"`
+	Weekdays       string     `gorm:"not null" json:"weekdays"`    // comma separated: mon,tue,wed,thu,fri,sat,sun
+	DepartTime     string     `gorm:"not null" json:"depart_time"` // HH:MM in Timezone
+	Timezone       string     `gorm:"not null;default:Asia/Bangkok" json:"timezone"`
+	LeadMinutes    int        `gorm:"not null;default:60" json:"lead_minutes"`
+	AutoSelect     string     `gorm:"not null;default:''" json:"auto_select"` // ""|fastest|cheapest
+	Active         bool       `gorm:"not null;default:true" json:"active"`
+	LastPlannedFor *time.Time `json:"last_planned_for,omitempty"` // departure the latest plan was created for
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseWeekdays converts "mon,tue" style lists into weekdays.
+func ParseWeekdays(s string) ([]time.Weekday, error) {
+	var out []time.Weekday
+	for _, part := range strings.Split(s, ",") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if name == "" {
+			continue
+		}
+		d, ok := weekdayNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q", part)
+		}
+		out = append(out, d)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no weekdays given")
+	}
+	return out, nil
+}
+
+// NextDeparture returns the first scheduled departure strictly after `after`.
+func (s TripSchedule) NextDeparture(after time.Time) (time.Time, error) {
+	days, err := ParseWeekdays(s.Weekdays)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timezone: %w", err)
+	}
+	clock, err := time.Parse("15:04", s.DepartTime)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid depart_time: %w", err)
+	}
+
+	local := after.In(loc)
+	for i := 0; i <= 7; i++ {
+		d := local.AddDate(0, 0, i)
+		cand := time.Date(d.Year(), d.Month(), d.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+		if !cand.After(after) {
+			continue
+		}
+		for _, wd := range days {
+			if cand.Weekday() == wd {
+				return cand, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("no departure found")
+}
@@ -0,0 +1,105 @@
+package models
+
+import "time"
+
+// FareSplit statuses
+const (
+	SplitOpen      = "open"      // waiting for the participants
+	SplitFunded    = "funded"    // every share is authorized
+	SplitSettled   = "settled"   // the shares were captured
+	SplitFailed    = "failed"    // shares went unpaid and the owner didn't cover them
+	SplitCancelled = "cancelled" // by the owner, or the booking ended without a ride
+)
+
+// What happens to shares that are declined or not paid in time
+const (
+	SplitOwnerCovers = "owner_covers" // the owner's saved card is charged the rest
+	SplitVoid        = "void"         // every share is voided; the owner pays the booking again
+)
+
+// SplitShare roles
+const (
+	ShareOwner       = "owner"
+	ShareParticipant = "participant"
+	ShareCover       = "cover" // the owner paying for shares left unpaid
+)
+
+// SplitShare statuses
+const (
+	ShareInvited  = "invited"
+	ShareAccepted = "accepted" // a payment was made; see PaymentStatus for whether it went through
+	ShareDeclined = "declined"
+	ShareExpired  = "expired" // not paid by the split's deadline
+)
+
+// FareSplit = การหารค่าโดยสารของการจองหนึ่งระหว่างผู้ใช้หลายคน
+// Each share is paid with its own payment; the booking itself has none.
+type FareSplit struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	BookingID  uint       `gorm:"index;not null" json:"booking_id"`
+	OwnerID    uint       `gorm:"index;not null" json:"owner_id"`
+	Status     string     `gorm:"index;not null;default:open" json:"status"` // open|funded|settled|failed|cancelled
+	OnDecline  string     `gorm:"not null;default:owner_covers" json:"on_decline"`
+	Currency   string     `gorm:"not null" json:"currency"`
+	TotalCents int        `gorm:"not null" json:"total_cents"` // what the booking is charged, the sum of the shares
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`  // shares not paid by then are left to OnDecline
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	Note       string     `gorm:"not null;default:''" json:"note,omitempty"` // why it failed or was cancelled
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// SplitShare is what one user pays of a FareSplit.
+type SplitShare struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	SplitID       uint       `gorm:"index;not null;uniqueIndex:uniq_split_shares_user,priority:1" json:"split_id"`
+	UserID        uint       `gorm:"index;not null;uniqueIndex:uniq_split_shares_user,priority:2" json:"user_id"`
+	Role          string     `gorm:"not null;uniqueIndex:uniq_split_shares_user,priority:3" json:"role"` // owner|participant|cover
+	AmountCents   int        `gorm:"not null" json:"amount_cents"`
+	Status        string     `gorm:"not null;default:invited" json:"status"` // invited|accepted|declined|expired
+	PaymentID     *uint      `gorm:"index" json:"payment_id,omitempty"`
+	PaymentStatus string     `gorm:"not null;default:''" json:"payment_status,omitempty"` // mirror of Payment.Status, kept by PaymentRepository
+	RespondedAt   *time.Time `json:"responded_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Paid reports whether the share's payment holds or took its money.
+func (s SplitShare) Paid() bool {
+	switch s.PaymentStatus {
+	case "authorized", "captured", "partially_refunded", "refunded", "disputed":
+		return true
+	}
+	return false
+}
+
+// Open reports whether the user can still pay the share: they haven't
+// answered, or their payment was declined or is waiting for them.
+func (s SplitShare) Open() bool {
+	return s.Status == ShareInvited || (s.Status == ShareAccepted && !s.Paid())
+}
+
+// Allocate divides total in proportion to weights. Cents lost to rounding go
+// to the first entries, so the parts always add up to total.
+func Allocate(total int, weights []int) []int {
+	parts := make([]int, len(weights))
+	sum := 0
+	for _, w := range weights {
+		sum += w
+	}
+	if sum <= 0 {
+		return parts
+	}
+	left := total
+	for i, w := range weights {
+		parts[i] = total * w / sum
+		left -= parts[i]
+	}
+	for i := 0; left > 0 && len(parts) > 0; i = (i + 1) % len(parts) {
+		if weights[i] > 0 {
+			parts[i]++
+			left--
+		}
+	}
+	return parts
+}
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"navmate-backend/internal/money"
+)
 
 // TripPlan = คำขอวางแผนการเดินทางของผู้ใช้ (ประตู-ถึง-ประตู)
 type TripPlan struct {
@@ -9,8 +13,9 @@ type TripPlan struct {
 	Origin              string     `gorm:"not null" json:"origin"`
 	Destination         string     `gorm:"not null" json:"destination"`
 	DepartAt            *time.Time `json:"depart_at,omitempty"`
-	Status              string     `gorm:"not null;default:planned" json:"status"` // planned|selected|active|completed|cancelled
+	Status              string     `gorm:"not null;default:planned" json:"status"` // planned|selected|active|completed|cancelled|archived
 	SelectedItineraryID *uint      `json:"selected_itinerary_id,omitempty"`
+	ScheduleID          *uint      `gorm:"index" json:"schedule_id,omitempty"` // set when created by the trip scheduler
 	CreatedAt           time.Time  `json:"created_at"`
 	UpdatedAt           time.Time  `json:"updated_at"`
 
@@ -18,17 +23,28 @@ type TripPlan struct {
 }
 
 type Itinerary struct {
-	ID             uint      `gorm:"primaryKey" json:"id"`
-	PlanID         uint      `gorm:"index;not null" json:"plan_id"`
-	ModeMix        string    `gorm:"not null" json:"mode_mix"` // เช่น WALK+TRANSIT+RIDE
-	TotalMinutes   int       `gorm:"not null" json:"total_minutes"`
-	RoughCostCents int       `gorm:"not null" json:"rough_cost_cents"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID             uint   `gorm:"primaryKey" json:"id"`
+	PlanID         uint   `gorm:"index;not null" json:"plan_id"`
+	ModeMix        string `gorm:"not null" json:"mode_mix"` // เช่น WALK+TRANSIT+RIDE
+	TotalMinutes   int    `gorm:"not null" json:"total_minutes"`
+	RoughCostCents int    `gorm:"not null" json:"rough_cost_cents"`
+	Currency       string `gorm:"not null;default:THB" json:"currency"` // of every fare in the itinerary, by region
+	// RoughCostCents in the user's preferred currency, converted when planned
+	DisplayCurrency  string     `gorm:"not null;default:''" json:"display_currency,omitempty"`
+	DisplayCostCents int        `gorm:"not null;default:0" json:"display_cost_cents,omitempty"`
+	FxRate           float64    `gorm:"not null;default:0" json:"fx_rate,omitempty"`
+	ExpiresAt        *time.Time `gorm:"index" json:"expires_at,omitempty"` // fares/ETAs are stale after this; nil = no expiry
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
 
 	Legs []Leg `gorm:"foreignKey:ItineraryID;constraint:OnDelete:CASCADE;" json:"-"`
 }
 
+// Expired reports whether the itinerary's quote window has passed.
+func (it Itinerary) Expired(now time.Time) bool {
+	return it.ExpiresAt != nil && now.After(*it.ExpiresAt)
+}
+
 type Leg struct {
 	ID          uint    `gorm:"primaryKey" json:"id"`
 	ItineraryID uint    `gorm:"index;not null" json:"itinerary_id"`
@@ -38,33 +54,122 @@ type Leg struct {
 	ToName      string  `gorm:"not null" json:"to_name"`
 	Minutes     int     `gorm:"not null" json:"minutes"`
 	DistanceM   int64   `gorm:"not null" json:"distance_m"`
-	Provider    *string `json:"provider,omitempty"` // สำหรับ RIDE
+	Provider    *string `json:"provider,omitempty"`                   // สำหรับ RIDE; leg ที่มี provider จองได้
+	FareCents   int     `gorm:"not null;default:0" json:"fare_cents"` // ค่าโดยสารของ leg นี้
 }
 
 // การจองรถ (สำหรับ RIDE legs)
 type RideBooking struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	PlanID      uint      `gorm:"index;not null" json:"plan_id"`
-	ItineraryID uint      `gorm:"index;not null" json:"itinerary_id"`
-	Provider    string    `gorm:"not null" json:"provider"`
-	Status      string    `gorm:"not null;default:pending" json:"status"` // pending|confirmed|cancelled|driver_assigned|in_progress|completed
-	EtaMinutes  int       `gorm:"not null" json:"eta_minutes"`
-	FareCents   int       `gorm:"not null" json:"fare_cents"`
-	PaymentID   *uint     `json:"payment_id,omitempty"`
-	QuoteID     string    `gorm:"index" json:"quote_id,omitempty"` // for idempotency
-	ExternalRef string    `json:"external_ref,omitempty"`          // provider's booking reference
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	PlanID      uint   `gorm:"index;not null;uniqueIndex:uniq_ride_bookings_idempotency,priority:1" json:"plan_id"`
+	ItineraryID uint   `gorm:"index;not null" json:"itinerary_id"`
+	LegID       *uint  `gorm:"index;uniqueIndex:uniq_ride_bookings_idempotency,priority:3" json:"leg_id,omitempty"` // one booking per bookable leg
+	GroupRef    string `gorm:"index;not null;default:''" json:"group_ref,omitempty"`                                // legs booked together, all-or-nothing
+	Provider    string `gorm:"not null" json:"provider"`
+	Status      string `gorm:"not null;default:pending" json:"status"` // scheduled|pending|needs_confirmation|confirmed|cancelled|declined|failed|driver_assigned|in_progress|completed
+	EtaMinutes  int    `gorm:"not null" json:"eta_minutes"`
+	FareCents   int    `gorm:"not null" json:"fare_cents"`
+	Currency    string `gorm:"not null;default:THB" json:"currency"` // of FareCents and every fee, the itinerary's
+	// preferred currency and rate of the quote it was booked from, see DisplayFare
+	DisplayCurrency string  `gorm:"not null;default:''" json:"display_currency,omitempty"`
+	FxRate          float64 `gorm:"not null;default:0" json:"fx_rate,omitempty"`
+	// promotion and credits applied at booking, kept by PromotionRepository; settled against the final fare when paid
+	PromotionID    *uint   `gorm:"index" json:"promotion_id,omitempty"`
+	PromoCode      string  `gorm:"not null;default:''" json:"promo_code,omitempty"`
+	DiscountCents  int     `gorm:"not null;default:0" json:"discount_cents"`
+	CreditCents    int     `gorm:"not null;default:0" json:"credit_cents"`
+	PaymentID      *uint   `json:"payment_id,omitempty"`
+	PaymentStatus  string  `gorm:"not null;default:''" json:"payment_status,omitempty"`                                    // mirror of Payment.Status, kept by PaymentRepository
+	QuoteID        *string `gorm:"uniqueIndex:uniq_ride_bookings_quote_id" json:"quote_id,omitempty"`                      // one booking per quote
+	IdempotencyKey *string `gorm:"uniqueIndex:uniq_ride_bookings_idempotency,priority:2" json:"idempotency_key,omitempty"` // Idempotency-Key header, unique per plan
+	ExternalRef    string  `gorm:"index" json:"-"`                                                                         // provider's booking reference, kept from riders
+	// advance booking: sent to the provider BOOKING_DISPATCH_LEAD before PickupAt
+	PickupAt *time.Time `gorm:"index" json:"pickup_at,omitempty"`
+	// surge waiting for the user's decision (status needs_confirmation)
+	SurgeMultiplier float64 `gorm:"not null;default:0" json:"surge_multiplier,omitempty"`
+	SurgeFareCents  int     `gorm:"not null;default:0" json:"surge_fare_cents,omitempty"`
+	SurgeQuoteID    string  `gorm:"not null;default:''" json:"surge_quote_id,omitempty"`
+	// cancellation
+	CancelledAt          *time.Time `json:"cancelled_at,omitempty"`
+	CancelledBy          string     `gorm:"not null;default:''" json:"cancelled_by,omitempty"` // user|system|provider
+	CancelReason         string     `gorm:"not null;default:''" json:"cancel_reason,omitempty"`
+	CancellationFeeCents int        `gorm:"not null;default:0" json:"cancellation_fee_cents"`
+	// driver details reported by the provider once assigned
+	DriverName       string     `gorm:"not null;default:''" json:"driver_name,omitempty"`
+	VehiclePlate     string     `gorm:"not null;default:''" json:"vehicle_plate,omitempty"`
+	VehicleModel     string     `gorm:"not null;default:''" json:"vehicle_model,omitempty"`
+	DriverLat        *float64   `json:"driver_lat,omitempty"`
+	DriverLng        *float64   `json:"driver_lng,omitempty"`
+	DriverLocationAt *time.Time `json:"driver_location_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// Fare is the booked fare with its currency.
+func (b RideBooking) Fare() money.Money { return money.New(b.FareCents, b.Currency) }
+
+// DisplayFare is the fare in the user's preferred currency at the rate it was
+// quoted with; ok=false when the fare is shown as is.
+func (b RideBooking) DisplayFare() (m money.Money, ok bool) {
+	if b.DisplayCurrency == "" || b.DisplayCurrency == b.Currency || b.FxRate <= 0 {
+		return m, false
+	}
+	return money.Convert(b.Fare(), b.DisplayCurrency, b.FxRate), true
+}
+
+// RideQuote = ราคาที่ผู้ให้บริการเสนอไว้ ใช้จองได้จนถึง ExpiresAt
+type RideQuote struct {
+	ID          uint   `gorm:"primaryKey" json:"-"`
+	QuoteID     string `gorm:"uniqueIndex;not null" json:"quote_id"` // provider's quote id
+	UserID      uint   `gorm:"index;not null" json:"-"`
+	PlanID      uint   `gorm:"index;not null" json:"plan_id"`
+	ItineraryID uint   `gorm:"not null" json:"itinerary_id"`
+	LegID       *uint  `json:"leg_id,omitempty"`
+	Provider    string `gorm:"not null" json:"provider"`
+	FareCents   int    `gorm:"not null" json:"fare_cents"`
+	Currency    string `gorm:"not null;default:THB" json:"currency"`
+	// FareCents in the user's preferred currency, converted when quoted
+	DisplayCurrency  string    `gorm:"not null;default:''" json:"display_currency,omitempty"`
+	DisplayFareCents int       `gorm:"not null;default:0" json:"display_fare_cents,omitempty"`
+	FxRate           float64   `gorm:"not null;default:0" json:"fx_rate,omitempty"`
+	EtaMinutes       int       `gorm:"not null" json:"eta_minutes"`
+	SurgeMultiplier  float64   `gorm:"not null;default:1" json:"surge_multiplier"`
+	ExpiresAt        time.Time `gorm:"not null" json:"expires_at"`
+	CreatedAt        time.Time `json:"created_at"`
 }
 
 type Payment struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	AmountCents int       `gorm:"not null" json:"amount_cents"`
-	Currency    string    `gorm:"not null;default:THB" json:"currency"`
-	Status      string    `gorm:"not null;default:authorized" json:"status"` // authorized|captured|refunded|voided|declined
-	ExternalRef string    `gorm:"not null;default:'stub'" json:"external_ref"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID            uint   `gorm:"primaryKey" json:"id"`
+	AmountCents   int    `gorm:"not null" json:"amount_cents"`
+	Currency      string `gorm:"not null;default:THB" json:"currency"`
+	Status        string `gorm:"not null;default:authorized" json:"status"` // pending|authorized|captured|partially_refunded|refunded|voided|declined|expired|disputed
+	CapturedCents int    `gorm:"not null;default:0" json:"captured_cents"`
+	RefundedCents int    `gorm:"not null;default:0" json:"refunded_cents"`
+	Gateway       string `gorm:"not null;default:''" json:"gateway"`                // PAYMENT_GATEWAY that holds the charge
+	ExternalRef   string `gorm:"index;not null;default:'stub'" json:"external_ref"` // gateway's charge id
+	FailureCode   string `gorm:"not null;default:''" json:"failure_code,omitempty"` // why the gateway declined it
+	// what AmountCents was made of when authorized: fare + fee + tip - discount - credit
+	FareCents       int    `gorm:"not null;default:0" json:"fare_cents"`
+	FeeCents        int    `gorm:"not null;default:0" json:"fee_cents"`
+	TipCents        int    `gorm:"not null;default:0" json:"tip_cents"`
+	DiscountCents   int    `gorm:"not null;default:0" json:"discount_cents"`
+	CreditCents     int    `gorm:"not null;default:0" json:"credit_cents"`
+	IdempotencyKey  string `gorm:"not null;default:''" json:"-"`             // client's Idempotency-Key, to answer retries
+	PaymentMethodID *uint  `gorm:"index" json:"payment_method_id,omitempty"` // saved method it was paid with, if any
+	// the booking's preferred currency and quoted rate, see DisplayAmount
+	DisplayCurrency string    `gorm:"not null;default:''" json:"display_currency,omitempty"`
+	FxRate          float64   `gorm:"not null;default:0" json:"fx_rate,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// DisplayAmount is AmountCents in the user's preferred currency; ok=false
+// when the amount is shown as is.
+func (p Payment) DisplayAmount() (m money.Money, ok bool) {
+	if p.DisplayCurrency == "" || p.DisplayCurrency == p.Currency || p.FxRate <= 0 {
+		return m, false
+	}
+	return money.Convert(money.New(p.AmountCents, p.Currency), p.DisplayCurrency, p.FxRate), true
 }
 
 type SafetySession struct {
@@ -11,3 +11,65 @@ type User struct {
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 }
+
+// UserPreference = การตั้งค่าส่วนตัวของผู้ใช้ (ไม่มีแถว = ใช้ค่าเริ่มต้น)
+type UserPreference struct {
+	ID                 uint    `gorm:"primaryKey" json:"-"`
+	UserID             uint    `gorm:"uniqueIndex;not null" json:"user_id"`
+	MaxSurgeMultiplier float64 `gorm:"not null;default:1" json:"max_surge_multiplier"`    // surges up to this are accepted without asking
+	RideFallback       string  `gorm:"not null;default:alternative" json:"ride_fallback"` // what to do when a ride booking fails, see RideFallback*
+	Currency           string  `gorm:"not null;default:''" json:"currency"`               // fares are also shown in this currency; empty = local currency only
+	// business details printed on receipts, for tax invoices; empty = personal receipt
+	BillingName    string    `gorm:"not null;default:''" json:"billing_name"`
+	BillingTaxID   string    `gorm:"not null;default:''" json:"billing_tax_id"`
+	BillingAddress string    `gorm:"not null;default:''" json:"billing_address"`
+	EmailReceipts  bool      `gorm:"not null;default:false" json:"email_receipts"` // email each receipt when it is issued
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Ride fallback policies, applied when the provider fails a booking.
+const (
+	RideFallbackRetry        = "retry"         // retry the same provider with backoff
+	RideFallbackNextProvider = "next_provider" // book the next cheapest provider
+	RideFallbackAlternative  = "alternative"   // don't rebook; propose the best itinerary without a ride
+)
+
+// ValidRideFallback reports whether s is a known fallback policy.
+func ValidRideFallback(s string) bool {
+	switch s {
+	case RideFallbackRetry, RideFallbackNextProvider, RideFallbackAlternative:
+		return true
+	}
+	return false
+}
+
+// DefaultPreference is used for users who never saved preferences.
+func DefaultPreference(userID uint) UserPreference {
+	return UserPreference{UserID: userID, MaxSurgeMultiplier: 1, RideFallback: RideFallbackAlternative}
+}
+
+// PaymentMethod = วิธีชำระเงินที่ผู้ใช้บันทึกไว้ เก็บเฉพาะ reference ของ gateway และข้อมูลสำหรับแสดงผล
+type PaymentMethod struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"index;uniqueIndex:uniq_payment_methods_default,where:is_default;not null" json:"user_id"` // one default per user
+	Type       string    `gorm:"not null" json:"type"`                                                                    // card|promptpay|wallet, see PaymentMethod*
+	Gateway    string    `gorm:"not null" json:"gateway"`                                                                 // PAYMENT_GATEWAY that holds GatewayRef
+	GatewayRef string    `gorm:"not null;default:''" json:"-"`                                                            // saved card at the gateway; never sent to clients
+	Provider   string    `gorm:"not null;default:''" json:"provider,omitempty"`                                           // wallet: the gateway's source type, e.g. truemoney
+	Brand      string    `gorm:"not null;default:''" json:"brand,omitempty"`
+	Last4      string    `gorm:"not null;default:''" json:"last4,omitempty"`
+	ExpMonth   int       `gorm:"not null;default:0" json:"exp_month,omitempty"`
+	ExpYear    int       `gorm:"not null;default:0" json:"exp_year,omitempty"`
+	Label      string    `gorm:"not null;default:''" json:"label,omitempty"`
+	IsDefault  bool      `gorm:"not null;default:false" json:"is_default"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Payment method types
+const (
+	PaymentMethodCard      = "card"
+	PaymentMethodPromptPay = "promptpay"
+	PaymentMethodWallet    = "wallet"
+)
@@ -0,0 +1,66 @@
+package money
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Money is an amount in the minor unit of its currency (satang, cents, or
+// whole yen for currencies without one). Amounts are called *_cents across
+// the API whatever the currency.
+type Money struct {
+	Cents    int    `json:"amount_cents"`
+	Currency string `json:"currency"` // ISO 4217, e.g. THB
+}
+
+func New(cents int, currency string) Money { return Money{Cents: cents, Currency: currency} }
+
+// exponents are the number of minor-unit digits of the supported currencies.
+var exponents = map[string]int{
+	"THB": 2, "USD": 2, "EUR": 2, "GBP": 2, "SGD": 2, "MYR": 2, "IDR": 2, "PHP": 2,
+	"HKD": 2, "AUD": 2, "CNY": 2, "LAK": 2, "VND": 0, "JPY": 0, "KRW": 0,
+}
+
+// Known reports whether currency is supported.
+func Known(currency string) bool {
+	_, ok := exponents[currency]
+	return ok
+}
+
+// Exponent returns the number of minor-unit digits of currency (2 if unknown).
+func Exponent(currency string) int {
+	if e, ok := exponents[currency]; ok {
+		return e
+	}
+	return 2
+}
+
+// Convert returns m in currency to, where rate is the price of one major unit
+// of m's currency in major units of to. Rounds half away from zero.
+func Convert(m Money, to string, rate float64) Money {
+	if m.Currency == to {
+		return m
+	}
+	scale := math.Pow10(Exponent(to) - Exponent(m.Currency))
+	return Money{Cents: int(math.Round(float64(m.Cents) * rate * scale)), Currency: to}
+}
+
+// String formats m for logs and notes, e.g. "123.45 THB".
+func (m Money) String() string {
+	e := Exponent(m.Currency)
+	if e == 0 {
+		return fmt.Sprintf("%d %s", m.Cents, m.Currency)
+	}
+	sign, cents := "", m.Cents
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	div := int(math.Pow10(e))
+	return fmt.Sprintf("%s%d.%0*d %s", sign, cents/div, e, cents%div, m.Currency)
+}
+
+// Normalize upper-cases a currency code from user input.
+func Normalize(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	"navmate-backend/internal/events"
+)
+
+// Notification kinds sent to users.
+const (
+	KindTripPlanned       = events.TypeTripPlanned
+	KindTravelTimeChanged = events.TypeTravelTimeChanged
+	KindHeartbeatDue      = events.TypeHeartbeatDue
+	KindDispatchFailed    = events.TypeDispatchFailed
+)
+
+// Notifier delivers user-facing notifications (push, email, ...).
+type Notifier interface {
+	Notify(ctx context.Context, userID uint, kind string, data map[string]any) error
+}
+
+// LogNotifier only writes notifications to the log. Used until a real push provider is wired in.
+type LogNotifier struct {
+	log *zap.SugaredLogger
+}
+
+func NewLogNotifier(log *zap.SugaredLogger) *LogNotifier { return &LogNotifier{log: log} }
+
+func (n *LogNotifier) Notify(_ context.Context, userID uint, kind string, data map[string]any) error {
+	n.log.Infow("notify", "user_id", userID, "kind", kind, "data", data)
+	return nil
+}
+
+// EventNotifier forwards notifications to the event bus so connected clients get them live.
+type EventNotifier struct {
+	pub events.Publisher
+}
+
+func NewEventNotifier(pub events.Publisher) *EventNotifier { return &EventNotifier{pub: pub} }
+
+func (n *EventNotifier) Notify(ctx context.Context, userID uint, kind string, data map[string]any) error {
+	return n.pub.Publish(ctx, events.Event{Type: kind, UserID: userID, Data: data})
+}
+
+// Multi sends every notification to all notifiers and joins their errors.
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, userID uint, kind string, data map[string]any) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, userID, kind, data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,171 @@
+// Package receipts builds, renders and sends the receipts (tax invoices) of
+// captured payments.
+package receipts
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"navmate-backend/internal/models"
+)
+
+// Party is the seller or buyer printed on a receipt.
+type Party struct {
+	Name    string `json:"name,omitempty"`
+	TaxID   string `json:"tax_id,omitempty"`
+	Address string `json:"address,omitempty"`
+	Email   string `json:"email,omitempty"`
+}
+
+type Trip struct {
+	PlanID      uint       `json:"plan_id"`
+	Origin      string     `json:"origin"`
+	Destination string     `json:"destination"`
+	DepartAt    *time.Time `json:"depart_at,omitempty"`
+	ModeMix     string     `json:"mode_mix,omitempty"`
+}
+
+// Leg is one leg of the trip; ride legs carry their booking.
+type Leg struct {
+	Index     int    `json:"index"`
+	Mode      string `json:"mode"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	DistanceM int64  `json:"distance_m"`
+	Minutes   int    `json:"minutes"`
+	Provider  string `json:"provider,omitempty"`
+	BookingID uint   `json:"booking_id,omitempty"`
+	Status    string `json:"status,omitempty"`
+	FareCents int    `json:"fare_cents,omitempty"`
+}
+
+// Line is one amount of the breakdown; deductions are negative.
+type Line struct {
+	Description string `json:"description"`
+	AmountCents int    `json:"amount_cents"`
+}
+
+// Document is everything printed on a receipt. Amounts are in Currency;
+// prices include tax.
+type Document struct {
+	Number      string    `json:"number"`
+	IssuedAt    time.Time `json:"issued_at"`
+	TaxInvoice  bool      `json:"tax_invoice"` // the buyer gave business details
+	Seller      Party     `json:"seller"`
+	Buyer       Party     `json:"buyer"`
+	PaymentID   uint      `json:"payment_id"`
+	Gateway     string    `json:"gateway"`
+	ExternalRef string    `json:"external_ref"`
+	Trip        Trip      `json:"trip"`
+	Legs        []Leg     `json:"legs"`
+	Lines       []Line    `json:"lines"`
+	Currency    string    `json:"currency"`
+	NetCents    int       `json:"net_cents"` // total before tax
+	TaxRate     float64   `json:"tax_rate"`  // percent
+	TaxCents    int       `json:"tax_cents"`
+	TotalCents  int       `json:"total_cents"`
+}
+
+// Input is what a receipt is built from.
+type Input struct {
+	Payment   models.Payment
+	Plan      models.TripPlan
+	Itinerary models.Itinerary
+	Legs      []models.Leg
+	Bookings  []models.RideBooking // paid by Payment
+	Shared    bool                 // Payment is one share of a split fare
+	Seller    Party
+	Buyer     Party
+	TaxRateBP int // basis points, e.g. 700 = 7%
+}
+
+// Build lays out the receipt of in.Payment for what was captured. Number and
+// IssuedAt are set when it is issued.
+func Build(in Input) Document {
+	p := in.Payment
+	doc := Document{
+		TaxInvoice: in.Buyer.TaxID != "",
+		Seller:     in.Seller, Buyer: in.Buyer,
+		PaymentID: p.ID, Gateway: p.Gateway, ExternalRef: p.ExternalRef,
+		Trip: Trip{
+			PlanID: in.Plan.ID, Origin: in.Plan.Origin, Destination: in.Plan.Destination,
+			DepartAt: in.Plan.DepartAt, ModeMix: in.Itinerary.ModeMix,
+		},
+		Currency:   p.Currency,
+		TotalCents: p.CapturedCents,
+		TaxRate:    float64(in.TaxRateBP) / 100,
+	}
+
+	byLeg := map[uint]models.RideBooking{}
+	promo, unridden := "", true
+	for _, b := range in.Bookings {
+		if b.LegID != nil {
+			byLeg[*b.LegID] = b
+		}
+		if b.PromoCode != "" {
+			promo = b.PromoCode
+		}
+		unridden = unridden && models.IsTerminal(b.Status) && b.Status != models.BookingCompleted
+	}
+	doc.Legs = make([]Leg, 0, len(in.Legs))
+	for _, l := range in.Legs {
+		leg := Leg{Index: l.Index, Mode: l.Mode, From: l.FromName, To: l.ToName, DistanceM: l.DistanceM, Minutes: l.Minutes}
+		if b, ok := byLeg[l.ID]; ok {
+			leg.Provider, leg.BookingID, leg.Status, leg.FareCents = b.Provider, b.ID, b.Status, b.FareCents
+		}
+		doc.Legs = append(doc.Legs, leg)
+	}
+
+	if unridden && len(in.Bookings) > 0 {
+		// no ride: only the cancellation fee was captured
+		doc.Lines = []Line{{Description: "Cancellation fee", AmountCents: p.CapturedCents}}
+	} else {
+		fare := "Fare"
+		if in.Shared {
+			fare = "Fare share (split fare)"
+		}
+		doc.Lines = append(doc.Lines, Line{Description: fare, AmountCents: p.FareCents})
+		if p.FeeCents != 0 {
+			doc.Lines = append(doc.Lines, Line{Description: "Service fee", AmountCents: p.FeeCents})
+		}
+		if p.TipCents != 0 {
+			doc.Lines = append(doc.Lines, Line{Description: "Tip", AmountCents: p.TipCents})
+		}
+		if p.DiscountCents != 0 {
+			desc := "Discount"
+			if promo != "" {
+				desc += " (" + promo + ")"
+			}
+			doc.Lines = append(doc.Lines, Line{Description: desc, AmountCents: -p.DiscountCents})
+		}
+		if p.CreditCents != 0 {
+			doc.Lines = append(doc.Lines, Line{Description: "Credit", AmountCents: -p.CreditCents})
+		}
+		if diff := p.CapturedCents - p.AmountCents; diff != 0 {
+			doc.Lines = append(doc.Lines, Line{Description: "Adjustment at capture", AmountCents: diff})
+		}
+	}
+
+	doc.TaxCents = InclusiveTax(doc.TotalCents, in.TaxRateBP)
+	doc.NetCents = doc.TotalCents - doc.TaxCents
+	return doc
+}
+
+// InclusiveTax is the tax contained in a tax-inclusive amount.
+func InclusiveTax(totalCents, rateBP int) int {
+	if rateBP <= 0 {
+		return 0
+	}
+	return int(math.Round(float64(totalCents) * float64(rateBP) / float64(10000+rateBP)))
+}
+
+// Load returns the document frozen in rec, with its number and issue time.
+func Load(rec models.Receipt) (Document, error) {
+	var doc Document
+	if err := json.Unmarshal([]byte(rec.Document), &doc); err != nil {
+		return doc, err
+	}
+	doc.Number, doc.IssuedAt = rec.Number, rec.CreatedAt
+	return doc, nil
+}
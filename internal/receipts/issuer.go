@@ -0,0 +1,168 @@
+package receipts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/adapters/mail"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/repository"
+)
+
+var ErrNotCaptured = errors.New("payment has not been captured")
+
+// Issuer issues the receipts of captured payments and emails them.
+type Issuer struct {
+	db       *gorm.DB
+	receipts *repository.ReceiptRepository
+	prefs    *repository.PreferenceRepository
+	mail     mail.Sender // nil = mail disabled
+	seller   Party
+	prefix   string
+	taxBP    int
+	loc      *time.Location // receipt dates and the numbering year
+}
+
+func NewIssuer(db *gorm.DB, sender mail.Sender, cfg *config.Config) *Issuer {
+	loc, err := time.LoadLocation(cfg.App.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return &Issuer{
+		db:       db,
+		receipts: repository.NewReceiptRepository(db),
+		prefs:    repository.NewPreferenceRepository(db),
+		mail:     sender,
+		seller: Party{
+			Name: cfg.Receipt.BusinessName, TaxID: cfg.Receipt.BusinessTaxID,
+			Address: cfg.Receipt.BusinessAddress, Email: cfg.Receipt.BusinessEmail,
+		},
+		prefix: cfg.Receipt.Prefix,
+		taxBP:  cfg.Receipt.VATRateBP,
+		loc:    loc,
+	}
+}
+
+// Issue returns the receipt of p, issuing it the first time; created
+// reports whether this call issued it.
+func (is *Issuer) Issue(ctx context.Context, p models.Payment) (rec models.Receipt, created bool, err error) {
+	if p.CapturedCents <= 0 {
+		return rec, false, ErrNotCaptured
+	}
+	rec, err = is.receipts.ByPayment(ctx, p.ID)
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return rec, false, err
+	}
+
+	db := is.db.WithContext(ctx)
+	in := Input{Payment: p, Seller: is.seller, TaxRateBP: is.taxBP}
+	if err := db.Where("payment_id = ?", p.ID).Order("id ASC").Find(&in.Bookings).Error; err != nil {
+		return rec, false, err
+	}
+	buyerID := uint(0)
+	if len(in.Bookings) == 0 {
+		// a share of a split fare, bought by its participant
+		var share models.SplitShare
+		if err := db.Where("payment_id = ?", p.ID).First(&share).Error; err != nil {
+			return rec, false, errors.New("payment has no booking")
+		}
+		var split models.FareSplit
+		if err := db.First(&split, share.SplitID).Error; err != nil {
+			return rec, false, err
+		}
+		if err := db.Where("id = ?", split.BookingID).Find(&in.Bookings).Error; err != nil || len(in.Bookings) == 0 {
+			return rec, false, errors.New("payment has no booking")
+		}
+		buyerID, in.Shared = share.UserID, true
+	}
+	if err := db.First(&in.Plan, in.Bookings[0].PlanID).Error; err != nil {
+		return rec, false, err
+	}
+	if err := db.First(&in.Itinerary, in.Bookings[0].ItineraryID).Error; err != nil {
+		return rec, false, err
+	}
+	if err := db.Where("itinerary_id = ?", in.Itinerary.ID).Order("index ASC").Find(&in.Legs).Error; err != nil {
+		return rec, false, err
+	}
+	if buyerID == 0 {
+		buyerID = in.Plan.UserID
+	}
+	var user models.User
+	if err := db.First(&user, buyerID).Error; err != nil {
+		return rec, false, err
+	}
+	pref, err := is.prefs.Get(ctx, user.ID)
+	if err != nil {
+		return rec, false, err
+	}
+	in.Buyer = Party{Name: pref.BillingName, TaxID: pref.BillingTaxID, Address: pref.BillingAddress, Email: user.Email}
+
+	doc := Build(in)
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return rec, false, err
+	}
+	rec = models.Receipt{
+		PaymentID: p.ID, UserID: user.ID, Currency: doc.Currency,
+		TotalCents: doc.TotalCents, TaxCents: doc.TaxCents, Document: string(data),
+	}
+	created, err = is.receipts.Issue(ctx, &rec, time.Now().In(is.loc).Year(), is.prefix)
+	return rec, created, err
+}
+
+// Document returns what rec prints, dated in the app's timezone.
+func (is *Issuer) Document(rec models.Receipt) (Document, error) {
+	doc, err := Load(rec)
+	doc.IssuedAt = doc.IssuedAt.In(is.loc)
+	return doc, err
+}
+
+// Email sends rec to address, the PDF attached. It returns
+// mail.ErrDisabled when no mail sender is configured.
+func (is *Issuer) Email(ctx context.Context, rec *models.Receipt, address string) error {
+	if is.mail == nil {
+		return mail.ErrDisabled
+	}
+	doc, err := is.Document(*rec)
+	if err != nil {
+		return err
+	}
+	html, err := HTML(doc)
+	if err != nil {
+		return err
+	}
+	err = is.mail.Send(ctx, mail.Message{
+		To:          address,
+		Subject:     "Your NavMate receipt " + doc.Number,
+		Text:        strings.Join(Text(doc), "\n"),
+		HTML:        string(html),
+		Attachments: []mail.Attachment{{Name: doc.Number + ".pdf", ContentType: "application/pdf", Data: PDF(doc)}},
+	})
+	if err != nil {
+		return err
+	}
+	return is.receipts.MarkEmailed(ctx, rec, address, time.Now())
+}
+
+// EmailIfWanted emails rec to its owner if they turned on email_receipts
+// and mail is configured.
+func (is *Issuer) EmailIfWanted(ctx context.Context, rec *models.Receipt) error {
+	if is.mail == nil {
+		return nil
+	}
+	pref, err := is.prefs.Get(ctx, rec.UserID)
+	if err != nil || !pref.EmailReceipts {
+		return err
+	}
+	var user models.User
+	if err := is.db.WithContext(ctx).First(&user, rec.UserID).Error; err != nil {
+		return err
+	}
+	return is.Email(ctx, rec, user.Email)
+}
@@ -0,0 +1,92 @@
+package receipts
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// PDF lays the text version out on A4 pages in Courier, one of the standard
+// PDF fonts, so no font has to be embedded. The standard fonts only cover
+// Latin-1; other characters (e.g. Thai place names) print as '?', the HTML
+// version has them.
+func PDF(doc Document) []byte {
+	const (
+		pageW, pageH = 595, 842
+		margin       = 50
+		size         = 9.0
+		leading      = 13
+	)
+	perPage := (pageH - 2*margin) / leading
+	lines := Text(doc)
+	var pages [][]string
+	for len(lines) > 0 {
+		n := min(perPage, len(lines))
+		pages = append(pages, lines[:n])
+		lines = lines[n:]
+	}
+
+	var objs []string // object i+1
+	add := func(s string) int { objs = append(objs, s); return len(objs) }
+	add("<< /Type /Catalog /Pages 2 0 R >>")
+	add("") // pages, filled in below
+	add("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	add("<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>")
+
+	kids := make([]string, 0, len(pages))
+	for i, page := range pages {
+		var content strings.Builder
+		y := float64(pageH - margin)
+		for j, line := range page {
+			font, sz := "/F1", size
+			if i == 0 && j == 0 {
+				font, sz = "/F2", 14 // the title
+			}
+			fmt.Fprintf(&content, "BT %s %g Tf 1 0 0 1 %d %g Tm (%s) Tj ET\n", font, sz, margin, y, pdfString(line))
+			y -= leading
+		}
+		stream := content.String()
+		c := add(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(stream), stream))
+		p := add(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Contents %d 0 R /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> >>",
+			pageW, pageH, c))
+		kids = append(kids, fmt.Sprintf("%d 0 R", p))
+	}
+	objs[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objs))
+	for i, o := range objs {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info << /Title (%s) >> >>\nstartxref\n%d\n%%%%EOF\n",
+		len(objs)+1, pdfString(doc.Number), xref)
+	return buf.Bytes()
+}
+
+// pdfString escapes s for a PDF literal string in WinAnsi (Latin-1) encoding.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '→':
+			b.WriteString("->")
+		case r < 32 || r > 255:
+			b.WriteByte('?')
+		case r > 126:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,117 @@
+package receipts
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"navmate-backend/internal/money"
+)
+
+func amount(cents int, currency string) string { return money.New(cents, currency).String() }
+
+var htmlTmpl = template.Must(template.New("receipt").Funcs(template.FuncMap{"amount": amount, "km": km}).Parse(`<!DOCTYPE html>
+<html lang="th">
+<head>
+<meta charset="utf-8">
+<title>{{.Number}}</title>
+<style>
+body { font-family: sans-serif; max-width: 720px; margin: 2em auto; color: #222; }
+table { width: 100%; border-collapse: collapse; margin: 1em 0; }
+th, td { text-align: left; padding: 4px 6px; border-bottom: 1px solid #ddd; }
+td.num, th.num { text-align: right; }
+.parties { display: flex; justify-content: space-between; gap: 2em; }
+.total td { font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>{{if .TaxInvoice}}ใบเสร็จรับเงิน/ใบกำกับภาษี · Receipt / Tax Invoice{{else}}ใบเสร็จรับเงิน · Receipt{{end}}</h1>
+<p>เลขที่ No. <strong>{{.Number}}</strong> · วันที่ Date {{.IssuedAt.Format "2006-01-02 15:04 MST"}}</p>
+<div class="parties">
+<div><h3>ผู้ขาย Seller</h3>
+<p>{{.Seller.Name}}{{with .Seller.TaxID}}<br>เลขประจำตัวผู้เสียภาษี Tax ID {{.}}{{end}}{{with .Seller.Address}}<br>{{.}}{{end}}{{with .Seller.Email}}<br>{{.}}{{end}}</p></div>
+<div><h3>ผู้ซื้อ Buyer</h3>
+<p>{{.Buyer.Name}}{{with .Buyer.TaxID}}<br>เลขประจำตัวผู้เสียภาษี Tax ID {{.}}{{end}}{{with .Buyer.Address}}<br>{{.}}{{end}}{{with .Buyer.Email}}<br>{{.}}{{end}}</p></div>
+</div>
+<h3>การเดินทาง Trip</h3>
+<p>{{.Trip.Origin}} → {{.Trip.Destination}}{{with .Trip.DepartAt}} · {{.Format "2006-01-02 15:04"}}{{end}}{{with .Trip.ModeMix}} · {{.}}{{end}}</p>
+<table>
+<tr><th>#</th><th>Mode</th><th>From</th><th>To</th><th class="num">km</th><th class="num">min</th><th>Provider</th><th class="num">Fare</th></tr>
+{{range .Legs}}<tr><td>{{.Index}}</td><td>{{.Mode}}</td><td>{{.From}}</td><td>{{.To}}</td><td class="num">{{km .DistanceM}}</td><td class="num">{{.Minutes}}</td><td>{{.Provider}}{{with .BookingID}} (#{{.}}){{end}}</td><td class="num">{{if .FareCents}}{{amount .FareCents $.Currency}}{{end}}</td></tr>
+{{end}}</table>
+<table>
+{{range .Lines}}<tr><td>{{.Description}}</td><td class="num">{{amount .AmountCents $.Currency}}</td></tr>
+{{end}}<tr><td>มูลค่าก่อนภาษี Net</td><td class="num">{{amount .NetCents .Currency}}</td></tr>
+<tr><td>ภาษีมูลค่าเพิ่ม VAT {{.TaxRate}}%</td><td class="num">{{amount .TaxCents .Currency}}</td></tr>
+<tr class="total"><td>รวมทั้งสิ้น Total</td><td class="num">{{amount .TotalCents .Currency}}</td></tr>
+</table>
+<p>ชำระผ่าน Paid via {{.Gateway}} · {{.ExternalRef}}</p>
+</body>
+</html>
+`))
+
+func km(m int64) string { return fmt.Sprintf("%.1f", float64(m)/1000) }
+
+// HTML renders the receipt as a standalone page.
+func HTML(doc Document) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlTmpl.Execute(&buf, doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Text is the plain text version, used as the body of receipt emails and
+// laid out in the PDF.
+func Text(doc Document) []string {
+	title := "Receipt"
+	if doc.TaxInvoice {
+		title = "Receipt / Tax Invoice"
+	}
+	lines := []string{
+		title,
+		"No. " + doc.Number + "   Date " + doc.IssuedAt.Format("2006-01-02 15:04 MST"),
+		"",
+		"Seller: " + partyLine(doc.Seller),
+		"Buyer:  " + partyLine(doc.Buyer),
+		"",
+		"Trip: " + doc.Trip.Origin + " -> " + doc.Trip.Destination,
+	}
+	for _, l := range doc.Legs {
+		s := fmt.Sprintf("  %d. %s %s -> %s, %s km, %d min", l.Index, l.Mode, l.From, l.To, km(l.DistanceM), l.Minutes)
+		if l.Provider != "" {
+			s += ", " + l.Provider
+		}
+		if l.FareCents != 0 {
+			s += ", " + amount(l.FareCents, doc.Currency)
+		}
+		lines = append(lines, s)
+	}
+	lines = append(lines, "")
+	for _, l := range doc.Lines {
+		lines = append(lines, fmt.Sprintf("%-32s %16s", l.Description, amount(l.AmountCents, doc.Currency)))
+	}
+	lines = append(lines,
+		fmt.Sprintf("%-32s %16s", "Net", amount(doc.NetCents, doc.Currency)),
+		fmt.Sprintf("%-32s %16s", fmt.Sprintf("VAT %g%%", doc.TaxRate), amount(doc.TaxCents, doc.Currency)),
+		fmt.Sprintf("%-32s %16s", "Total", amount(doc.TotalCents, doc.Currency)),
+		"",
+		"Paid via "+doc.Gateway+" "+doc.ExternalRef,
+	)
+	return lines
+}
+
+func partyLine(p Party) string {
+	parts := []string{p.Name}
+	if p.TaxID != "" {
+		parts = append(parts, "Tax ID "+p.TaxID)
+	}
+	if p.Address != "" {
+		parts = append(parts, p.Address)
+	}
+	if p.Email != "" {
+		parts = append(parts, p.Email)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"navmate-backend/internal/adapters/ride"
+	"navmate-backend/internal/events"
+	"navmate-backend/internal/models"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid booking status transition")
+	ErrStaleBooking      = errors.New("booking status changed concurrently")
+)
+
+// BookingRepository persists ride bookings and enforces the booking state
+// machine; every status change is recorded as a BookingEvent and published
+// to the owner's event stream.
+type BookingRepository struct {
+	db  *gorm.DB
+	pub events.Publisher // optional
+}
+
+func NewBookingRepository(db *gorm.DB, pub events.Publisher) *BookingRepository {
+	return &BookingRepository{db: db, pub: pub}
+}
+
+// Create inserts a booking together with its creation event.
+func (r *BookingRepository) Create(ctx context.Context, b *models.RideBooking, actor string) error {
+	return r.CreateGroup(ctx, []*models.RideBooking{b}, actor)
+}
+
+// CreateGroup inserts the bookings of several legs in one transaction, so
+// either every leg is claimed or none is.
+func (r *BookingRepository) CreateGroup(ctx context.Context, bs []*models.RideBooking, actor string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, b := range bs {
+			if err := tx.Create(b).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&models.BookingEvent{BookingID: b.ID, ToStatus: b.Status, Actor: actor}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete removes a booking that never reached the provider, with its history.
+func (r *BookingRepository) Delete(ctx context.Context, b *models.RideBooking) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := releaseDiscounts(tx, b); err != nil {
+			return err
+		}
+		if err := tx.Where("booking_id = ?", b.ID).Delete(&models.BookingEvent{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(b).Error
+	})
+}
+
+// Transition saves b and moves it to status `to`. The update only applies if
+// the stored status is still b.Status, so concurrent writers cannot skip states.
+// Moving to the current status just saves the other fields.
+func (r *BookingRepository) Transition(ctx context.Context, b *models.RideBooking, to, actor, note string) error {
+	return r.TransitionAll(ctx, []*models.RideBooking{b}, to, actor, note)
+}
+
+// TransitionAll moves several bookings to `to` in one transaction: if any of
+// them is invalid or stale, none is changed.
+func (r *BookingRepository) TransitionAll(ctx context.Context, bs []*models.RideBooking, to, actor, note string) error {
+	from := make([]string, len(bs))
+	for i, b := range bs {
+		from[i] = b.Status
+		if b.Status != to && !models.CanTransition(b.Status, to) {
+			return ErrInvalidTransition
+		}
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, b := range bs {
+			b.Status = to
+			if err := transition(tx, b, from[i], actor, note); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for i, b := range bs {
+			b.Status = from[i]
+		}
+		return err
+	}
+	for _, b := range bs {
+		r.publish(ctx, b)
+	}
+	return nil
+}
+
+// transition writes b, already set to its new status, if it is still in from.
+// payment_id and payment_status belong to PaymentRepository and the discount
+// columns to PromotionRepository; none is written from here, so a booking read
+// before a payment was linked cannot unlink it. A booking that ends
+// without a ride gives its promotion and credit back; one cancelled once the
+// trip had started keeps them, as it is paid for.
+func transition(tx *gorm.DB, b *models.RideBooking, from, actor, note string) error {
+	res := tx.Model(&models.RideBooking{}).
+		Where("id = ? AND status = ?", b.ID, from).
+		Select("*").Omit("id", "created_at", "payment_id", "payment_status",
+		"promotion_id", "promo_code", "discount_cents", "credit_cents").
+		Updates(b)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrStaleBooking
+	}
+	if from == b.Status {
+		return nil
+	}
+	if models.IsTerminal(b.Status) && b.Status != models.BookingCompleted && from != models.BookingInProgress {
+		if err := releaseDiscounts(tx, b); err != nil {
+			return err
+		}
+	}
+	return tx.Create(&models.BookingEvent{
+		BookingID: b.ID, FromStatus: from, ToStatus: b.Status, Actor: actor, Note: note,
+	}).Error
+}
+
+// publish is best effort: the booking is already saved and clients can re-read it.
+func (r *BookingRepository) publish(ctx context.Context, b *models.RideBooking) {
+	if r.pub == nil {
+		return
+	}
+	var userID uint
+	if err := r.db.WithContext(ctx).Model(&models.TripPlan{}).Where("id = ?", b.PlanID).
+		Pluck("user_id", &userID).Error; err != nil || userID == 0 {
+		return
+	}
+	data := map[string]any{
+		"booking_id": b.ID, "plan_id": b.PlanID, "status": b.Status, "eta_minutes": b.EtaMinutes,
+	}
+	if b.DriverName != "" {
+		data["driver"] = map[string]any{
+			"name": b.DriverName, "vehicle_plate": b.VehiclePlate, "vehicle_model": b.VehicleModel,
+			"lat": b.DriverLat, "lng": b.DriverLng, "location_at": b.DriverLocationAt,
+		}
+	}
+	_ = r.pub.Publish(ctx, events.Event{Type: events.TypeBookingUpdated, UserID: userID, Data: data})
+}
+
+// AddNote adds a history entry that leaves the status unchanged, such as a
+// failed provider attempt or a rescheduled pickup.
+func (r *BookingRepository) AddNote(ctx context.Context, b *models.RideBooking, actor, note string) error {
+	return r.db.WithContext(ctx).Create(&models.BookingEvent{
+		BookingID: b.ID, FromStatus: b.Status, ToStatus: b.Status, Actor: actor, Note: note,
+	}).Error
+}
+
+// ApplyProviderUpdate merges a polled or signed webhook status update into
+// the booking. Driver details are kept even when the status change is
+// rejected. A trip the provider cancels after pickup is charged its fare as
+// the cancellation fee; before pickup the cancellation is free.
+func (r *BookingRepository) ApplyProviderUpdate(ctx context.Context, b *models.RideBooking, u ride.StatusUpdate) error {
+	changed := false
+	if u.EtaMinutes > 0 && u.EtaMinutes != b.EtaMinutes {
+		b.EtaMinutes, changed = u.EtaMinutes, true
+	}
+	if d := u.Driver; d != nil {
+		if d.Name != b.DriverName || d.VehiclePlate != b.VehiclePlate || d.VehicleModel != b.VehicleModel {
+			b.DriverName, b.VehiclePlate, b.VehicleModel, changed = d.Name, d.VehiclePlate, d.VehicleModel, true
+		}
+		if d.Lat != nil && d.Lng != nil && !(sameCoord(d.Lat, b.DriverLat) && sameCoord(d.Lng, b.DriverLng)) {
+			now := time.Now()
+			b.DriverLat, b.DriverLng, b.DriverLocationAt, changed = d.Lat, d.Lng, &now, true
+		}
+	}
+
+	// keep the driver details; the status stays
+	keep := func() error {
+		if !changed {
+			return nil // a poll that brings nothing new writes nothing
+		}
+		return r.Transition(ctx, b, b.Status, "provider", "")
+	}
+	if u.Status == "" || u.Status == b.Status {
+		return keep()
+	}
+	if !models.CanTransition(b.Status, u.Status) {
+		if err := keep(); err != nil {
+			return err
+		}
+		return ErrInvalidTransition
+	}
+	if u.Status == models.BookingCancelled {
+		now := time.Now()
+		b.CancelledAt, b.CancelledBy = &now, "provider"
+		if b.Status == models.BookingInProgress {
+			b.CancellationFeeCents = b.FareCents
+		}
+	}
+	return r.Transition(ctx, b, u.Status, "provider", "")
+}
+
+// Events returns the booking's transition history, oldest first.
+func (r *BookingRepository) Events(ctx context.Context, bookingID uint) ([]models.BookingEvent, error) {
+	var events []models.BookingEvent
+	err := r.db.WithContext(ctx).Where("booking_id = ?", bookingID).Order("id ASC").Find(&events).Error
+	return events, err
+}
+
+// Active returns bookings the provider may still move forward.
+func (r *BookingRepository) Active(ctx context.Context) ([]models.RideBooking, error) {
+	var list []models.RideBooking
+	err := r.db.WithContext(ctx).
+		Where("status IN ? AND external_ref <> ''", []string{
+			models.BookingConfirmed, models.BookingDriverAssigned, models.BookingInProgress,
+		}).
+		Find(&list).Error
+	return list, err
+}
+
+func sameCoord(a, b *float64) bool {
+	return b != nil && *a == *b
+}
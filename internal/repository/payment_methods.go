@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"navmate-backend/internal/models"
+)
+
+type PaymentMethodRepository struct{ db *gorm.DB }
+
+func NewPaymentMethodRepository(db *gorm.DB) *PaymentMethodRepository {
+	return &PaymentMethodRepository{db: db}
+}
+
+// List returns the user's payment methods, the default first.
+func (r *PaymentMethodRepository) List(ctx context.Context, userID uint) ([]models.PaymentMethod, error) {
+	var list []models.PaymentMethod
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("is_default DESC, id ASC").Find(&list).Error
+	return list, err
+}
+
+// Get returns one of the user's payment methods.
+func (r *PaymentMethodRepository) Get(ctx context.Context, userID, id uint) (models.PaymentMethod, error) {
+	var m models.PaymentMethod
+	err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&m).Error
+	return m, err
+}
+
+// Default returns the user's default payment method; found is false if the
+// user has none.
+func (r *PaymentMethodRepository) Default(ctx context.Context, userID uint) (m models.PaymentMethod, found bool, err error) {
+	err = r.db.WithContext(ctx).Where("user_id = ? AND is_default", userID).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return m, false, nil
+	}
+	return m, err == nil, err
+}
+
+// Create saves a new method. The user's first method becomes the default.
+func (r *PaymentMethodRepository) Create(ctx context.Context, m *models.PaymentMethod) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Model(&models.PaymentMethod{}).Where("user_id = ?", m.UserID).Count(&n).Error; err != nil {
+			return err
+		}
+		makeDefault := m.IsDefault || n == 0
+		m.IsDefault = false
+		if err := tx.Create(m).Error; err != nil {
+			return err
+		}
+		if makeDefault {
+			return setDefault(tx, m)
+		}
+		return nil
+	})
+}
+
+// Save updates a method's label and, if m.IsDefault, makes it the default.
+func (r *PaymentMethodRepository) Save(ctx context.Context, m *models.PaymentMethod) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(m).Update("label", m.Label).Error; err != nil {
+			return err
+		}
+		if m.IsDefault {
+			return setDefault(tx, m)
+		}
+		return nil
+	})
+}
+
+// Delete removes a method; if it was the default, the oldest remaining one
+// takes over.
+func (r *PaymentMethodRepository) Delete(ctx context.Context, m *models.PaymentMethod) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(m).Error; err != nil {
+			return err
+		}
+		if !m.IsDefault {
+			return nil
+		}
+		var next models.PaymentMethod
+		err := tx.Where("user_id = ?", m.UserID).Order("id ASC").First(&next).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return setDefault(tx, &next)
+	})
+}
+
+// setDefault clears the previous default first, for the one-default index.
+func setDefault(tx *gorm.DB, m *models.PaymentMethod) error {
+	if err := tx.Model(&models.PaymentMethod{}).Where("user_id = ? AND is_default AND id <> ?", m.UserID, m.ID).
+		Update("is_default", false).Error; err != nil {
+		return err
+	}
+	m.IsDefault = true
+	return tx.Model(m).Update("is_default", true).Error
+}
@@ -0,0 +1,384 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"navmate-backend/internal/adapters/payment"
+	"navmate-backend/internal/events"
+	"navmate-backend/internal/models"
+)
+
+var (
+	// ErrWebhookNotClaimed means another worker is processing the webhook
+	// event, or it was processed meanwhile.
+	ErrWebhookNotClaimed = errors.New("webhook event already claimed")
+	// ErrPaymentExists means the booking already has a payment.
+	ErrPaymentExists = errors.New("booking already has a payment")
+	// ErrWebhookNotReplayable is returned for events that did not fail.
+	ErrWebhookNotReplayable = errors.New("only failed or ignored webhook events can be replayed")
+	// ErrAlreadyApplied means the ledger already has an entry for the
+	// client's Idempotency-Key.
+	ErrAlreadyApplied = errors.New("payment change already applied")
+)
+
+// PaymentRepository persists payments and gateway webhooks. Saving a payment
+// mirrors its status onto the bookings it pays for and notifies the owner.
+type PaymentRepository struct {
+	db  *gorm.DB
+	pub events.Publisher // optional
+}
+
+func NewPaymentRepository(db *gorm.DB, pub events.Publisher) *PaymentRepository {
+	return &PaymentRepository{db: db, pub: pub}
+}
+
+// Claim inserts p and links it to booking b before the gateway is called,
+// so two requests cannot both authorize the booking. It fails with
+// ErrPaymentExists when b was linked to another payment since it was read;
+// replacing a payment that b still points at is the caller's decision.
+func (r *PaymentRepository) Claim(ctx context.Context, p *models.Payment, b *models.RideBooking) error {
+	prev := b.PaymentID
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(p).Error; err != nil {
+			return err
+		}
+		q := tx.Model(&models.RideBooking{}).Where("id = ?", b.ID)
+		if prev == nil {
+			q = q.Where("payment_id IS NULL")
+		} else {
+			q = q.Where("payment_id = ?", *prev)
+		}
+		res := q.Updates(map[string]any{"payment_id": p.ID, "payment_status": p.Status})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrPaymentExists
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	b.PaymentID, b.PaymentStatus = &p.ID, p.Status
+	return nil
+}
+
+// Release undoes Claim when the gateway could not be reached: p is deleted
+// and b points at prev (its earlier payment, or none) again.
+func (r *PaymentRepository) Release(ctx context.Context, p *models.Payment, b *models.RideBooking, prev *models.Payment) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		link := map[string]any{"payment_id": nil, "payment_status": ""}
+		if prev != nil {
+			link = map[string]any{"payment_id": prev.ID, "payment_status": prev.Status}
+		}
+		if err := tx.Model(&models.RideBooking{}).Where("id = ? AND payment_id = ?", b.ID, p.ID).Updates(link).Error; err != nil {
+			return err
+		}
+		return tx.Delete(p).Error
+	})
+	if err != nil {
+		return err
+	}
+	b.PaymentID, b.PaymentStatus = nil, ""
+	if prev != nil {
+		b.PaymentID, b.PaymentStatus = &prev.ID, prev.Status
+	}
+	return nil
+}
+
+// Save updates the payment and the payment_status of what it pays for.
+func (r *PaymentRepository) Save(ctx context.Context, p *models.Payment) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(p).Error; err != nil {
+			return err
+		}
+		return mirrorStatus(tx, p)
+	})
+	if err != nil {
+		return err
+	}
+	r.publish(ctx, p)
+	return nil
+}
+
+// mirrorStatus copies the payment's status to what it pays for: bookings,
+// or the share of a split fare.
+func mirrorStatus(tx *gorm.DB, p *models.Payment) error {
+	if err := tx.Model(&models.RideBooking{}).Where("payment_id = ?", p.ID).
+		Update("payment_status", p.Status).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.SplitShare{}).Where("payment_id = ?", p.ID).
+		Update("payment_status", p.Status).Error
+}
+
+// LedgerMeta describes who caused the ledger entries ApplyCharge writes.
+type LedgerMeta struct {
+	Actor          string // user|system|gateway, or an admin's email
+	Note           string
+	IdempotencyKey string // the client's key for a refund
+}
+
+// ApplyCharge brings the payment in line with the gateway's view of it: the
+// money that moved since the ledger was last written is appended as entries,
+// and the payment's balances and status are derived from the ledger.
+func (r *PaymentRepository) ApplyCharge(ctx context.Context, p *models.Payment, ch payment.Charge, meta LedgerMeta) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// serializes a webhook and a request applying the same change
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Payment{}, p.ID).Error; err != nil {
+			return err
+		}
+		var ledger []models.PaymentTransaction
+		if err := tx.Where("payment_id = ?", p.ID).Order("id ASC").Find(&ledger).Error; err != nil {
+			return err
+		}
+		return applyCharge(tx, p, ledger, ch, meta)
+	})
+	if err != nil {
+		return err
+	}
+	r.publish(ctx, p)
+	return nil
+}
+
+// Settle calls the gateway through op while holding p's row lock and applies
+// the charge op returns, so captures and refunds of one payment run one at a
+// time, each seeing the ledger the previous one wrote. op gets the payment as
+// stored and its balance; an error from it changes nothing. When the ledger
+// already has meta.IdempotencyKey, op is not called and ErrAlreadyApplied is
+// returned. p is reloaded either way.
+func (r *PaymentRepository) Settle(ctx context.Context, p *models.Payment, meta LedgerMeta,
+	op func(p models.Payment, bal models.PaymentBalance) (payment.Charge, error)) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(p, p.ID).Error; err != nil {
+			return err
+		}
+		var ledger []models.PaymentTransaction
+		if err := tx.Where("payment_id = ?", p.ID).Order("id ASC").Find(&ledger).Error; err != nil {
+			return err
+		}
+		if meta.IdempotencyKey != "" {
+			for _, t := range ledger {
+				if t.IdempotencyKey == meta.IdempotencyKey {
+					return ErrAlreadyApplied
+				}
+			}
+		}
+		ch, err := op(*p, models.Balance(ledger))
+		if err != nil {
+			return err
+		}
+		return applyCharge(tx, p, ledger, ch, meta)
+	})
+	if err != nil {
+		return err
+	}
+	r.publish(ctx, p)
+	return nil
+}
+
+// applyCharge appends what moved since ledger to it and saves p; the caller
+// holds p's row lock.
+func applyCharge(tx *gorm.DB, p *models.Payment, ledger []models.PaymentTransaction, ch payment.Charge, meta LedgerMeta) error {
+	bal := models.Balance(ledger)
+	entry := func(kind string, amount int) models.PaymentTransaction {
+		return models.PaymentTransaction{
+			PaymentID: p.ID, Kind: kind, AmountCents: amount,
+			Actor: meta.Actor, Note: meta.Note, IdempotencyKey: meta.IdempotencyKey,
+		}
+	}
+	var add []models.PaymentTransaction
+	if bal.AuthorizedCents == 0 && ch.Status != payment.StatusPending && ch.Status != payment.StatusDeclined {
+		add = append(add, entry(models.TxAuthorize, p.AmountCents))
+	}
+	if d := ch.CapturedCents - bal.CapturedCents; d > 0 {
+		add = append(add, entry(models.TxCapture, d))
+	}
+	if d := ch.RefundedCents - bal.RefundedCents; d > 0 {
+		add = append(add, entry(models.TxRefund, d))
+	}
+	if (ch.Status == payment.StatusVoided || ch.Status == payment.StatusExpired) && !bal.Voided && ch.CapturedCents == 0 {
+		add = append(add, entry(models.TxVoid, p.AmountCents))
+	}
+	if len(add) > 0 {
+		if err := tx.Create(&add).Error; err != nil {
+			return err
+		}
+	}
+
+	bal = models.Balance(append(ledger, add...))
+	p.CapturedCents, p.RefundedCents = bal.CapturedCents, bal.RefundedCents
+	p.FailureCode = ch.FailureCode
+	p.Status = paymentStatus(p.Status, ch.Status, bal)
+	if err := tx.Save(p).Error; err != nil {
+		return err
+	}
+	return mirrorStatus(tx, p)
+}
+
+// paymentStatus is the gateway's status while no money moved, otherwise the
+// one the ledger balance says. An open dispute is only known from its
+// webhook (the charge still reads as captured), so it sticks until a refund
+// or the dispute's closing webhook.
+func paymentStatus(current, gatewayStatus string, bal models.PaymentBalance) string {
+	if current == payment.StatusDisputed && gatewayStatus == payment.StatusCaptured {
+		return current
+	}
+	switch gatewayStatus {
+	case payment.StatusPending, payment.StatusDeclined, payment.StatusVoided, payment.StatusExpired:
+		return gatewayStatus
+	}
+	switch {
+	case bal.RefundedCents > 0 && bal.RefundedCents >= bal.CapturedCents:
+		return payment.StatusRefunded
+	case bal.RefundedCents > 0:
+		return payment.StatusPartiallyRefunded
+	case bal.CapturedCents > 0:
+		return payment.StatusCaptured
+	default:
+		return payment.StatusAuthorized
+	}
+}
+
+// Transactions returns the payment's ledger, oldest first.
+func (r *PaymentRepository) Transactions(ctx context.Context, paymentID uint) ([]models.PaymentTransaction, error) {
+	var list []models.PaymentTransaction
+	err := r.db.WithContext(ctx).Where("payment_id = ?", paymentID).Order("id ASC").Find(&list).Error
+	return list, err
+}
+
+// HasTransaction reports whether an entry was already written for the
+// client's Idempotency-Key.
+func (r *PaymentRepository) HasTransaction(ctx context.Context, paymentID uint, idempotencyKey string) (bool, error) {
+	var n int64
+	err := r.db.WithContext(ctx).Model(&models.PaymentTransaction{}).
+		Where("payment_id = ? AND idempotency_key = ?", paymentID, idempotencyKey).Count(&n).Error
+	return n > 0, err
+}
+
+// ByExternalRef finds the payment the gateway knows as ref.
+func (r *PaymentRepository) ByExternalRef(ctx context.Context, gateway, ref string) (models.Payment, error) {
+	var p models.Payment
+	err := r.db.WithContext(ctx).Where("gateway = ? AND external_ref = ?", gateway, ref).First(&p).Error
+	return p, err
+}
+
+// publish is best effort, like BookingRepository.publish.
+func (r *PaymentRepository) publish(ctx context.Context, p *models.Payment) {
+	if r.pub == nil {
+		return
+	}
+	var (
+		userID, bookingID uint
+		b                 models.RideBooking
+		share             models.SplitShare
+	)
+	if err := r.db.WithContext(ctx).Where("payment_id = ?", p.ID).First(&b).Error; err == nil {
+		bookingID = b.ID
+		if err := r.db.WithContext(ctx).Model(&models.TripPlan{}).Where("id = ?", b.PlanID).
+			Pluck("user_id", &userID).Error; err != nil {
+			return
+		}
+	} else if err := r.db.WithContext(ctx).Where("payment_id = ?", p.ID).First(&share).Error; err == nil {
+		// a share of a split fare is paid by its participant
+		userID = share.UserID
+		if err := r.db.WithContext(ctx).Model(&models.FareSplit{}).Where("id = ?", share.SplitID).
+			Pluck("booking_id", &bookingID).Error; err != nil {
+			return
+		}
+	}
+	if userID == 0 {
+		return
+	}
+	_ = r.pub.Publish(ctx, events.Event{
+		Type: events.TypePaymentUpdated, UserID: userID,
+		Data: map[string]any{
+			"payment_id": p.ID, "booking_id": bookingID, "status": p.Status,
+			"amount_cents": p.AmountCents, "captured_cents": p.CapturedCents, "refunded_cents": p.RefundedCents,
+		},
+	})
+}
+
+// RecordWebhook stores a verified webhook event. A redelivery of an event
+// already stored returns false and leaves the stored copy untouched.
+func (r *PaymentRepository) RecordWebhook(ctx context.Context, ev *models.PaymentWebhookEvent) (bool, error) {
+	res := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(ev)
+	return res.RowsAffected == 1, res.Error
+}
+
+// PendingWebhooks returns events waiting to be processed, oldest first,
+// including ones stuck in processing for longer than stuckAfter (the worker
+// died halfway).
+func (r *PaymentRepository) PendingWebhooks(ctx context.Context, stuckAfter time.Duration, limit int) ([]models.PaymentWebhookEvent, error) {
+	var list []models.PaymentWebhookEvent
+	err := r.db.WithContext(ctx).
+		Where("status = ? OR (status = ? AND updated_at < ?)",
+			models.WebhookReceived, models.WebhookProcessing, time.Now().Add(-stuckAfter)).
+		Order("id ASC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// ClaimWebhook moves ev to processing unless someone else claimed it since it
+// was read; every claim bumps Attempts, which doubles as the version.
+func (r *PaymentRepository) ClaimWebhook(ctx context.Context, ev *models.PaymentWebhookEvent) error {
+	res := r.db.WithContext(ctx).Model(&models.PaymentWebhookEvent{}).
+		Where("id = ? AND status = ? AND attempts = ?", ev.ID, ev.Status, ev.Attempts).
+		Updates(map[string]any{"status": models.WebhookProcessing, "attempts": ev.Attempts + 1})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrWebhookNotClaimed
+	}
+	ev.Status = models.WebhookProcessing
+	ev.Attempts++
+	return nil
+}
+
+// FinishWebhook records the outcome of processing ev: status is processed,
+// ignored or failed (with err).
+func (r *PaymentRepository) FinishWebhook(ctx context.Context, ev *models.PaymentWebhookEvent, status string, err error) error {
+	now := time.Now()
+	ev.Status, ev.LastError = status, ""
+	if err != nil {
+		ev.LastError = err.Error()
+	}
+	if status != models.WebhookFailed {
+		ev.ProcessedAt = &now
+	}
+	return r.db.WithContext(ctx).Model(ev).
+		Updates(map[string]any{"status": ev.Status, "last_error": ev.LastError, "processed_at": ev.ProcessedAt}).Error
+}
+
+// ReplayWebhook queues a failed (or ignored) event to be processed again.
+func (r *PaymentRepository) ReplayWebhook(ctx context.Context, id uint) (models.PaymentWebhookEvent, error) {
+	var ev models.PaymentWebhookEvent
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ev, id).Error; err != nil {
+			return err
+		}
+		if ev.Status != models.WebhookFailed && ev.Status != models.WebhookIgnored {
+			return ErrWebhookNotReplayable
+		}
+		ev.Status = models.WebhookReceived
+		return tx.Model(&ev).Updates(map[string]any{"status": ev.Status}).Error
+	})
+	return ev, err
+}
+
+// Webhooks lists stored events, newest first; status "" means all.
+func (r *PaymentRepository) Webhooks(ctx context.Context, status string, limit int) ([]models.PaymentWebhookEvent, error) {
+	q := r.db.WithContext(ctx).Order("id DESC").Limit(limit)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	var list []models.PaymentWebhookEvent
+	err := q.Find(&list).Error
+	return list, err
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"navmate-backend/internal/models"
+)
+
+type PreferenceRepository struct{ db *gorm.DB }
+
+func NewPreferenceRepository(db *gorm.DB) *PreferenceRepository { return &PreferenceRepository{db: db} }
+
+// Get returns the user's saved preferences, or the defaults if none were saved.
+func (r *PreferenceRepository) Get(ctx context.Context, userID uint) (models.UserPreference, error) {
+	var p models.UserPreference
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultPreference(userID), nil
+	}
+	return p, err
+}
+
+// Save upserts the user's preferences.
+func (r *PreferenceRepository) Save(ctx context.Context, p *models.UserPreference) error {
+	var existing models.UserPreference
+	err := r.db.WithContext(ctx).Where("user_id = ?", p.UserID).First(&existing).Error
+	if err == nil {
+		p.ID = existing.ID
+		p.CreatedAt = existing.CreatedAt
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return r.db.WithContext(ctx).Save(p).Error
+}
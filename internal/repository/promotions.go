@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"navmate-backend/internal/adapters/fx"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/money"
+)
+
+var ErrPromoExists = errors.New("promo code already exists")
+
+// PromoError is why a promo code can't be used. Code is returned to clients,
+// see models.Promotion.Unavailable for most of them.
+type PromoError struct{ Code string }
+
+func (e *PromoError) Error() string {
+	return "promo code: " + strings.ReplaceAll(strings.TrimPrefix(e.Code, "promo_"), "_", " ")
+}
+
+// PromotionRepository owns promotions, their redemptions and user credits,
+// and the discount columns of ride bookings.
+type PromotionRepository struct {
+	db    *gorm.DB
+	rates fx.RateSource
+}
+
+func NewPromotionRepository(db *gorm.DB, rates fx.RateSource) *PromotionRepository {
+	return &PromotionRepository{db: db, rates: rates}
+}
+
+// NormalizeCode is how codes are stored and looked up.
+func NormalizeCode(code string) string { return strings.ToUpper(strings.TrimSpace(code)) }
+
+func (r *PromotionRepository) Create(ctx context.Context, p *models.Promotion) error {
+	p.Code = NormalizeCode(p.Code)
+	var n int64
+	if err := r.db.WithContext(ctx).Model(&models.Promotion{}).Where("code = ?", p.Code).Count(&n).Error; err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrPromoExists
+	}
+	return r.db.WithContext(ctx).Create(p).Error
+}
+
+// List returns promotions, newest first.
+func (r *PromotionRepository) List(ctx context.Context, limit int) ([]models.Promotion, error) {
+	var list []models.Promotion
+	err := r.db.WithContext(ctx).Order("id DESC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+func (r *PromotionRepository) Get(ctx context.Context, id uint) (models.Promotion, error) {
+	var p models.Promotion
+	err := r.db.WithContext(ctx).First(&p, id).Error
+	return p, err
+}
+
+// Save updates a promotion's settings. used_count belongs to redemptions.
+func (r *PromotionRepository) Save(ctx context.Context, p *models.Promotion) error {
+	return r.db.WithContext(ctx).Model(&models.Promotion{}).Where("id = ?", p.ID).
+		Select("*").Omit("id", "code", "created_at", "used_count").Updates(p).Error
+}
+
+// Lookup returns the promotion with code if userID may redeem it at now.
+func (r *PromotionRepository) Lookup(ctx context.Context, code string, userID uint, now time.Time) (models.Promotion, error) {
+	var p models.Promotion
+	err := r.db.WithContext(ctx).Where("code = ?", NormalizeCode(code)).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return p, &PromoError{Code: "promo_not_found"}
+	}
+	if err != nil {
+		return p, err
+	}
+	if reason := p.Unavailable(now); reason != "" {
+		return p, &PromoError{Code: reason}
+	}
+	if err := checkUserUses(r.db.WithContext(ctx), p, userID); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+func checkUserUses(tx *gorm.DB, p models.Promotion, userID uint) error {
+	if p.MaxUsesPerUser <= 0 {
+		return nil
+	}
+	var n int64
+	err := tx.Model(&models.PromotionRedemption{}).
+		Where("promotion_id = ? AND user_id = ? AND status = ?", p.ID, userID, models.RedemptionApplied).
+		Count(&n).Error
+	if err != nil {
+		return err
+	}
+	if n >= int64(p.MaxUsesPerUser) {
+		return &PromoError{Code: "promo_used"}
+	}
+	return nil
+}
+
+// Discount prices p on fare, converting the promotion's amounts at the
+// current rate when it was set up in another currency.
+func (r *PromotionRepository) Discount(ctx context.Context, p models.Promotion, fare money.Money) (int, error) {
+	rate := 1.0
+	if p.Kind == models.PromoFixed || p.MaxDiscountCents > 0 || p.MinFareCents > 0 {
+		var err error
+		if rate, err = r.rates.Rate(ctx, p.Currency, fare.Currency); err != nil {
+			return 0, err
+		}
+	}
+	return p.Discount(fare, rate), nil
+}
+
+// Apply records the discounts of booking b in one transaction: it redeems p
+// (nil for none) for discountCents and reserves up to maxCreditCents of the
+// user's credit in b's currency. The global and per-user limits are checked
+// again under lock, so concurrent bookings cannot overuse a code.
+func (r *PromotionRepository) Apply(ctx context.Context, userID uint, b *models.RideBooking, p *models.Promotion, discountCents, maxCreditCents int) error {
+	before := *b
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if p != nil {
+			res := tx.Model(&models.Promotion{}).
+				Where("id = ? AND active AND (max_uses = 0 OR used_count < max_uses)", p.ID).
+				UpdateColumn("used_count", gorm.Expr("used_count + 1"))
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return &PromoError{Code: "promo_exhausted"}
+			}
+			// the row lock taken above serializes this user's redemptions too
+			if err := checkUserUses(tx, *p, userID); err != nil {
+				return err
+			}
+			if err := tx.Create(&models.PromotionRedemption{
+				PromotionID: p.ID, UserID: userID, BookingID: b.ID, Status: models.RedemptionApplied,
+			}).Error; err != nil {
+				return err
+			}
+			b.PromotionID, b.PromoCode, b.DiscountCents = &p.ID, p.Code, discountCents
+		}
+
+		if maxCreditCents > 0 {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, userID).Error; err != nil {
+				return err
+			}
+			bal, err := creditBalance(tx, userID, b.Currency)
+			if err != nil {
+				return err
+			}
+			if spend := min(bal, maxCreditCents); spend > 0 {
+				if err := tx.Create(&models.CreditTransaction{
+					UserID: userID, Kind: models.CreditSpend, AmountCents: -spend, Currency: b.Currency,
+					BookingID: &b.ID, Actor: "system",
+				}).Error; err != nil {
+					return err
+				}
+				b.CreditCents = spend
+			}
+		}
+		return saveDiscounts(tx, b)
+	})
+	if err != nil {
+		b.PromotionID, b.PromoCode, b.DiscountCents, b.CreditCents = before.PromotionID, before.PromoCode, before.DiscountCents, before.CreditCents
+	}
+	return err
+}
+
+// Settle fixes b's discounts against its final fare when it is paid: the
+// discount is repriced and credit the fare no longer needs is given back.
+func (r *PromotionRepository) Settle(ctx context.Context, b *models.RideBooking, discountCents, creditCents int) error {
+	if discountCents == b.DiscountCents && creditCents == b.CreditCents {
+		return nil
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if unused := b.CreditCents - creditCents; unused > 0 {
+			var userID uint
+			if err := tx.Model(&models.TripPlan{}).Where("id = ?", b.PlanID).Pluck("user_id", &userID).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&models.CreditTransaction{
+				UserID: userID, Kind: models.CreditRefund, AmountCents: unused, Currency: b.Currency,
+				BookingID: &b.ID, Actor: "system", Note: "not needed for the final fare",
+			}).Error; err != nil {
+				return err
+			}
+		}
+		b.DiscountCents, b.CreditCents = discountCents, min(creditCents, b.CreditCents)
+		return saveDiscounts(tx, b)
+	})
+}
+
+func saveDiscounts(tx *gorm.DB, b *models.RideBooking) error {
+	return tx.Model(&models.RideBooking{}).Where("id = ?", b.ID).Updates(map[string]any{
+		"promotion_id": b.PromotionID, "promo_code": b.PromoCode,
+		"discount_cents": b.DiscountCents, "credit_cents": b.CreditCents,
+	}).Error
+}
+
+// releaseDiscounts gives back what a booking that won't be ridden held: its
+// promotion redemption and any credit it reserved.
+func releaseDiscounts(tx *gorm.DB, b *models.RideBooking) error {
+	var red models.PromotionRedemption
+	err := tx.Where("booking_id = ? AND status = ?", b.ID, models.RedemptionApplied).First(&red).Error
+	switch {
+	case err == nil:
+		if err := tx.Model(&red).Update("status", models.RedemptionReleased).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Promotion{}).Where("id = ? AND used_count > 0", red.PromotionID).
+			UpdateColumn("used_count", gorm.Expr("used_count - 1")).Error; err != nil {
+			return err
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return err
+	}
+
+	var held []struct {
+		UserID   uint
+		Currency string
+		Net      int
+	}
+	if err := tx.Model(&models.CreditTransaction{}).Select("user_id, currency, SUM(amount_cents) AS net").
+		Where("booking_id = ?", b.ID).Group("user_id, currency").Scan(&held).Error; err != nil {
+		return err
+	}
+	for _, h := range held {
+		if h.Net >= 0 {
+			continue
+		}
+		if err := tx.Create(&models.CreditTransaction{
+			UserID: h.UserID, Kind: models.CreditRefund, AmountCents: -h.Net, Currency: h.Currency,
+			BookingID: &b.ID, Actor: "system", Note: "booking " + b.Status,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func creditBalance(tx *gorm.DB, userID uint, currency string) (int, error) {
+	var bal int
+	err := tx.Model(&models.CreditTransaction{}).Select("COALESCE(SUM(amount_cents), 0)").
+		Where("user_id = ? AND currency = ?", userID, currency).Scan(&bal).Error
+	return bal, err
+}
+
+// CreditBalances returns the user's credit per currency, skipping empty ones.
+func (r *PromotionRepository) CreditBalances(ctx context.Context, userID uint) ([]money.Money, error) {
+	var rows []struct {
+		Currency string
+		Cents    int
+	}
+	err := r.db.WithContext(ctx).Model(&models.CreditTransaction{}).
+		Select("currency, SUM(amount_cents) AS cents").Where("user_id = ?", userID).
+		Group("currency").Order("currency").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make([]money.Money, 0, len(rows))
+	for _, row := range rows {
+		if row.Cents != 0 {
+			out = append(out, money.New(row.Cents, row.Currency))
+		}
+	}
+	return out, nil
+}
+
+// Credits returns the user's credit history, newest first.
+func (r *PromotionRepository) Credits(ctx context.Context, userID uint, limit int) ([]models.CreditTransaction, error) {
+	var list []models.CreditTransaction
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id DESC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// GrantCredit adds credit to a user's balance.
+func (r *PromotionRepository) GrantCredit(ctx context.Context, t *models.CreditTransaction) error {
+	t.Kind = models.CreditGrant
+	return r.db.WithContext(ctx).Create(t).Error
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"navmate-backend/internal/models"
+)
+
+// ReceiptRepository stores issued receipts and hands out their numbers.
+type ReceiptRepository struct{ db *gorm.DB }
+
+func NewReceiptRepository(db *gorm.DB) *ReceiptRepository { return &ReceiptRepository{db: db} }
+
+// Issue stores rec under the next number of year, formatted
+// PREFIX-YEAR-000001. The year's counter is locked until the receipt is
+// stored, so numbers are sequential without gaps. If the payment already has
+// a receipt, rec is replaced by it and created is false.
+func (r *ReceiptRepository) Issue(ctx context.Context, rec *models.Receipt, year int, prefix string) (created bool, err error) {
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.InvoiceCounter{Year: year}).Error; err != nil {
+			return err
+		}
+		var ctr models.InvoiceCounter
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("year = ?", year).First(&ctr).Error; err != nil {
+			return err
+		}
+
+		var existing models.Receipt
+		err := tx.Where("payment_id = ?", rec.PaymentID).First(&existing).Error
+		if err == nil {
+			*rec = existing
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		ctr.Last++
+		if err := tx.Model(&ctr).Where("year = ?", year).Update("last", ctr.Last).Error; err != nil {
+			return err
+		}
+		rec.Year, rec.Seq = year, ctr.Last
+		rec.Number = fmt.Sprintf("%s-%d-%06d", prefix, year, ctr.Last)
+		created = true
+		return tx.Create(rec).Error
+	})
+	return created, err
+}
+
+func (r *ReceiptRepository) ByPayment(ctx context.Context, paymentID uint) (models.Receipt, error) {
+	var rec models.Receipt
+	err := r.db.WithContext(ctx).Where("payment_id = ?", paymentID).First(&rec).Error
+	return rec, err
+}
+
+// MarkEmailed records that the receipt was sent to address.
+func (r *ReceiptRepository) MarkEmailed(ctx context.Context, rec *models.Receipt, address string, at time.Time) error {
+	rec.EmailedAt, rec.EmailedTo = &at, address
+	return r.db.WithContext(ctx).Model(rec).Updates(map[string]any{"emailed_at": at, "emailed_to": address}).Error
+}
+
+// Unissued returns captured payments without a receipt, oldest first.
+func (r *ReceiptRepository) Unissued(ctx context.Context, limit int) ([]models.Payment, error) {
+	var list []models.Payment
+	err := r.db.WithContext(ctx).
+		Where("captured_cents > 0 AND NOT EXISTS (SELECT 1 FROM receipts WHERE receipts.payment_id = payments.id)").
+		Order("id ASC").Limit(limit).Find(&list).Error
+	return list, err
+}
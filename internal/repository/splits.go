@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"navmate-backend/internal/events"
+	"navmate-backend/internal/models"
+)
+
+var (
+	// ErrSplitExists means the booking's fare is already being split.
+	ErrSplitExists = errors.New("booking fare is already split")
+	// ErrShareNotOpen means the share was answered, is being paid, or its
+	// split is no longer open.
+	ErrShareNotOpen = errors.New("share can no longer be paid")
+)
+
+// activeSplit are the split statuses that own the booking's payment.
+var activeSplit = []string{models.SplitOpen, models.SplitFunded, models.SplitSettled}
+
+// replaceablePayment are the payment statuses a share may be paid again from.
+var replaceablePayment = []string{"", "declined", "voided", "expired"}
+
+// SplitRepository stores fare splits and their shares. Every change is
+// published to the owner and the participants.
+type SplitRepository struct {
+	db  *gorm.DB
+	pub events.Publisher // optional
+}
+
+func NewSplitRepository(db *gorm.DB, pub events.Publisher) *SplitRepository {
+	return &SplitRepository{db: db, pub: pub}
+}
+
+// Create stores s and its shares. The booking row is locked so two requests
+// cannot both split it; it fails with ErrSplitExists if the booking has an
+// active split.
+func (r *SplitRepository) Create(ctx context.Context, s *models.FareSplit, shares []models.SplitShare) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.RideBooking{}, s.BookingID).Error; err != nil {
+			return err
+		}
+		var n int64
+		if err := tx.Model(&models.FareSplit{}).Where("booking_id = ? AND status IN ?", s.BookingID, activeSplit).Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrSplitExists
+		}
+		if err := tx.Create(s).Error; err != nil {
+			return err
+		}
+		for i := range shares {
+			shares[i].SplitID = s.ID
+		}
+		return tx.Create(&shares).Error
+	})
+	if err != nil {
+		return err
+	}
+	r.publish(ctx, s, shares)
+	return nil
+}
+
+func (r *SplitRepository) Get(ctx context.Context, id uint) (models.FareSplit, []models.SplitShare, error) {
+	var s models.FareSplit
+	if err := r.db.WithContext(ctx).First(&s, id).Error; err != nil {
+		return s, nil, err
+	}
+	shares, err := r.Shares(ctx, s.ID)
+	return s, shares, err
+}
+
+// Shares returns the split's shares, the owner's first.
+func (r *SplitRepository) Shares(ctx context.Context, splitID uint) ([]models.SplitShare, error) {
+	var list []models.SplitShare
+	err := r.db.WithContext(ctx).Where("split_id = ?", splitID).Order("id ASC").Find(&list).Error
+	return list, err
+}
+
+// Active returns the booking's split that is open, funded or settled.
+func (r *SplitRepository) Active(ctx context.Context, bookingID uint) (models.FareSplit, error) {
+	var s models.FareSplit
+	err := r.db.WithContext(ctx).Where("booking_id = ? AND status IN ?", bookingID, activeSplit).First(&s).Error
+	return s, err
+}
+
+// ForUser returns the splits userID owns or has a share in, newest first.
+func (r *SplitRepository) ForUser(ctx context.Context, userID uint, limit int) ([]models.FareSplit, error) {
+	var list []models.FareSplit
+	err := r.db.WithContext(ctx).
+		Where("owner_id = ? OR id IN (SELECT split_id FROM split_shares WHERE user_id = ?)", userID, userID).
+		Order("id DESC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// Pending returns splits the settler has work on: open or funded ones, and
+// failed or cancelled ones whose shares still hold money.
+func (r *SplitRepository) Pending(ctx context.Context, limit int) ([]models.FareSplit, error) {
+	var list []models.FareSplit
+	err := r.db.WithContext(ctx).
+		Where("status IN ? OR (status IN ? AND id IN (SELECT split_id FROM split_shares WHERE payment_status IN ?))",
+			[]string{models.SplitOpen, models.SplitFunded},
+			[]string{models.SplitFailed, models.SplitCancelled},
+			[]string{"authorized", "pending"}).
+		Order("id ASC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// ClaimShare inserts p and links it to share before the gateway is called,
+// like PaymentRepository.Claim. It fails with ErrShareNotOpen unless the
+// split is open and the share has no payment that holds or may still take
+// money.
+func (r *SplitRepository) ClaimShare(ctx context.Context, p *models.Payment, share *models.SplitShare) error {
+	now := time.Now()
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(p).Error; err != nil {
+			return err
+		}
+		res := tx.Model(&models.SplitShare{}).
+			Where("id = ? AND status IN ? AND payment_status IN ?", share.ID, []string{models.ShareInvited, models.ShareAccepted}, replaceablePayment).
+			Where("split_id IN (SELECT id FROM fare_splits WHERE status = ? AND expires_at > ?)", models.SplitOpen, now).
+			Updates(map[string]any{"payment_id": p.ID, "payment_status": p.Status, "status": models.ShareAccepted, "responded_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrShareNotOpen
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	share.PaymentID, share.PaymentStatus, share.Status, share.RespondedAt = &p.ID, p.Status, models.ShareAccepted, &now
+	return nil
+}
+
+// ReleaseShare undoes ClaimShare when the gateway could not be reached: p is
+// deleted and share is put back as it was (prev).
+func (r *SplitRepository) ReleaseShare(ctx context.Context, p *models.Payment, share *models.SplitShare, prev models.SplitShare) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.SplitShare{}).Where("id = ? AND payment_id = ?", share.ID, p.ID).
+			Updates(map[string]any{
+				"payment_id": prev.PaymentID, "payment_status": prev.PaymentStatus,
+				"status": prev.Status, "responded_at": prev.RespondedAt,
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(p).Error
+	})
+	if err != nil {
+		return err
+	}
+	*share = prev
+	return nil
+}
+
+// Decline records that the participant won't pay their share.
+func (r *SplitRepository) Decline(ctx context.Context, s *models.FareSplit, share *models.SplitShare) error {
+	now := time.Now()
+	res := r.db.WithContext(ctx).Model(&models.SplitShare{}).
+		Where("id = ? AND status IN ? AND payment_status IN ?", share.ID, []string{models.ShareInvited, models.ShareAccepted}, replaceablePayment).
+		Updates(map[string]any{"status": models.ShareDeclined, "responded_at": now})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrShareNotOpen
+	}
+	share.Status, share.RespondedAt = models.ShareDeclined, &now
+	r.Notify(ctx, s)
+	return nil
+}
+
+// ExpireInvited marks the shares nobody answered as expired.
+func (r *SplitRepository) ExpireInvited(ctx context.Context, s *models.FareSplit) error {
+	return r.db.WithContext(ctx).Model(&models.SplitShare{}).
+		Where("split_id = ? AND status = ?", s.ID, models.ShareInvited).
+		Update("status", models.ShareExpired).Error
+}
+
+// AddCover stores the owner's share covering what was left unpaid, with the
+// payment that pays it.
+func (r *SplitRepository) AddCover(ctx context.Context, share *models.SplitShare, p *models.Payment) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(p).Error; err != nil {
+			return err
+		}
+		share.PaymentID, share.PaymentStatus = &p.ID, p.Status
+		return tx.Create(share).Error
+	})
+}
+
+// SetStatus moves s from the status it was read with to status. It fails
+// with gorm.ErrRecordNotFound if s changed meanwhile.
+func (r *SplitRepository) SetStatus(ctx context.Context, s *models.FareSplit, status, note string) error {
+	upd := map[string]any{"status": status, "note": note}
+	var resolved *time.Time
+	if status != models.SplitSettled {
+		now := time.Now()
+		resolved = &now
+		upd["resolved_at"] = now
+	}
+	res := r.db.WithContext(ctx).Model(&models.FareSplit{}).Where("id = ? AND status = ?", s.ID, s.Status).Updates(upd)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	s.Status, s.Note = status, note
+	if resolved != nil {
+		s.ResolvedAt = resolved
+	}
+	r.Notify(ctx, s)
+	return nil
+}
+
+// Notify tells the owner and the participants that s changed, e.g. a share
+// was paid.
+func (r *SplitRepository) Notify(ctx context.Context, s *models.FareSplit) {
+	if r.pub == nil {
+		return
+	}
+	shares, err := r.Shares(ctx, s.ID)
+	if err != nil {
+		return
+	}
+	r.publish(ctx, s, shares)
+}
+
+// publish is best effort, like BookingRepository.publish.
+func (r *SplitRepository) publish(ctx context.Context, s *models.FareSplit, shares []models.SplitShare) {
+	if r.pub == nil {
+		return
+	}
+	users := map[uint]bool{s.OwnerID: true}
+	for _, sh := range shares {
+		users[sh.UserID] = true
+	}
+	for uid := range users {
+		_ = r.pub.Publish(ctx, events.Event{
+			Type: events.TypeSplitUpdated, UserID: uid,
+			Data: map[string]any{"split_id": s.ID, "booking_id": s.BookingID, "status": s.Status},
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"navmate-backend/internal/adapters/fx"
+	"navmate-backend/internal/adapters/maps"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/money"
+)
+
+// Errors returned by TripRepository. Handlers map these to HTTP status codes.
+var (
+	ErrPlanNotFound      = errors.New("plan not found")
+	ErrItineraryNotFound = errors.New("itinerary not found")
+	ErrItineraryExpired  = errors.New("itinerary expired")
+)
+
+const batchSize = 100
+
+// TripRepository owns persistence of the trip plan graph (plan -> itineraries -> legs).
+type TripRepository struct{ db *gorm.DB }
+
+func NewTripRepository(db *gorm.DB) *TripRepository { return &TripRepository{db: db} }
+
+// BuildPlan turns routing adapter options into an unsaved plan graph.
+func BuildPlan(userID uint, origin, destination string, departAt *time.Time, opts []maps.ItinOpt, expiresAt *time.Time) models.TripPlan {
+	plan := models.TripPlan{UserID: userID, Origin: origin, Destination: destination, DepartAt: departAt}
+	plan.Itineraries = make([]models.Itinerary, 0, len(opts))
+	for _, o := range opts {
+		it := models.Itinerary{
+			ModeMix: o.ModeMix, TotalMinutes: o.TotalMinutes, RoughCostCents: o.RoughCostCents, Currency: o.Currency,
+			ExpiresAt: expiresAt,
+		}
+		it.Legs = make([]models.Leg, 0, len(o.Legs))
+		for i, l := range o.Legs {
+			it.Legs = append(it.Legs, models.Leg{
+				Index: i, Mode: l.Mode, FromName: l.From, ToName: l.To,
+				Minutes: l.Minutes, DistanceM: l.DistanceM, Provider: l.Provider, FareCents: l.FareCents,
+			})
+		}
+		plan.Itineraries = append(plan.Itineraries, it)
+	}
+	return plan
+}
+
+// LocalizePlan converts the itineraries' costs into currency for display,
+// keeping the rate on each itinerary so that later quotes use the same one.
+// Itineraries already in currency are left alone.
+func LocalizePlan(ctx context.Context, rates fx.RateSource, plan *models.TripPlan, currency string) error {
+	if currency == "" {
+		return nil
+	}
+	for i := range plan.Itineraries {
+		it := &plan.Itineraries[i]
+		if it.Currency == currency {
+			continue
+		}
+		m, rate, err := fx.Convert(ctx, rates, money.New(it.RoughCostCents, it.Currency), currency)
+		if err != nil {
+			return err
+		}
+		it.DisplayCurrency, it.DisplayCostCents, it.FxRate = currency, m.Cents, rate
+	}
+	return nil
+}
+
+// CreatePlan inserts the plan, its itineraries and their legs in a single
+// transaction using batch inserts. IDs are written back into the graph.
+func (r *TripRepository) CreatePlan(ctx context.Context, plan *models.TripPlan) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createPlan(tx, plan)
+	})
+}
+
+// CreateSelectedPlan is CreatePlan with plan.Itineraries[selected] chosen in
+// the same transaction, so the plan never exists without its selection.
+func (r *TripRepository) CreateSelectedPlan(ctx context.Context, plan *models.TripPlan, selected int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := createPlan(tx, plan); err != nil {
+			return err
+		}
+		id := plan.Itineraries[selected].ID
+		if err := tx.Model(plan).Updates(map[string]any{"selected_itinerary_id": id, "status": "selected"}).Error; err != nil {
+			return fmt.Errorf("select itinerary: %w", err)
+		}
+		plan.SelectedItineraryID, plan.Status = &id, "selected"
+		return nil
+	})
+}
+
+func createPlan(tx *gorm.DB, plan *models.TripPlan) error {
+	if err := tx.Omit(clause.Associations).Create(plan).Error; err != nil {
+		return fmt.Errorf("create plan: %w", err)
+	}
+	if len(plan.Itineraries) == 0 {
+		return nil
+	}
+
+	for i := range plan.Itineraries {
+		plan.Itineraries[i].PlanID = plan.ID
+	}
+	if err := tx.Omit(clause.Associations).CreateInBatches(&plan.Itineraries, batchSize).Error; err != nil {
+		return fmt.Errorf("create itineraries: %w", err)
+	}
+
+	var legs []*models.Leg
+	for i := range plan.Itineraries {
+		it := &plan.Itineraries[i]
+		for j := range it.Legs {
+			it.Legs[j].ItineraryID = it.ID
+			legs = append(legs, &it.Legs[j])
+		}
+	}
+	if len(legs) == 0 {
+		return nil
+	}
+	if err := tx.CreateInBatches(legs, batchSize).Error; err != nil {
+		return fmt.Errorf("create legs: %w", err)
+	}
+	return nil
+}
+
+// GetPlan loads a plan owned by userID.
+func (r *TripRepository) GetPlan(ctx context.Context, userID, planID uint) (*models.TripPlan, error) {
+	var p models.TripPlan
+	err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", planID, userID).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPlanNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SelectItinerary marks itineraryID as the chosen option of the user's plan.
+func (r *TripRepository) SelectItinerary(ctx context.Context, userID, planID, itineraryID uint, now time.Time) (*models.TripPlan, error) {
+	p, err := r.GetPlan(ctx, userID, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	var it models.Itinerary
+	err = r.db.WithContext(ctx).Where("id = ? AND plan_id = ?", itineraryID, p.ID).First(&it).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrItineraryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if it.Expired(now) {
+		return nil, ErrItineraryExpired
+	}
+
+	p.SelectedItineraryID = &it.ID
+	p.Status = "selected"
+	if err := r.db.WithContext(ctx).Save(p).Error; err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// BestNonRideItinerary returns the fastest unexpired itinerary of the plan that
+// has no RIDE leg, used as a fallback when a ride cannot be booked.
+func (r *TripRepository) BestNonRideItinerary(ctx context.Context, planID uint, now time.Time) (*models.Itinerary, error) {
+	var it models.Itinerary
+	err := r.db.WithContext(ctx).
+		Where("plan_id = ? AND (expires_at IS NULL OR expires_at > ?)", planID, now).
+		Where("NOT EXISTS (SELECT 1 FROM legs WHERE legs.itinerary_id = itineraries.id AND legs.mode = ?)", "RIDE").
+		Order("total_minutes ASC, rough_cost_cents ASC").
+		First(&it).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrItineraryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &it, nil
+}
@@ -0,0 +1,96 @@
+package routes
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/adapters/fx"
+	"navmate-backend/internal/adapters/mail"
+	"navmate-backend/internal/adapters/payment"
+	"navmate-backend/internal/adapters/ride"
+	"navmate-backend/internal/events"
+)
+
+// Deps are long-lived services shared by HTTP handlers and background jobs.
+type Deps struct {
+	Rides    *ride.Registry
+	SimClock *ride.SimClock // clock of the simulated providers
+	Events   events.Bus
+	Payments payment.PaymentGateway
+	FX       fx.RateSource
+	Mail     mail.Sender // nil when MAIL_SENDER is empty
+	Log      *zap.SugaredLogger
+}
+
+func NewDeps(cfg *config.Config, db *gorm.DB) (*Deps, error) {
+	seed := cfg.Ride.SimSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	clock := ride.NewSimClock(nil)
+	providers := make([]ride.RideProvider, 0, len(cfg.Ride.Providers))
+	for i, pc := range cfg.Ride.Providers {
+		sim, err := ride.NewSimulator(pc.Name, ride.SimulatorOptions{
+			PriceFactor: pc.PriceFactor,
+			QuoteTTL:    cfg.Ride.QuoteTTL,
+			Seed:        seed + int64(i),
+			Clock:       clock,
+			Scenario:    cfg.Ride.SimScenario,
+
+			WebhookSecret:    pc.WebhookSecret,
+			WebhookTolerance: cfg.Ride.WebhookTolerance,
+		})
+		if err != nil {
+			return nil, err // e.g. a bad RIDE_SIM_SCENARIO
+		}
+		providers = append(providers, sim)
+	}
+
+	payments, err := payment.New(payment.Options{
+		Gateway:          cfg.Payment.Gateway,
+		APIURL:           cfg.Payment.APIURL,
+		SecretKey:        cfg.Payment.SecretKey,
+		Timeout:          cfg.Payment.Timeout,
+		WebhookSecret:    cfg.Payment.WebhookSecret,
+		WebhookTolerance: cfg.Payment.WebhookTolerance,
+		ReturnURL:        cfg.Payment.ReturnURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rates, err := fx.New(fx.Options{Source: cfg.Money.FXSource, RatesFile: cfg.Money.FXRatesFile})
+	if err != nil {
+		return nil, err
+	}
+
+	sender, err := mail.New(mail.Options{
+		Sender:   cfg.Mail.Sender,
+		From:     cfg.Mail.From,
+		Dir:      cfg.Mail.Dir,
+		Host:     cfg.Mail.SMTPHost,
+		Port:     cfg.Mail.SMTPPort,
+		Username: cfg.Mail.SMTPUsername,
+		Password: cfg.Mail.SMTPPassword,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var bus events.Bus = events.NewMemoryBus()
+	if cfg.Events.Backend == "postgres" && db != nil {
+		bus = events.NewPostgresBus(db)
+	}
+	return &Deps{
+		Rides:    ride.NewRegistry(cfg.Ride.DefaultProvider, providers...),
+		SimClock: clock,
+		Events:   bus,
+		Payments: payments,
+		FX:       rates,
+		Log:      zap.NewNop().Sugar(), // main swaps in its logger
+		Mail:     sender,
+	}, nil
+}
@@ -10,16 +10,27 @@ import (
 	"navmate-backend/config"
 	"navmate-backend/internal/handlers/auth"
 	"navmate-backend/internal/handlers/booking"
+	"navmate-backend/internal/handlers/dev"
 	"navmate-backend/internal/handlers/payment"
+	"navmate-backend/internal/handlers/profile"
+	"navmate-backend/internal/handlers/promo"
 	"navmate-backend/internal/handlers/safety"
+	"navmate-backend/internal/handlers/stream"
 	"navmate-backend/internal/handlers/travel"
 	"navmate-backend/internal/middleware"
 	"navmate-backend/pkg/jwtauth"
 )
 
-func SetupRouter(router *gin.Engine, DB *gorm.DB, cfg *config.Config) {
+func SetupRouter(router *gin.Engine, DB *gorm.DB, cfg *config.Config, deps *Deps) {
 	router.Use(gin.Recovery())
 
+	if deps == nil {
+		var err error
+		if deps, err = NewDeps(cfg, DB); err != nil {
+			panic(err)
+		}
+	}
+
 	//router.StaticFile("/", "./index.html")
 
 	// Health checks
@@ -51,26 +62,84 @@ func SetupRouter(router *gin.Engine, DB *gorm.DB, cfg *config.Config) {
 		v1.GET("/me", middleware.AuthJWT(jwtSvc), func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{"user_id": c.GetInt("user_id"), "email": c.GetString("email")})
 		})
+		profH := profile.New(DB)
+		v1.GET("/me/preferences", middleware.AuthJWT(jwtSvc), profH.GetPreferences)
+		v1.PUT("/me/preferences", middleware.AuthJWT(jwtSvc), profH.UpdatePreferences)
 
 		// Trip planning routes (BE-5)
 		// NEW: Pass the config to the travel handler
-		travH := travel.New(DB, cfg)
+		travH := travel.New(DB, deps.FX, cfg, deps.Log)
 		v1.POST("/trips/plan", middleware.AuthJWT(jwtSvc), travH.Plan)
+		v1.GET("/trips/plans", middleware.AuthJWT(jwtSvc), travH.ListPlans)
 		v1.GET("/trips/plans/:id", middleware.AuthJWT(jwtSvc), travH.GetPlan)
 		v1.POST("/trips/plans/:id/select", middleware.AuthJWT(jwtSvc), travH.SelectItinerary)
+		v1.GET("/me/stats", middleware.AuthJWT(jwtSvc), travH.Stats)
+		v1.POST("/trips/schedules", middleware.AuthJWT(jwtSvc), travH.CreateSchedule)
+		v1.GET("/trips/schedules", middleware.AuthJWT(jwtSvc), travH.ListSchedules)
+		v1.PUT("/trips/schedules/:id", middleware.AuthJWT(jwtSvc), travH.UpdateSchedule)
+		v1.DELETE("/trips/schedules/:id", middleware.AuthJWT(jwtSvc), travH.DeleteSchedule)
 
 		// Booking routes (BE-6)
-		bookH := booking.New(DB)
+		bookH := booking.New(DB, deps.Rides, deps.Payments, deps.FX, deps.Events, cfg)
+		v1.POST("/bookings/quotes", middleware.AuthJWT(jwtSvc), bookH.CompareQuotes)
 		v1.POST("/bookings", middleware.AuthJWT(jwtSvc), bookH.Create)
 		v1.GET("/bookings/:id", middleware.AuthJWT(jwtSvc), bookH.Get)
-		//v1.DELETE("/bookings/:id", middleware.AuthJWT(jwtSvc), bookH.Cancel)
+		v1.GET("/bookings/:id/events", middleware.AuthJWT(jwtSvc), bookH.Events)
+		v1.POST("/bookings/:id/surge/accept", middleware.AuthJWT(jwtSvc), bookH.AcceptSurge)
+		v1.POST("/bookings/:id/surge/decline", middleware.AuthJWT(jwtSvc), bookH.DeclineSurge)
+		v1.PATCH("/bookings/:id", middleware.AuthJWT(jwtSvc), bookH.Reschedule)
+		v1.DELETE("/bookings/:id", middleware.AuthJWT(jwtSvc), bookH.Cancel)
+		v1.POST("/rides/webhook/:provider", bookH.ProviderWebhook)
 
 		// Payment routes (BE-7)
-		payH := payment.New(DB)
+		payH := payment.New(DB, deps.Payments, deps.FX, deps.Mail, deps.Events, cfg)
 		v1.POST("/payments/authorize", middleware.AuthJWT(jwtSvc), payH.Authorize)
-		v1.POST("/payments/:id/capture", middleware.AuthJWT(jwtSvc), payH.Capture)
-		v1.POST("/payments/:id/refund", middleware.AuthJWT(jwtSvc), payH.Refund)
+		v1.GET("/payments/:id", middleware.AuthJWT(jwtSvc), payH.Get)
+		v1.GET("/payments/:id/transactions", middleware.AuthJWT(jwtSvc), payH.Transactions)
+		v1.GET("/payments/:id/receipt", middleware.AuthJWT(jwtSvc), payH.Receipt)
+		v1.POST("/payments/:id/receipt/email", middleware.AuthJWT(jwtSvc), payH.EmailReceipt)
 		v1.POST("/payments/webhook", payH.Webhook)
+		v1.POST("/bookings/:id/split", middleware.AuthJWT(jwtSvc), payH.CreateSplit)
+		v1.GET("/splits/:id", middleware.AuthJWT(jwtSvc), payH.GetSplit)
+		v1.POST("/splits/:id/accept", middleware.AuthJWT(jwtSvc), payH.AcceptSplit)
+		v1.POST("/splits/:id/decline", middleware.AuthJWT(jwtSvc), payH.DeclineSplit)
+		v1.DELETE("/splits/:id", middleware.AuthJWT(jwtSvc), payH.CancelSplit)
+		v1.GET("/me/splits", middleware.AuthJWT(jwtSvc), payH.ListSplits)
+		v1.GET("/me/payment-methods", middleware.AuthJWT(jwtSvc), payH.ListMethods)
+		v1.POST("/me/payment-methods", middleware.AuthJWT(jwtSvc), payH.CreateMethod)
+		v1.PATCH("/me/payment-methods/:id", middleware.AuthJWT(jwtSvc), payH.UpdateMethod)
+		v1.DELETE("/me/payment-methods/:id", middleware.AuthJWT(jwtSvc), payH.DeleteMethod)
+
+		// Promotions and credits
+		promoH := promo.New(DB, deps.FX, cfg)
+		v1.GET("/me/credits", middleware.AuthJWT(jwtSvc), promoH.Credits)
+
+		// Admin routes
+		var admins []string
+		if cfg != nil {
+			admins = cfg.App.Admins
+		}
+		admin := v1.Group("/admin", middleware.AuthJWT(jwtSvc), middleware.RequireAdmin(admins))
+		admin.GET("/payments/webhooks", payH.ListWebhooks)
+		admin.POST("/payments/webhooks/:id/replay", payH.ReplayWebhook)
+		admin.POST("/payments/:id/capture", payH.Capture)
+		admin.POST("/payments/:id/refund", payH.Refund)
+		admin.POST("/promotions", promoH.Create)
+		admin.GET("/promotions", promoH.List)
+		admin.PATCH("/promotions/:id", promoH.Update)
+		admin.POST("/users/:id/credits", promoH.GrantCredit)
+
+		// Ride simulator controls (development only)
+		if cfg != nil && cfg.App.Env == "development" {
+			devH := dev.New(deps.Rides, deps.SimClock)
+			v1.POST("/dev/rides/clock/advance", devH.AdvanceClock)
+			v1.POST("/dev/rides/:provider/next", devH.ForceOutcome)
+			v1.PUT("/dev/rides/:provider/scenario", devH.SetScenario)
+		}
+
+		// Realtime updates
+		streamH := stream.New(deps.Events, cfg)
+		v1.GET("/events/stream", middleware.AuthJWTStream(jwtSvc), streamH.Stream)
 
 		// Safety routes (BE-9)
 		v1.POST("/safety/session", middleware.AuthJWT(jwtSvc), safeH.Start)
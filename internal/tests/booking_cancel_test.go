@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/adapters/payment"
+	"navmate-backend/internal/adapters/ride"
+	"navmate-backend/internal/handlers/booking"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/repository"
+)
+
+// authorizedBooking stores a booking of user 1 in status with fare, paid by
+// an authorization held at gw. A nil gw leaves the booking unpaid.
+func authorizedBooking(t *testing.T, db *gorm.DB, gw payment.PaymentGateway, status string, fare int) (models.RideBooking, *models.Payment) {
+	t.Helper()
+	ctx := context.Background()
+	plan := samplePlan(1, nil)
+	if err := repository.NewTripRepository(db).CreatePlan(ctx, &plan); err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+	b := models.RideBooking{PlanID: plan.ID, ItineraryID: plan.Itineraries[0].ID, Provider: "Sim", Status: status,
+		FareCents: fare, Currency: "THB", ExternalRef: fmt.Sprintf("Sim-%d", plan.ID)}
+	if err := repository.NewBookingRepository(db, nil).Create(ctx, &b, "user"); err != nil {
+		t.Fatalf("create booking: %v", err)
+	}
+	if gw == nil {
+		return b, nil
+	}
+
+	payments := repository.NewPaymentRepository(db, nil)
+	p := models.Payment{AmountCents: fare, Currency: "THB", Status: payment.StatusPending, FareCents: fare, Gateway: gw.Name()}
+	if err := payments.Claim(ctx, &p, &b); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	ch, err := gw.Authorize(ctx, payment.AuthRequest{AmountCents: fare, Currency: "THB", Source: "tokn_test_visa", IdempotencyKey: fmt.Sprintf("pay-%d", p.ID)})
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	p.ExternalRef = ch.ExternalRef
+	if err := payments.ApplyCharge(ctx, &p, ch, repository.LedgerMeta{Actor: "user"}); err != nil {
+		t.Fatalf("apply charge: %v", err)
+	}
+	b.PaymentStatus = p.Status
+	return b, &p
+}
+
+// addCreditSpend reserves credit of the booking's owner for it, as
+// PromotionRepository.Apply does.
+func addCreditSpend(t *testing.T, db *gorm.DB, b models.RideBooking, cents int) {
+	t.Helper()
+	if err := db.Create(&models.CreditTransaction{UserID: 1, Kind: models.CreditSpend, AmountCents: -cents, Currency: "THB", BookingID: &b.ID, Actor: "system"}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func creditRefunds(t *testing.T, db *gorm.DB, b models.RideBooking) int64 {
+	t.Helper()
+	return countWhere(t, db, &models.CreditTransaction{}, "booking_id = ? AND kind = ?", b.ID, models.CreditRefund)
+}
+
+func TestProviderCancelAfterPickupIsCharged(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	bookings := repository.NewBookingRepository(db, nil)
+
+	// the trip had started: the fare is due and the credit stays spent
+	started, _ := authorizedBooking(t, db, nil, models.BookingInProgress, 12000)
+	addCreditSpend(t, db, started, 500)
+	if err := bookings.ApplyProviderUpdate(ctx, &started, ride.StatusUpdate{ExternalRef: started.ExternalRef, Status: models.BookingCancelled}); err != nil {
+		t.Fatalf("apply update: %v", err)
+	}
+	var stored models.RideBooking
+	db.First(&stored, started.ID)
+	if stored.Status != models.BookingCancelled || stored.CancelledBy != "provider" || stored.CancelledAt == nil || stored.CancellationFeeCents != 12000 {
+		t.Fatalf("in_progress -> cancelled: %q by %q, fee %d", stored.Status, stored.CancelledBy, stored.CancellationFeeCents)
+	}
+	if n := creditRefunds(t, db, started); n != 0 {
+		t.Fatalf("credit of a started trip was refunded")
+	}
+
+	// before pickup the driver's cancellation costs the rider nothing
+	waiting, _ := authorizedBooking(t, db, nil, models.BookingDriverAssigned, 12000)
+	addCreditSpend(t, db, waiting, 500)
+	if err := bookings.ApplyProviderUpdate(ctx, &waiting, ride.StatusUpdate{ExternalRef: waiting.ExternalRef, Status: models.BookingCancelled}); err != nil {
+		t.Fatalf("apply update: %v", err)
+	}
+	db.First(&stored, waiting.ID)
+	if stored.Status != models.BookingCancelled || stored.CancellationFeeCents != 0 {
+		t.Fatalf("driver_assigned -> cancelled: %q, fee %d", stored.Status, stored.CancellationFeeCents)
+	}
+	if n := creditRefunds(t, db, waiting); n != 1 {
+		t.Fatalf("credit not refunded: %d refunds", n)
+	}
+}
+
+func TestCancelCapturesFeeOnceDriverAssigned(t *testing.T) {
+	db := newTestDB(t)
+	gw := newMockGateway(t)
+	b, _ := authorizedBooking(t, db, gw, models.BookingDriverAssigned, 12000)
+
+	cfg := &config.Config{}
+	cfg.Money.Currency = "THB"
+	cfg.Booking.CancelFeeCents, cfg.Booking.CancelFeeDriverAssignedCents = 2000, 4000
+	gin.SetMode(gin.TestMode)
+	h := booking.New(db, ride.NewRegistry("Sim", newSim(t, 1, ride.ScenarioConfirm, nil)), gw, nil, nil, cfg)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", 1) })
+	r.DELETE("/v1/bookings/:id", h.Cancel)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/v1/bookings/%d", b.ID), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("cancel: %d %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Status  string `json:"status"`
+		Fee     int    `json:"cancellation_fee_cents"`
+		Payment struct {
+			Status        string `json:"status"`
+			CapturedCents int    `json:"captured_cents"`
+		} `json:"payment"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Status != models.BookingCancelled || resp.Fee != 4000 || resp.Payment.Status != payment.StatusCaptured || resp.Payment.CapturedCents != 4000 {
+		t.Fatalf("cancel response = %s", w.Body)
+	}
+
+	// riders can't cancel a trip that has started
+	started, _ := authorizedBooking(t, db, nil, models.BookingInProgress, 12000)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/v1/bookings/%d", started.ID), nil))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("cancel in_progress: got %d, want 409", w.Code)
+	}
+}
+
+func TestCancelRereadsStaleBookingBeforeSettling(t *testing.T) {
+	db := newTestDB(t)
+	gw := newMockGateway(t)
+	b, p := authorizedBooking(t, db, gw, models.BookingConfirmed, 12000)
+	ctx := context.Background()
+	ch, err := gw.Capture(ctx, p.ExternalRef, 12000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.NewPaymentRepository(db, nil).ApplyCharge(ctx, p, ch, repository.LedgerMeta{Actor: "system"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the poller assigns a driver between the handler's read and its write
+	fired := false
+	if err := db.Callback().Update().Before("gorm:update").Register("test:driver_assigned", func(tx *gorm.DB) {
+		if tx.Statement.Table == "ride_bookings" && !fired {
+			fired = true
+			db.Exec("UPDATE ride_bookings SET status = ? WHERE id = ?", models.BookingDriverAssigned, b.ID)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Callback().Update().Remove("test:driver_assigned") })
+
+	cfg := &config.Config{}
+	cfg.Money.Currency = "THB"
+	cfg.Booking.CancelFeeCents, cfg.Booking.CancelFeeDriverAssignedCents = 2000, 4000
+	gin.SetMode(gin.TestMode)
+	h := booking.New(db, ride.NewRegistry("Sim", newSim(t, 1, ride.ScenarioConfirm, nil)), gw, nil, nil, cfg)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", 1) })
+	r.DELETE("/v1/bookings/:id", h.Cancel)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/v1/bookings/%d", b.ID), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("cancel: %d %s", w.Code, w.Body)
+	}
+	var stored models.RideBooking
+	db.First(&stored, b.ID)
+	if stored.Status != models.BookingCancelled || stored.CancellationFeeCents != 4000 {
+		t.Fatalf("booking = %q, fee %d; want cancelled with the driver_assigned fee", stored.Status, stored.CancellationFeeCents)
+	}
+	var paid models.Payment
+	db.First(&paid, p.ID)
+	if paid.RefundedCents != 8000 {
+		t.Fatalf("refunded %d, want 8000", paid.RefundedCents)
+	}
+	if n := countWhere(t, db, &models.PaymentTransaction{}, "payment_id = ? AND kind = ?", p.ID, models.TxRefund); n != 1 {
+		t.Fatalf("%d refund entries, want 1", n)
+	}
+}
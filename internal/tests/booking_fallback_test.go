@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/adapters/ride"
+	"navmate-backend/internal/handlers/booking"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/repository"
+)
+
+type fallbackResp struct {
+	BookingID uint            `json:"booking_id"`
+	Status    string          `json:"status"`
+	Provider  string          `json:"provider"`
+	Attempts  int             `json:"attempts"`
+	Fallback  json.RawMessage `json:"fallback_itinerary"`
+	Code      string          `json:"code"`
+}
+
+// bookWithFallback books the ride leg of a fresh plan of user 1, whose
+// ride_fallback is policy, with RideNow on scenario and GoCab always
+// confirming. quote, if set, returns the quote_id to book.
+func bookWithFallback(t *testing.T, db *gorm.DB, policy, scenario string, tune func(*config.Config), quote func(models.TripPlan) string) (int, fallbackResp) {
+	t.Helper()
+	ctx := context.Background()
+	plan := samplePlan(1, nil)
+	if err := repository.NewTripRepository(db).CreatePlan(ctx, &plan); err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+	if _, err := repository.NewTripRepository(db).SelectItinerary(ctx, 1, plan.ID, plan.Itineraries[0].ID, time.Now()); err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	pref := models.DefaultPreference(1)
+	pref.RideFallback = policy
+	if err := repository.NewPreferenceRepository(db).Save(ctx, &pref); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{}
+	cfg.Money.Currency = "THB"
+	cfg.Ride.QuoteTimeout = time.Second
+	cfg.Booking.MaxAttempts, cfg.Booking.RetryBackoff, cfg.Booking.AttemptsTimeout = 3, time.Millisecond, 5*time.Second
+	if tune != nil {
+		tune(cfg)
+	}
+	rideNow, err := ride.NewSimulator("RideNow", ride.SimulatorOptions{QuoteTTL: time.Minute, Seed: 1, Scenario: scenario})
+	if err != nil {
+		t.Fatal(err)
+	}
+	goCab, _ := ride.NewSimulator("GoCab", ride.SimulatorOptions{PriceFactor: 0.9, QuoteTTL: time.Minute, Seed: 2, Scenario: ride.ScenarioConfirm})
+
+	gin.SetMode(gin.TestMode)
+	h := booking.New(db, ride.NewRegistry("RideNow", rideNow, goCab), nil, nil, nil, cfg)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", 1) })
+	r.POST("/v1/bookings", h.Create)
+
+	w := httptest.NewRecorder()
+	body := fmt.Sprintf(`{"plan_id":%d}`, plan.ID)
+	if quote != nil {
+		body = fmt.Sprintf(`{"plan_id":%d,"quote_id":%q}`, plan.ID, quote(plan))
+	}
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/bookings", bytes.NewBufferString(body)))
+	var resp fallbackResp
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func attemptNotes(t *testing.T, db *gorm.DB, bookingID uint) []string {
+	t.Helper()
+	var notes []string
+	for _, ev := range mustEvents(t, db, bookingID) {
+		if strings.HasPrefix(ev.Note, "attempt ") {
+			notes = append(notes, ev.Note)
+		}
+	}
+	return notes
+}
+
+func mustEvents(t *testing.T, db *gorm.DB, bookingID uint) []models.BookingEvent {
+	t.Helper()
+	events, err := repository.NewBookingRepository(db, nil).Events(context.Background(), bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestBookingFallbackNextProvider(t *testing.T) {
+	db := newTestDB(t)
+	code, resp := bookWithFallback(t, db, models.RideFallbackNextProvider, "fail:5", nil, nil)
+	if code != http.StatusOK || resp.Status != models.BookingConfirmed || resp.Provider != "GoCab" || resp.Attempts != 2 {
+		t.Fatalf("next provider: %d %+v", code, resp)
+	}
+	if notes := attemptNotes(t, db, resp.BookingID); len(notes) != 1 || notes[0] != "attempt 1 via RideNow: failed" {
+		t.Fatalf("attempt history = %q", notes)
+	}
+}
+
+func TestBookingFallbackRetryCount(t *testing.T) {
+	db := newTestDB(t)
+	code, resp := bookWithFallback(t, db, models.RideFallbackRetry, "fail:2", nil, nil)
+	if code != http.StatusOK || resp.Status != models.BookingConfirmed || resp.Provider != "RideNow" || resp.Attempts != 3 {
+		t.Fatalf("retry until confirmed: %d %+v", code, resp)
+	}
+	if notes := attemptNotes(t, db, resp.BookingID); len(notes) != 2 {
+		t.Fatalf("attempt history = %q", notes)
+	}
+
+	// BOOKING_MAX_ATTEMPTS counts the first attempt too
+	_, resp = bookWithFallback(t, db, models.RideFallbackRetry, "fail:5", nil, nil)
+	if resp.Status != models.BookingFailed || resp.Attempts != 3 || string(resp.Fallback) == "null" {
+		t.Fatalf("retries used up: %+v", resp)
+	}
+	if notes := attemptNotes(t, db, resp.BookingID); len(notes) != 3 {
+		t.Fatalf("attempt history = %q", notes)
+	}
+}
+
+func TestBookingFallbackNotRetried(t *testing.T) {
+	db := newTestDB(t)
+
+	// the alternative policy proposes another itinerary instead of rebooking
+	_, resp := bookWithFallback(t, db, models.RideFallbackAlternative, "fail:1", nil, nil)
+	if resp.Status != models.BookingFailed || resp.Attempts != 1 || string(resp.Fallback) == "null" {
+		t.Fatalf("alternative: %+v", resp)
+	}
+
+	// a backoff that would outlast BOOKING_ATTEMPTS_TIMEOUT isn't waited for
+	start := time.Now()
+	_, resp = bookWithFallback(t, db, models.RideFallbackRetry, "fail:5", func(cfg *config.Config) {
+		cfg.Booking.RetryBackoff, cfg.Booking.AttemptsTimeout = time.Hour, 100*time.Millisecond
+	}, nil)
+	if resp.Status != models.BookingFailed || resp.Attempts != 1 || time.Since(start) > 5*time.Second {
+		t.Fatalf("bounded retries: %+v after %v", resp, time.Since(start))
+	}
+
+	// a quote the provider no longer knows fails the first attempt; nothing is retried or kept
+	code, resp := bookWithFallback(t, db, models.RideFallbackRetry, ride.ScenarioConfirm, nil, func(plan models.TripPlan) string {
+		leg := plan.Itineraries[0].Legs[0]
+		if err := db.Create(&models.RideQuote{QuoteID: "q_gone", UserID: 1, PlanID: plan.ID, ItineraryID: leg.ItineraryID, LegID: &leg.ID,
+			Provider: "RideNow", FareCents: 12000, ExpiresAt: time.Now().Add(time.Minute)}).Error; err != nil {
+			t.Fatal(err)
+		}
+		return "q_gone"
+	})
+	if code != http.StatusConflict || resp.Code != "quote_expired" {
+		t.Fatalf("unknown quote: %d %+v", code, resp)
+	}
+	if n := countWhere(t, db, &models.RideBooking{}, "quote_id = ?", "q_gone"); n != 0 {
+		t.Fatalf("claim of the unknown quote kept: %d", n)
+	}
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"navmate-backend/internal/adapters/payment"
+	"navmate-backend/internal/adapters/ride"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/repository"
+)
+
+func TestBookingRepositoryTransitionAllIsAllOrNothing(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	plan := samplePlan(1, nil)
+	if err := repository.NewTripRepository(db).CreatePlan(ctx, &plan); err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+	repo := repository.NewBookingRepository(db, nil)
+
+	it := plan.Itineraries[0]
+	legID := it.Legs[0].ID
+	first := &models.RideBooking{PlanID: plan.ID, ItineraryID: it.ID, LegID: &legID, GroupRef: "g1", Provider: "RideNow", Status: models.BookingScheduled}
+	second := &models.RideBooking{PlanID: plan.ID, ItineraryID: it.ID, GroupRef: "g1", Provider: "RideNow", Status: models.BookingScheduled}
+	if err := repo.CreateGroup(ctx, []*models.RideBooking{first, second}, "user"); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+
+	// another worker dispatches the second leg behind our back
+	if err := db.Model(&models.RideBooking{}).Where("id = ?", second.ID).Update("status", models.BookingPending).Error; err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	err := repo.TransitionAll(ctx, []*models.RideBooking{first, second}, models.BookingPending, "system", "dispatch")
+	if !errors.Is(err, repository.ErrStaleBooking) {
+		t.Fatalf("expected ErrStaleBooking, got %v", err)
+	}
+	if first.Status != models.BookingScheduled {
+		t.Fatalf("in-memory status not restored: %s", first.Status)
+	}
+
+	var stored models.RideBooking
+	db.First(&stored, first.ID)
+	if stored.Status != models.BookingScheduled {
+		t.Fatalf("first leg moved without the second: %s", stored.Status)
+	}
+	var events int64
+	db.Model(&models.BookingEvent{}).Where("booking_id = ?", first.ID).Count(&events)
+	if events != 1 {
+		t.Fatalf("expected only the creation event, got %d", events)
+	}
+}
+
+func TestBookingRepositoryTransitionKeepsPaymentLink(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	b, _ := authorizedBooking(t, db, nil, models.BookingConfirmed, 12000)
+	stale := b // read before the payment was claimed
+
+	payments := repository.NewPaymentRepository(db, nil)
+	p := models.Payment{AmountCents: 12000, Currency: "THB", Status: payment.StatusPending, FareCents: 12000, Gateway: "test"}
+	if err := payments.Claim(ctx, &p, &b); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if err := repository.NewBookingRepository(db, nil).Transition(ctx, &stale, models.BookingDriverAssigned, "provider", ""); err != nil {
+		t.Fatalf("transition: %v", err)
+	}
+
+	var stored models.RideBooking
+	db.First(&stored, b.ID)
+	if stored.PaymentID == nil || *stored.PaymentID != p.ID {
+		t.Fatalf("payment link lost: %v", stored.PaymentID)
+	}
+}
+
+func TestBookingRepositoryProviderUpdateWithoutChangesWritesNothing(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	b, _ := authorizedBooking(t, db, nil, models.BookingDriverAssigned, 12000)
+	repo := repository.NewBookingRepository(db, nil)
+
+	lat, lng := 13.75, 100.5
+	u := ride.StatusUpdate{ExternalRef: b.ExternalRef, Status: b.Status, EtaMinutes: 4,
+		Driver: &ride.Driver{Name: "Somchai", VehiclePlate: "1กข 1234", VehicleModel: "Camry", Lat: &lat, Lng: &lng}}
+	if err := repo.ApplyProviderUpdate(ctx, &b, u); err != nil {
+		t.Fatalf("first update: %v", err)
+	}
+	var before models.RideBooking
+	db.First(&before, b.ID)
+
+	if err := repo.ApplyProviderUpdate(ctx, &b, u); err != nil {
+		t.Fatalf("repeated update: %v", err)
+	}
+	var after models.RideBooking
+	db.First(&after, b.ID)
+	if !after.UpdatedAt.Equal(before.UpdatedAt) {
+		t.Fatalf("unchanged update rewrote the booking: %v -> %v", before.UpdatedAt, after.UpdatedAt)
+	}
+}
@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"testing"
+
+	"navmate-backend/internal/models"
+)
+
+func TestBookingTransitions(t *testing.T) {
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{models.BookingScheduled, models.BookingPending, true},
+		{models.BookingScheduled, models.BookingConfirmed, false},
+		{models.BookingPending, models.BookingConfirmed, true},
+		{models.BookingConfirmed, models.BookingDriverAssigned, true},
+		{models.BookingDriverAssigned, models.BookingInProgress, true},
+		{models.BookingInProgress, models.BookingCompleted, true},
+		{models.BookingPending, models.BookingCompleted, false},
+		{models.BookingDriverAssigned, models.BookingConfirmed, false},
+		{models.BookingCompleted, models.BookingCancelled, false},
+		{models.BookingCancelled, models.BookingConfirmed, false},
+	}
+	for _, tc := range cases {
+		if got := models.CanTransition(tc.from, tc.to); got != tc.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", tc.from, tc.to, got, tc.want)
+		}
+	}
+	for _, s := range []string{models.BookingCompleted, models.BookingCancelled, models.BookingDeclined, models.BookingFailed} {
+		if !models.IsTerminal(s) {
+			t.Errorf("%s should be terminal", s)
+		}
+	}
+}
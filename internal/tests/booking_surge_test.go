@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/adapters/ride"
+	"navmate-backend/internal/handlers/booking"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/repository"
+)
+
+func TestAcceptSurgeResurged(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	plan := samplePlan(1, nil)
+	if err := repository.NewTripRepository(db).CreatePlan(ctx, &plan); err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+	it := plan.Itineraries[0]
+
+	// the first booking attempt surged past the user's limit
+	sim := newSim(t, 1, ride.ScenarioSurge, nil)
+	q, _ := sim.Quote(ctx, ride.QuoteRequest{RoughFareCents: 12000, Minutes: 18})
+	br, err := sim.Book(ctx, ride.BookRequest{QuoteID: q.ID})
+	if err != nil || br.SurgeQuote == nil {
+		t.Fatalf("expected a surge, got %+v, %v", br, err)
+	}
+	sq := br.SurgeQuote
+	if err := db.Create(&models.RideQuote{QuoteID: sq.ID, UserID: 1, PlanID: plan.ID, ItineraryID: it.ID, Provider: "Sim",
+		FareCents: sq.FareCents, EtaMinutes: sq.EtaMinutes, SurgeMultiplier: sq.SurgeMultiplier, ExpiresAt: sq.ExpiresAt}).Error; err != nil {
+		t.Fatal(err)
+	}
+	b := models.RideBooking{PlanID: plan.ID, ItineraryID: it.ID, Provider: "Sim", Status: models.BookingNeedsConfirmation,
+		FareCents: q.FareCents, SurgeMultiplier: sq.SurgeMultiplier, SurgeFareCents: sq.FareCents, SurgeQuoteID: sq.ID}
+	if err := repository.NewBookingRepository(db, nil).Create(ctx, &b, "user"); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	h := booking.New(db, ride.NewRegistry("Sim", sim), nil, nil, nil, &config.Config{})
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", 1) })
+	r.POST("/v1/bookings/:id/surge/accept", h.AcceptSurge)
+	accept := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, fmt.Sprintf("/v1/bookings/%d/surge/accept", b.ID), nil))
+		return w
+	}
+
+	// the provider surges again on the accepted quote
+	if err := sim.Force(ride.OutcomeSurge); err != nil {
+		t.Fatal(err)
+	}
+	w := accept()
+	if w.Code != http.StatusConflict {
+		t.Fatalf("re-surge: got %d %s, want 409", w.Code, w.Body)
+	}
+	var resp struct {
+		Code       string           `json:"code"`
+		SurgeQuote models.RideQuote `json:"surge_quote"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Code != "surge_changed" || resp.SurgeQuote.QuoteID == "" || resp.SurgeQuote.QuoteID == sq.ID {
+		t.Fatalf("re-surge response = %s", w.Body)
+	}
+	var stored models.RideBooking
+	db.First(&stored, b.ID)
+	if stored.Status != models.BookingNeedsConfirmation || stored.SurgeQuoteID != resp.SurgeQuote.QuoteID || stored.SurgeFareCents != resp.SurgeQuote.FareCents {
+		t.Fatalf("booking after re-surge = %+v", stored)
+	}
+
+	// accepting the new surge books it
+	if w := accept(); w.Code != http.StatusOK {
+		t.Fatalf("accept new surge: got %d %s", w.Code, w.Body)
+	}
+	db.First(&stored, b.ID)
+	if stored.Status != models.BookingConfirmed || stored.FareCents != resp.SurgeQuote.FareCents {
+		t.Fatalf("booking after accept = %q, fare %d", stored.Status, stored.FareCents)
+	}
+}
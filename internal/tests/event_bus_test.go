@@ -0,0 +1,42 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"navmate-backend/internal/events"
+)
+
+func TestMemoryBusDeliversToOwnerOnly(t *testing.T) {
+	bus := events.NewMemoryBus()
+	mine, unsubscribe := bus.Subscribe(1)
+	other, unsubscribeOther := bus.Subscribe(2)
+	defer unsubscribeOther()
+
+	err := bus.Publish(context.Background(), events.Event{
+		Type: events.TypeBookingUpdated, UserID: 1, Data: map[string]any{"booking_id": 7},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-mine:
+		if e.Type != events.TypeBookingUpdated || e.Data["booking_id"] != 7 || e.At.IsZero() {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event not delivered")
+	}
+	select {
+	case e := <-other:
+		t.Fatalf("user 2 received user 1's event %+v", e)
+	default:
+	}
+
+	unsubscribe()
+	if _, ok := <-mine; ok {
+		t.Fatal("channel still open after unsubscribe")
+	}
+}
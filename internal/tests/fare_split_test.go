@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"navmate-backend/internal/adapters/payment"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/repository"
+)
+
+func TestAllocate(t *testing.T) {
+	// 100.00 between three: the owner, first, takes the odd cent
+	got := models.Allocate(10000, []int{1, 1, 1})
+	if got[0] != 3334 || got[1] != 3333 || got[2] != 3333 {
+		t.Fatalf("equal shares = %v", got)
+	}
+	// a 50.00 cancellation fee in proportion to shares of 60 and 30
+	got = models.Allocate(5000, []int{6000, 3000})
+	if got[0]+got[1] != 5000 || got[0] != 3334 {
+		t.Fatalf("fee shares = %v", got)
+	}
+	if got = models.Allocate(100, []int{0, 0}); got[0] != 0 || got[1] != 0 {
+		t.Fatalf("no weight, got %v", got)
+	}
+}
+
+func TestSplitShareClaim(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	plan := samplePlan(1, nil)
+	if err := repository.NewTripRepository(db).CreatePlan(ctx, &plan); err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+	b := models.RideBooking{PlanID: plan.ID, ItineraryID: plan.Itineraries[0].ID, Provider: "RideNow", Status: models.BookingConfirmed, FareCents: 12000}
+	if err := db.Create(&b).Error; err != nil {
+		t.Fatalf("create booking: %v", err)
+	}
+	splits := repository.NewSplitRepository(db, nil)
+
+	s := models.FareSplit{BookingID: b.ID, OwnerID: 1, Status: models.SplitOpen, OnDecline: models.SplitVoid,
+		Currency: "THB", TotalCents: 12000, ExpiresAt: time.Now().Add(time.Hour)}
+	shares := []models.SplitShare{
+		{UserID: 1, Role: models.ShareOwner, AmountCents: 6000, Status: models.ShareInvited},
+		{UserID: 2, Role: models.ShareParticipant, AmountCents: 6000, Status: models.ShareInvited},
+	}
+	if err := splits.Create(ctx, &s, shares); err != nil {
+		t.Fatalf("create split: %v", err)
+	}
+	again := models.FareSplit{BookingID: b.ID, OwnerID: 1, Status: models.SplitOpen, Currency: "THB", TotalCents: 12000, ExpiresAt: s.ExpiresAt}
+	if err := splits.Create(ctx, &again, nil); !errors.Is(err, repository.ErrSplitExists) {
+		t.Fatalf("expected ErrSplitExists, got %v", err)
+	}
+
+	// the payment's status is mirrored onto the share it pays
+	share := shares[1]
+	p := models.Payment{AmountCents: 6000, Status: payment.StatusPending}
+	if err := splits.ClaimShare(ctx, &p, &share); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if err := repository.NewPaymentRepository(db, nil).ApplyCharge(ctx, &p, payment.Charge{Status: payment.StatusAuthorized}, repository.LedgerMeta{Actor: "user"}); err != nil {
+		t.Fatalf("apply charge: %v", err)
+	}
+	var stored models.SplitShare
+	db.First(&stored, share.ID)
+	if !stored.Paid() || stored.Status != models.ShareAccepted {
+		t.Fatalf("share = %q / %q", stored.Status, stored.PaymentStatus)
+	}
+
+	// a paid share can't be paid again or declined
+	second := stored
+	if err := splits.ClaimShare(ctx, &models.Payment{AmountCents: 6000, Status: payment.StatusPending}, &second); !errors.Is(err, repository.ErrShareNotOpen) {
+		t.Fatalf("expected ErrShareNotOpen, got %v", err)
+	}
+	if err := splits.Decline(ctx, &s, &second); !errors.Is(err, repository.ErrShareNotOpen) {
+		t.Fatalf("decline of a paid share: %v", err)
+	}
+}
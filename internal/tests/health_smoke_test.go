@@ -19,7 +19,7 @@ func TestHealthSmoke(t *testing.T) {
 	cfg := &config.Config{}
 	cfg.Google.GoogleMapsAPIKey = "test-api-key"
 
-	routes.SetupRouter(r, nil, cfg)
+	routes.SetupRouter(r, nil, cfg, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
 	rec := httptest.NewRecorder()
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"navmate-backend/internal/adapters/fx"
+	"navmate-backend/internal/adapters/maps"
+	"navmate-backend/internal/money"
+)
+
+func TestMoneyConvertAcrossMinorUnits(t *testing.T) {
+	// 1,000.00 THB at 4.11 JPY per baht; yen have no minor unit
+	jpy := money.Convert(money.New(100000, "THB"), "JPY", 4.11)
+	if jpy.Cents != 4110 || jpy.Currency != "JPY" {
+		t.Fatalf("THB->JPY: %+v", jpy)
+	}
+	thb := money.Convert(jpy, "THB", 1/4.11)
+	if thb.Cents != 100000 {
+		t.Fatalf("JPY->THB: %+v", thb)
+	}
+	if s := money.New(-12345, "THB").String(); s != "-123.45 THB" {
+		t.Fatalf("String = %q", s)
+	}
+	if s := money.New(500, "JPY").String(); s != "500 JPY" {
+		t.Fatalf("String = %q", s)
+	}
+}
+
+func TestStaticRatesFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	body := `{"base":"USD","as_of":"2026-10-01T00:00:00Z","rates":{"THB":36,"SGD":1.35}}`
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	src, err := fx.New(fx.Options{Source: "static", RatesFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	// crossed through the base: 1 SGD = 36 / 1.35 THB
+	m, rate, err := fx.Convert(ctx, src, money.New(1000, "SGD"), "THB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Cents != 26667 || rate < 26.66 || rate > 26.67 {
+		t.Fatalf("SGD->THB: %+v at %v", m, rate)
+	}
+	if _, _, err := fx.Convert(ctx, src, money.New(1000, "THB"), "JPY"); err == nil {
+		t.Fatal("expected no rate for a currency missing from the file")
+	}
+	if _, err := fx.New(fx.Options{Source: "ecb"}); err == nil {
+		t.Fatal("expected an error for an unknown source")
+	}
+}
+
+func TestFareRulesByRegion(t *testing.T) {
+	th, _ := maps.FareRuleFor("TH")
+	if r := maps.FareRuleAt(1.30, 103.85, th); r.Region != "SG" || r.Currency != "SGD" {
+		t.Fatalf("Singapore priced as %s", r.Region)
+	}
+	if r := maps.FareRuleAt(35.68, 139.69, th); r.Currency != "JPY" {
+		t.Fatalf("Tokyo priced in %s", r.Currency)
+	}
+	if r := maps.FareRuleAt(48.85, 2.35, th); r.Region != "TH" {
+		t.Fatalf("unknown regions fall back to the default, got %s", r.Region)
+	}
+	// 40 THB + 10 km * 8 THB + 20 min * 2 THB
+	if fare := th.RideFare(10000, 20*60); fare != 16000 {
+		t.Fatalf("fare = %d", fare)
+	}
+}
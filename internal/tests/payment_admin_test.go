@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/adapters/payment"
+	paymenthandler "navmate-backend/internal/handlers/payment"
+	"navmate-backend/internal/models"
+)
+
+func TestAdminCaptureAndRefund(t *testing.T) {
+	db := newTestDB(t)
+	gw := newMockGateway(t)
+	b, p := authorizedBooking(t, db, gw, models.BookingCompleted, 12000)
+
+	cfg := &config.Config{}
+	cfg.Money.Currency = "THB"
+	gin.SetMode(gin.TestMode)
+	h := paymenthandler.New(db, gw, nil, nil, nil, cfg)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("email", "support@navmate.app") })
+	r.POST("/v1/admin/payments/:id/capture", h.Capture)
+	r.POST("/v1/admin/payments/:id/refund", h.Refund)
+	post := func(path, key, body string) (int, map[string]any) {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/v1/admin/payments/%d/%s", p.ID, path), bytes.NewBufferString(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var out map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &out)
+		return w.Code, out
+	}
+
+	// the amount is the booking's, whatever the body says
+	if code, out := post("capture", "", `{"amount_cents":1}`); code != http.StatusOK || out["captured_cents"] != float64(b.FareCents) {
+		t.Fatalf("capture: %d %v", code, out)
+	}
+	if code, _ := post("capture", "", ""); code != http.StatusBadRequest {
+		t.Fatalf("second capture: got %d, want 400", code)
+	}
+
+	for i := 0; i < 2; i++ {
+		if code, out := post("refund", "r1", `{"amount_cents":2000}`); code != http.StatusOK || out["refunded_cents"] != float64(2000) {
+			t.Fatalf("refund %d: %d %v", i, code, out)
+		}
+	}
+	if n := countWhere(t, db, &models.PaymentTransaction{}, "payment_id = ? AND kind = ?", p.ID, models.TxRefund); n != 1 {
+		t.Fatalf("%d refund entries, want 1", n)
+	}
+	if code, out := post("refund", "r2", `{"amount_cents":20000}`); code != http.StatusBadRequest || out["available_cents"] != float64(10000) {
+		t.Fatalf("over-refund: %d %v", code, out)
+	}
+
+	var stored models.Payment
+	db.First(&stored, p.ID)
+	if stored.Status != payment.StatusPartiallyRefunded || stored.RefundedCents != 2000 {
+		t.Fatalf("payment = %q, refunded %d", stored.Status, stored.RefundedCents)
+	}
+}
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"navmate-backend/internal/adapters/payment"
+)
+
+// testWebhookSecret is base64 of "whsec-test".
+const testWebhookSecret = "d2hzZWMtdGVzdA=="
+
+func newMockGateway(t *testing.T) *payment.Omise {
+	t.Helper()
+	srv := httptest.NewServer(payment.NewMockServer())
+	t.Cleanup(srv.Close)
+	gw, err := payment.NewOmise("mock", payment.OmiseOptions{
+		BaseURL: srv.URL, SecretKey: "skey_test_123", WebhookSecret: testWebhookSecret, Client: srv.Client(),
+	})
+	if err != nil {
+		t.Fatalf("new gateway: %v", err)
+	}
+	return gw
+}
+
+func TestOmiseGatewayAuthorizeCaptureRefund(t *testing.T) {
+	gw := newMockGateway(t)
+	ctx := context.Background()
+
+	ch, err := gw.Authorize(ctx, payment.AuthRequest{AmountCents: 12000, Currency: "THB", Source: "tokn_test_visa", IdempotencyKey: "k1"})
+	if err != nil || ch.Status != payment.StatusAuthorized || ch.ExternalRef == "" {
+		t.Fatalf("authorize: %+v %v", ch, err)
+	}
+	again, _ := gw.Authorize(ctx, payment.AuthRequest{AmountCents: 12000, Currency: "THB", IdempotencyKey: "k1"})
+	if again.ExternalRef != ch.ExternalRef {
+		t.Fatalf("idempotency key not honoured: %s vs %s", again.ExternalRef, ch.ExternalRef)
+	}
+
+	if _, err := gw.Capture(ctx, ch.ExternalRef, 15000); err == nil {
+		t.Fatal("captured more than authorized")
+	}
+	ch, err = gw.Capture(ctx, ch.ExternalRef, 10000)
+	if err != nil || ch.Status != payment.StatusCaptured || ch.CapturedCents != 10000 {
+		t.Fatalf("capture: %+v %v", ch, err)
+	}
+
+	ch, err = gw.Refund(ctx, ch.ExternalRef, 4000, "r1")
+	if err != nil || ch.Status != payment.StatusPartiallyRefunded || ch.RefundedCents != 4000 {
+		t.Fatalf("partial refund: %+v %v", ch, err)
+	}
+	// a retried refund is not refunded again
+	if ch, err = gw.Refund(ctx, ch.ExternalRef, 4000, "r1"); err != nil || ch.RefundedCents != 4000 {
+		t.Fatalf("retried refund: %+v %v", ch, err)
+	}
+	ch, err = gw.Refund(ctx, ch.ExternalRef, 6000, "r2")
+	if err != nil || ch.Status != payment.StatusRefunded {
+		t.Fatalf("refund: %+v %v", ch, err)
+	}
+	var ge *payment.GatewayError
+	if _, err := gw.Refund(ctx, ch.ExternalRef, 1, ""); !errors.As(err, &ge) {
+		t.Fatalf("expected gateway error refunding past the capture, got %v", err)
+	}
+}
+
+func TestOmiseGatewayDeclineVoidAndNotFound(t *testing.T) {
+	gw := newMockGateway(t)
+	ctx := context.Background()
+
+	ch, err := gw.Authorize(ctx, payment.AuthRequest{AmountCents: 5000, Currency: "THB", Source: payment.MockTokenFailPrefix + "insufficient_fund"})
+	if err != nil || ch.Status != payment.StatusDeclined || ch.FailureCode != "insufficient_fund" {
+		t.Fatalf("expected decline, got %+v %v", ch, err)
+	}
+
+	ch, _ = gw.Authorize(ctx, payment.AuthRequest{AmountCents: 5000, Currency: "THB"})
+	if ch, err = gw.Void(ctx, ch.ExternalRef); err != nil || ch.Status != payment.StatusVoided {
+		t.Fatalf("void: %+v %v", ch, err)
+	}
+	if _, err := gw.Capture(ctx, ch.ExternalRef, 5000); err == nil {
+		t.Fatal("captured a voided charge")
+	}
+
+	if _, err := gw.Status(ctx, "chrg_missing"); !errors.Is(err, payment.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestPaymentGatewaySelection(t *testing.T) {
+	gw, err := payment.New(payment.Options{Gateway: "mock"})
+	if err != nil || gw.Name() != "mock" {
+		t.Fatalf("mock: %v", err)
+	}
+	// the in-process mock needs no server
+	if ch, err := gw.Authorize(context.Background(), payment.AuthRequest{AmountCents: 100, Currency: "THB"}); err != nil || ch.Status != payment.StatusAuthorized {
+		t.Fatalf("in-process mock authorize: %+v %v", ch, err)
+	}
+	if _, err := payment.New(payment.Options{Gateway: "omise"}); err == nil {
+		t.Fatal("omise without a secret key should be rejected")
+	}
+	if _, err := payment.New(payment.Options{Gateway: "paypal"}); err == nil {
+		t.Fatal("unknown gateway should be rejected")
+	}
+}
+
+func TestOmiseGatewaySavedCardsAndWallets(t *testing.T) {
+	gw := newMockGateway(t)
+	ctx := context.Background()
+
+	card, err := gw.SaveCard(ctx, "tokn_test_visa", "test user")
+	if err != nil || card.Ref == "" || card.Last4 == "" {
+		t.Fatalf("save card: %+v %v", card, err)
+	}
+	ch, err := gw.Authorize(ctx, payment.AuthRequest{AmountCents: 5000, Currency: "THB", Customer: card.Ref})
+	if err != nil || ch.Status != payment.StatusAuthorized {
+		t.Fatalf("authorize saved card: %+v %v", ch, err)
+	}
+
+	// a card saved from a failing token keeps failing
+	bad, err := gw.SaveCard(ctx, payment.MockTokenFailPrefix+"stolen_card", "")
+	if err != nil {
+		t.Fatalf("save card: %v", err)
+	}
+	if ch, err := gw.Authorize(ctx, payment.AuthRequest{AmountCents: 5000, Currency: "THB", Customer: bad.Ref}); err != nil || ch.FailureCode != "stolen_card" {
+		t.Fatalf("expected decline: %+v %v", ch, err)
+	}
+
+	// e-wallets wait for the customer
+	ch, err = gw.Authorize(ctx, payment.AuthRequest{AmountCents: 5000, Currency: "THB", SourceType: "truemoney"})
+	if err != nil || ch.Status != payment.StatusPending || ch.AuthorizeURI == "" {
+		t.Fatalf("wallet: %+v %v", ch, err)
+	}
+
+	if err := gw.RemoveCard(ctx, card.Ref); err != nil {
+		t.Fatalf("remove card: %v", err)
+	}
+	if _, err := gw.Authorize(ctx, payment.AuthRequest{AmountCents: 5000, Currency: "THB", Customer: card.Ref}); !errors.Is(err, payment.ErrNotFound) {
+		t.Fatalf("removed card: expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package tests
+
+import (
+	"testing"
+
+	"navmate-backend/internal/models"
+)
+
+func TestPaymentBalanceFromLedger(t *testing.T) {
+	ledger := []models.PaymentTransaction{
+		{Kind: models.TxAuthorize, AmountCents: 15000},
+	}
+	if bal := models.Balance(ledger); bal.Capturable() != 15000 || bal.Refundable() != 0 {
+		t.Fatalf("authorized: %+v", bal)
+	}
+
+	// the final fare was lower than the hold; the rest is released by the capture
+	ledger = append(ledger,
+		models.PaymentTransaction{Kind: models.TxCapture, AmountCents: 12000},
+		models.PaymentTransaction{Kind: models.TxRefund, AmountCents: 2000},
+		models.PaymentTransaction{Kind: models.TxRefund, AmountCents: 3000},
+	)
+	bal := models.Balance(ledger)
+	if bal.CapturedCents != 12000 || bal.RefundedCents != 5000 {
+		t.Fatalf("balance: %+v", bal)
+	}
+	if bal.Capturable() != 0 || bal.Refundable() != 7000 {
+		t.Fatalf("capturable %d refundable %d", bal.Capturable(), bal.Refundable())
+	}
+
+	voided := models.Balance([]models.PaymentTransaction{
+		{Kind: models.TxAuthorize, AmountCents: 15000}, {Kind: models.TxVoid, AmountCents: 15000},
+	})
+	if voided.Capturable() != 0 {
+		t.Fatalf("a voided hold cannot be captured: %+v", voided)
+	}
+}
@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/adapters/payment"
+	"navmate-backend/internal/adapters/ride"
+	"navmate-backend/internal/handlers/booking"
+	paymenthandler "navmate-backend/internal/handlers/payment"
+	"navmate-backend/internal/jobs"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/repository"
+)
+
+func reconcilerCfg() *config.Config {
+	cfg := &config.Config{}
+	cfg.Money.Currency = "THB"
+	cfg.Payment.AuthHold, cfg.Payment.ReconcileInterval = 7*24*time.Hour, time.Minute
+	return cfg
+}
+
+func TestScheduledBookingIsPaidAfterDispatch(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	gw := newMockGateway(t)
+	cfg := reconcilerCfg()
+	cfg.Ride.QuoteTimeout = time.Second
+
+	plan := samplePlan(1, nil)
+	if err := repository.NewTripRepository(db).CreatePlan(ctx, &plan); err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+	leg := plan.Itineraries[0].Legs[0]
+	if err := db.Model(&models.Leg{}).Where("id = ?", leg.ID).Update("fare_cents", 12000).Error; err != nil {
+		t.Fatal(err)
+	}
+	pickup := time.Now().Add(2 * time.Hour)
+	b := models.RideBooking{PlanID: plan.ID, ItineraryID: plan.Itineraries[0].ID, LegID: &leg.ID, Provider: "RideNow",
+		Status: models.BookingScheduled, PickupAt: &pickup, Currency: "THB"}
+	bookings := repository.NewBookingRepository(db, nil)
+	if err := bookings.Create(ctx, &b, "user"); err != nil {
+		t.Fatalf("create booking: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	payH := paymenthandler.New(db, gw, nil, nil, nil, cfg)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", 1) })
+	r.POST("/v1/payments/authorize", payH.Authorize)
+	authorize := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		body := fmt.Sprintf(`{"booking_id":%d,"source":"tokn_test_visa"}`, b.ID)
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/payments/authorize", bytes.NewBufferString(body)))
+		return w
+	}
+
+	// no fare yet: authorizing now would hold only the service fee
+	if w := authorize(); w.Code != http.StatusConflict {
+		t.Fatalf("authorize scheduled: got %d, want 409", w.Code)
+	}
+
+	sim, err := ride.NewSimulator("RideNow", ride.SimulatorOptions{QuoteTTL: time.Minute, Seed: 1, Scenario: ride.ScenarioConfirm})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bookH := booking.New(db, ride.NewRegistry("RideNow", sim), gw, nil, nil, cfg)
+	if err := bookH.Dispatch(ctx, &b); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if b.Status != models.BookingConfirmed || b.FareCents <= 0 {
+		t.Fatalf("dispatched booking: %q, fare %d", b.Status, b.FareCents)
+	}
+
+	w := authorize()
+	if w.Code != http.StatusOK {
+		t.Fatalf("authorize dispatched: %d %s", w.Code, w.Body)
+	}
+	var auth struct {
+		PaymentID uint `json:"payment_id"`
+		Breakdown struct {
+			AmountCents int `json:"amount_cents"`
+		} `json:"breakdown"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &auth)
+	if auth.Breakdown.AmountCents != b.FareCents {
+		t.Fatalf("authorized %d, want the fare %d", auth.Breakdown.AmountCents, b.FareCents)
+	}
+
+	for _, to := range []string{models.BookingInProgress, models.BookingCompleted} {
+		if err := bookings.Transition(ctx, &b, to, "provider", ""); err != nil {
+			t.Fatalf("-> %s: %v", to, err)
+		}
+	}
+	jobs.NewPaymentReconciler(db, gw, nil, cfg, zap.NewNop().Sugar()).Tick(ctx, time.Now())
+
+	var p models.Payment
+	db.First(&p, auth.PaymentID)
+	if p.Status != payment.StatusCaptured || p.CapturedCents != b.FareCents {
+		t.Fatalf("payment after completion: %q, captured %d of %d", p.Status, p.CapturedCents, b.FareCents)
+	}
+}
+
+func TestPaymentReconcilerSettlesEndedBookings(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	gw := newMockGateway(t)
+	cfg := reconcilerCfg()
+
+	completed, completedPay := authorizedBooking(t, db, gw, models.BookingCompleted, 12000)
+	charged, chargedPay := authorizedBooking(t, db, gw, models.BookingCancelled, 12000)
+	if err := db.Model(&models.RideBooking{}).Where("id = ?", charged.ID).Update("cancellation_fee_cents", 3000).Error; err != nil {
+		t.Fatal(err)
+	}
+	_, freePay := authorizedBooking(t, db, gw, models.BookingFailed, 12000)
+	_, stalePay := authorizedBooking(t, db, gw, models.BookingConfirmed, 12000)
+	if err := db.Model(&models.Payment{}).Where("id = ?", stalePay.ID).Update("created_at", time.Now().Add(-cfg.Payment.AuthHold-time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+	_, livePay := authorizedBooking(t, db, gw, models.BookingConfirmed, 12000)
+
+	jobs.NewPaymentReconciler(db, gw, nil, cfg, zap.NewNop().Sugar()).Tick(ctx, time.Now())
+
+	cases := []struct {
+		name     string
+		id       uint
+		status   string
+		captured int
+	}{
+		{"completed ride", completedPay.ID, payment.StatusCaptured, completed.FareCents},
+		{"cancellation fee", chargedPay.ID, payment.StatusCaptured, 3000},
+		{"failed booking", freePay.ID, payment.StatusVoided, 0},
+		{"expired hold", stalePay.ID, payment.StatusExpired, 0},
+		{"ride under way", livePay.ID, payment.StatusAuthorized, 0},
+	}
+	for _, tc := range cases {
+		var p models.Payment
+		db.First(&p, tc.id)
+		if p.Status != tc.status || p.CapturedCents != tc.captured {
+			t.Errorf("%s: %q captured %d, want %q captured %d", tc.name, p.Status, p.CapturedCents, tc.status, tc.captured)
+		}
+	}
+	var b models.RideBooking
+	db.First(&b, charged.ID)
+	if b.PaymentStatus != payment.StatusCaptured {
+		t.Fatalf("booking payment_status = %q", b.PaymentStatus)
+	}
+}
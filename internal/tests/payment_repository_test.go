@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/repository"
+)
+
+func TestPaymentRepositoryClaimPreventsDoubleAuthorization(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	plan := samplePlan(1, nil)
+	if err := repository.NewTripRepository(db).CreatePlan(ctx, &plan); err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+	b := models.RideBooking{PlanID: plan.ID, ItineraryID: plan.Itineraries[0].ID, Provider: "RideNow", Status: models.BookingConfirmed, FareCents: 12000}
+	if err := db.Create(&b).Error; err != nil {
+		t.Fatalf("create booking: %v", err)
+	}
+	repo := repository.NewPaymentRepository(db, nil)
+
+	// two requests read the booking before either linked a payment
+	first, second := b, b
+	p1 := models.Payment{AmountCents: 12000, Status: "pending"}
+	if err := repo.Claim(ctx, &p1, &first); err != nil {
+		t.Fatalf("first claim: %v", err)
+	}
+	p2 := models.Payment{AmountCents: 12000, Status: "pending"}
+	if err := repo.Claim(ctx, &p2, &second); !errors.Is(err, repository.ErrPaymentExists) {
+		t.Fatalf("expected ErrPaymentExists, got %v", err)
+	}
+	var count int64
+	db.Model(&models.Payment{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("the losing claim left a payment behind: %d payments", count)
+	}
+
+	// a gateway failure releases the booking for the next attempt
+	if err := repo.Release(ctx, &p1, &first, nil); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	var stored models.RideBooking
+	db.First(&stored, b.ID)
+	if stored.PaymentID != nil || stored.PaymentStatus != "" {
+		t.Fatalf("booking still linked: %v %q", stored.PaymentID, stored.PaymentStatus)
+	}
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"navmate-backend/internal/adapters/payment"
+)
+
+func webhookRequest(body string, ts time.Time, sig string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/v1/payments/webhook", strings.NewReader(body))
+	r.Header.Set(payment.HeaderWebhookTimestamp, strconv.FormatInt(ts.Unix(), 10))
+	r.Header.Set(payment.HeaderWebhookSignature, sig)
+	return r
+}
+
+func TestOmiseParseWebhookVerifiesSignatureAndAge(t *testing.T) {
+	gw := newMockGateway(t)
+	key := []byte("whsec-test")
+	body := `{"object":"event","id":"evnt_test_1","key":"refund.create","data":{"object":"refund","id":"rfnd_test_1","charge":"chrg_test_1"}}`
+	now := time.Now()
+
+	// a rotated secret sends two signatures; either may match
+	ev, err := gw.ParseWebhook(webhookRequest(body, now, "deadbeef,"+payment.SignWebhook(key, now, []byte(body))))
+	if err != nil {
+		t.Fatalf("valid webhook rejected: %v", err)
+	}
+	if ev.ID != "evnt_test_1" || ev.Type != "refund.create" || ev.ChargeRef != "chrg_test_1" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+
+	tampered := strings.Replace(body, "chrg_test_1", "chrg_test_2", 1)
+	if _, err := gw.ParseWebhook(webhookRequest(tampered, now, payment.SignWebhook(key, now, []byte(body)))); !errors.Is(err, payment.ErrBadSignature) {
+		t.Fatalf("tampered body: expected ErrBadSignature, got %v", err)
+	}
+
+	// a captured request replayed later is refused even with its valid signature
+	old := now.Add(-time.Hour)
+	if _, err := gw.ParseWebhook(webhookRequest(body, old, payment.SignWebhook(key, old, []byte(body)))); !errors.Is(err, payment.ErrStaleWebhook) {
+		t.Fatalf("old webhook: expected ErrStaleWebhook, got %v", err)
+	}
+
+	unsigned, err := payment.NewMockGateway("", 0)
+	if err != nil {
+		t.Fatalf("new gateway: %v", err)
+	}
+	if _, err := unsigned.ParseWebhook(webhookRequest(body, now, payment.SignWebhook(nil, now, []byte(body)))); !errors.Is(err, payment.ErrBadSignature) {
+		t.Fatalf("no secret configured: expected ErrBadSignature, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/jobs"
+	"navmate-backend/internal/models"
+)
+
+// retentionFixture stores an old unselected plan that is swept, one that is
+// too new, and old ones kept for a safety session, a booking and a payment.
+func retentionFixture(t *testing.T, db *gorm.DB) (old, fresh, shared, booked, paid models.TripPlan) {
+	t.Helper()
+	long := time.Now().Add(-10 * 24 * time.Hour)
+	old = createPlanAt(t, db, 1, "Old", "planned", long)
+	fresh = createPlanAt(t, db, 1, "Fresh", "planned", time.Now())
+	shared = createPlanAt(t, db, 1, "Shared", "planned", long)
+	booked = createPlanAt(t, db, 1, "Booked", "planned", long)
+	paid = createPlanAt(t, db, 1, "Paid", "planned", long)
+
+	if err := db.Create(&models.SafetySession{PlanID: shared.ID, ShareToken: "tok", IntervalMinutes: 5, NextDue: time.Now(), StartedAt: time.Now()}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.RideBooking{PlanID: booked.ID, ItineraryID: booked.Itineraries[0].ID, Provider: "RideNow", Status: models.BookingCancelled}).Error; err != nil {
+		t.Fatal(err)
+	}
+	p := models.Payment{AmountCents: 12000, Status: "captured", CapturedCents: 12000}
+	if err := db.Create(&p).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.RideBooking{PlanID: paid.ID, ItineraryID: paid.Itineraries[0].ID, Provider: "RideNow", Status: models.BookingCompleted, PaymentID: &p.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return old, fresh, shared, booked, paid
+}
+
+func sweepPlans(t *testing.T, db *gorm.DB, mode string) {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Trips.PlanRetention, cfg.Trips.RetentionMode = 72*time.Hour, mode
+	n, err := jobs.NewPlanRetention(db, cfg, zap.NewNop().Sugar()).Sweep(context.Background(), time.Now())
+	if err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("swept %d plans, want only the old unused one", n)
+	}
+}
+
+func countWhere(t *testing.T, db *gorm.DB, model any, query string, args ...any) int64 {
+	t.Helper()
+	var n int64
+	if err := db.Model(model).Where(query, args...).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestPlanRetentionArchive(t *testing.T) {
+	db := newTestDB(t)
+	old, fresh, shared, booked, paid := retentionFixture(t, db)
+	sweepPlans(t, db, "archive")
+
+	var stored models.TripPlan
+	db.First(&stored, old.ID)
+	if stored.Status != "archived" {
+		t.Fatalf("old plan status = %q, want archived", stored.Status)
+	}
+	if n := countWhere(t, db, &models.Itinerary{}, "plan_id = ?", old.ID); n != 0 {
+		t.Fatalf("archived plan kept %d itineraries", n)
+	}
+	for _, p := range []models.TripPlan{fresh, shared, booked, paid} {
+		if n := countWhere(t, db, &models.TripPlan{}, "id = ? AND status = ?", p.ID, "planned"); n != 1 {
+			t.Errorf("plan %q was swept", p.Destination)
+		}
+		if n := countWhere(t, db, &models.Itinerary{}, "plan_id = ?", p.ID); n != 2 {
+			t.Errorf("plan %q lost its itineraries", p.Destination)
+		}
+	}
+}
+
+func TestPlanRetentionDelete(t *testing.T) {
+	db := newTestDB(t)
+	old, fresh, shared, booked, paid := retentionFixture(t, db)
+	sweepPlans(t, db, "delete")
+
+	if n := countWhere(t, db, &models.TripPlan{}, "id = ?", old.ID); n != 0 {
+		t.Fatal("old plan was not deleted")
+	}
+	for _, p := range []models.TripPlan{fresh, shared, booked, paid} {
+		if n := countWhere(t, db, &models.TripPlan{}, "id = ?", p.ID); n != 1 {
+			t.Errorf("plan %q was deleted", p.Destination)
+		}
+	}
+	if n := countWhere(t, db, &models.RideBooking{}, "plan_id IN ?", []uint{booked.ID, paid.ID}); n != 2 {
+		t.Fatalf("bookings lost: %d left", n)
+	}
+	if n := countWhere(t, db, &models.Payment{}, "1 = 1"); n != 1 {
+		t.Fatalf("payments lost: %d left", n)
+	}
+}
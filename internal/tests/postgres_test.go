@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"navmate-backend/internal/models"
+)
+
+// newTestDB returns a migrated Postgres database for integration tests.
+// It uses TEST_DATABASE_DSN when set, otherwise starts a throwaway
+// postgres:16-alpine container through docker. Tests are skipped when neither
+// is available.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		dsn = startPostgresContainer(t)
+	}
+
+	var db *gorm.DB
+	var err error
+	for i := 0; i < 30; i++ {
+		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
+			SkipDefaultTransaction: true,
+			Logger:                 logger.Default.LogMode(logger.Silent),
+		})
+		if err == nil {
+			if sqlDB, e := db.DB(); e == nil && sqlDB.Ping() == nil {
+				break
+			}
+		}
+		time.Sleep(time.Second)
+	}
+	if err != nil {
+		t.Fatalf("connect postgres: %v", err)
+	}
+
+	if err := db.AutoMigrate(
+		&models.User{},
+		&models.TripPlan{},
+		&models.Itinerary{},
+		&models.Leg{},
+		&models.RideBooking{},
+		&models.Payment{},
+		&models.SafetySession{},
+		&models.Heartbeat{},
+		&models.TripSchedule{},
+		&models.RideQuote{},
+		&models.UserPreference{},
+		&models.BookingEvent{},
+		&models.PaymentWebhookEvent{},
+		&models.PaymentTransaction{},
+		&models.PaymentMethod{},
+		&models.Promotion{},
+		&models.PromotionRedemption{},
+		&models.CreditTransaction{},
+		&models.Receipt{},
+		&models.InvoiceCounter{},
+		&models.FareSplit{},
+		&models.SplitShare{},
+	); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec("TRUNCATE trip_plans, itineraries, legs, ride_bookings, safety_sessions, booking_events, ride_quotes, payments, payment_webhook_events, payment_transactions, payment_methods, promotions, promotion_redemptions, credit_transactions, receipts, invoice_counters, fare_splits, split_shares RESTART IDENTITY CASCADE")
+	})
+	return db
+}
+
+func startPostgresContainer(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("no TEST_DATABASE_DSN and docker not available")
+	}
+
+	out, err := exec.Command("docker", "run", "-d", "--rm",
+		"-e", "POSTGRES_PASSWORD=postgres", "-e", "POSTGRES_DB=navmate_test",
+		"-p", "127.0.0.1::5432", "postgres:16-alpine").Output()
+	if err != nil {
+		t.Skipf("start postgres container: %v", err)
+	}
+	id := strings.TrimSpace(string(out))
+	t.Cleanup(func() { _ = exec.Command("docker", "stop", id).Run() })
+
+	portOut, err := exec.Command("docker", "port", id, "5432/tcp").Output()
+	if err != nil {
+		t.Fatalf("docker port: %v", err)
+	}
+	// e.g. "127.0.0.1:49153"
+	hostPort := strings.TrimSpace(strings.Split(string(portOut), "\n")[0])
+	port := hostPort[strings.LastIndex(hostPort, ":")+1:]
+
+	return fmt.Sprintf("host=127.0.0.1 port=%s user=postgres password=postgres dbname=navmate_test sslmode=disable", port)
+}
@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/money"
+)
+
+func TestPromotionDiscount(t *testing.T) {
+	pct := models.Promotion{Kind: models.PromoPercent, Value: 20, Currency: "THB", MaxDiscountCents: 5000}
+	if d := pct.Discount(money.New(15000, "THB"), 1); d != 3000 {
+		t.Fatalf("20%% of 150 THB = %d", d)
+	}
+	if d := pct.Discount(money.New(40000, "THB"), 1); d != 5000 {
+		t.Fatalf("capped at 50 THB, got %d", d)
+	}
+	// the cap is converted to the fare's currency: 50 THB at 4.11 JPY per baht
+	if d := pct.Discount(money.New(3000, "JPY"), 4.11); d != 206 {
+		t.Fatalf("cap in JPY = %d", d)
+	}
+
+	fixed := models.Promotion{Kind: models.PromoFixed, Value: 10000, Currency: "THB", MinFareCents: 5000}
+	if d := fixed.Discount(money.New(8000, "THB"), 1); d != 8000 {
+		t.Fatalf("a discount never exceeds the fare, got %d", d)
+	}
+	if d := fixed.Discount(money.New(4000, "THB"), 1); d != 0 {
+		t.Fatalf("fares below the minimum get no discount, got %d", d)
+	}
+}
+
+func TestPromotionAvailability(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+	p := models.Promotion{Active: true, EndsAt: &later, MaxUses: 10, UsedCount: 9, Providers: "grab, bolt", ModeMixes: "RIDE"}
+	if r := p.Unavailable(now); r != "" {
+		t.Fatalf("unavailable: %s", r)
+	}
+	if r := p.Unavailable(later); r != "promo_expired" {
+		t.Fatalf("at ends_at: %q", r)
+	}
+	p.UsedCount = 10
+	if r := p.Unavailable(now); r != "promo_exhausted" {
+		t.Fatalf("used up: %q", r)
+	}
+	if !p.AllowsProvider("Bolt") || p.AllowsProvider("lineman") {
+		t.Fatal("provider restriction")
+	}
+	if p.AllowsModeMix("WALK+TRANSIT+RIDE") {
+		t.Fatal("mode restriction")
+	}
+	if !(models.Promotion{}).AllowsProvider("lineman") {
+		t.Fatal("no restriction allows any provider")
+	}
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/receipts"
+)
+
+func TestInclusiveTax(t *testing.T) {
+	if tax := receipts.InclusiveTax(10700, 700); tax != 700 {
+		t.Fatalf("7%% VAT in 107.00 = %d", tax)
+	}
+	if tax := receipts.InclusiveTax(10700, 0); tax != 0 {
+		t.Fatalf("no VAT, got %d", tax)
+	}
+}
+
+func TestReceiptBuild(t *testing.T) {
+	p := models.Payment{
+		ID: 7, Currency: "THB", FareCents: 20000, FeeCents: 1000, TipCents: 500,
+		DiscountCents: 3000, AmountCents: 18500, CapturedCents: 19000,
+	}
+	done := []models.RideBooking{{ID: 1, Status: models.BookingCompleted, PromoCode: "HELLO"}}
+	doc := receipts.Build(receipts.Input{Payment: p, Bookings: done, TaxRateBP: 700})
+
+	want := []receipts.Line{
+		{Description: "Fare", AmountCents: 20000},
+		{Description: "Service fee", AmountCents: 1000},
+		{Description: "Tip", AmountCents: 500},
+		{Description: "Discount (HELLO)", AmountCents: -3000},
+		{Description: "Adjustment at capture", AmountCents: 500},
+	}
+	if len(doc.Lines) != len(want) {
+		t.Fatalf("lines = %+v", doc.Lines)
+	}
+	for i := range want {
+		if doc.Lines[i] != want[i] {
+			t.Fatalf("line %d = %+v, want %+v", i, doc.Lines[i], want[i])
+		}
+	}
+	if doc.TotalCents != 19000 || doc.NetCents+doc.TaxCents != 19000 || doc.TaxInvoice {
+		t.Fatalf("totals = %d net %d tax %d", doc.TotalCents, doc.NetCents, doc.TaxCents)
+	}
+
+	// a cancelled booking only shows the cancellation fee
+	p.CapturedCents = 4000
+	cancelled := []models.RideBooking{{ID: 1, Status: models.BookingCancelled}}
+	doc = receipts.Build(receipts.Input{Payment: p, Bookings: cancelled, Buyer: receipts.Party{Name: "ACME", TaxID: "0105551234567"}})
+	if len(doc.Lines) != 1 || doc.Lines[0].AmountCents != 4000 || !doc.TaxInvoice {
+		t.Fatalf("cancellation receipt = %+v", doc)
+	}
+}
+
+func TestReceiptPDF(t *testing.T) {
+	doc := receipts.Document{Number: "NM-2026-000001", Currency: "THB", TotalCents: 10700, Trip: receipts.Trip{Origin: "สยาม (Siam)", Destination: "Silom"}}
+	pdf := receipts.PDF(doc)
+	if !bytes.HasPrefix(pdf, []byte("%PDF-")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatalf("not a PDF: %q", pdf[:min(len(pdf), 40)])
+	}
+	if !bytes.Contains(pdf, []byte("(Siam\\)")) {
+		t.Fatal("parentheses are not escaped")
+	}
+}
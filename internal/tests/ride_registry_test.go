@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"navmate-backend/internal/adapters/ride"
+)
+
+// fakeProvider answers quotes with a fixed fare after an optional delay.
+type fakeProvider struct {
+	name  string
+	fare  int
+	delay time.Duration
+	err   error
+}
+
+func (f *fakeProvider) Name() string { return f.name }
+
+func (f *fakeProvider) Quote(ctx context.Context, _ ride.QuoteRequest) (ride.Quote, error) {
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return ride.Quote{}, ctx.Err()
+	}
+	if f.err != nil {
+		return ride.Quote{}, f.err
+	}
+	return ride.Quote{ID: f.name + "-q", Provider: f.name, FareCents: f.fare}, nil
+}
+
+func (f *fakeProvider) Book(context.Context, ride.BookRequest) (ride.BookingResult, error) {
+	return ride.BookingResult{}, nil
+}
+func (f *fakeProvider) Cancel(context.Context, string) error { return nil }
+func (f *fakeProvider) Status(context.Context, string) (ride.StatusUpdate, error) {
+	return ride.StatusUpdate{}, nil
+}
+func (f *fakeProvider) ParseWebhook(*http.Request) (ride.StatusUpdate, error) {
+	return ride.StatusUpdate{}, nil
+}
+
+func TestCompareQuotesOrdersAndTimesOut(t *testing.T) {
+	reg := ride.NewRegistry("A",
+		&fakeProvider{name: "A", fare: 15000},
+		&fakeProvider{name: "B", fare: 11000},
+		&fakeProvider{name: "Slow", fare: 5000, delay: time.Second},
+		&fakeProvider{name: "Broken", err: errors.New("down")},
+	)
+
+	start := time.Now()
+	res := reg.CompareQuotes(context.Background(), ride.QuoteRequest{RoughFareCents: 12000}, 100*time.Millisecond)
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("comparison did not respect timeout")
+	}
+
+	if len(res) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(res))
+	}
+	if res[0].Provider != "B" || res[1].Provider != "A" {
+		t.Fatalf("expected cheapest first, got %s then %s", res[0].Provider, res[1].Provider)
+	}
+	for _, r := range res[2:] {
+		if r.Quote != nil || r.Error == "" {
+			t.Errorf("%s: expected error result, got %+v", r.Provider, r)
+		}
+	}
+}
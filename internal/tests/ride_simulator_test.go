@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"navmate-backend/internal/adapters/ride"
+)
+
+func newSim(t *testing.T, seed int64, scenario string, clock *ride.SimClock) *ride.Simulator {
+	t.Helper()
+	sim, err := ride.NewSimulator("Sim", ride.SimulatorOptions{
+		PriceFactor: 1, QuoteTTL: 5 * time.Minute, Seed: seed, Clock: clock, Scenario: scenario,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sim
+}
+
+func bookOnce(t *testing.T, sim *ride.Simulator) ride.BookingResult {
+	t.Helper()
+	ctx := context.Background()
+	q, err := sim.Quote(ctx, ride.QuoteRequest{RoughFareCents: 10000, Minutes: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	br, err := sim.Book(ctx, ride.BookRequest{QuoteID: q.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return br
+}
+
+func TestSimulatorSameSeedSameOutcomes(t *testing.T) {
+	a, b := newSim(t, 42, ride.ScenarioRandom, nil), newSim(t, 42, ride.ScenarioRandom, nil)
+	for i := 0; i < 20; i++ {
+		ra, rb := bookOnce(t, a), bookOnce(t, b)
+		if ra.Status != rb.Status || ra.EtaMinutes != rb.EtaMinutes || ra.ExternalRef != rb.ExternalRef {
+			t.Fatalf("call %d diverged: %+v vs %+v", i, ra, rb)
+		}
+	}
+}
+
+func TestSimulatorRejectsUnknownScenario(t *testing.T) {
+	if _, err := ride.NewSimulator("Sim", ride.SimulatorOptions{Scenario: "sometimes"}); err == nil {
+		t.Fatal("expected an error for an unknown scenario")
+	}
+}
+
+func TestSimulatorScripts(t *testing.T) {
+	sim := newSim(t, 1, "fail:2", nil)
+	for i, want := range []string{"failed", "failed", "confirmed"} {
+		if got := bookOnce(t, sim).Status; got != want {
+			t.Fatalf("attempt %d: got %s, want %s", i+1, got, want)
+		}
+	}
+
+	if err := sim.SetScenario(ride.ScenarioSurge); err != nil {
+		t.Fatal(err)
+	}
+	br := bookOnce(t, sim)
+	if br.Status != "surge_too_high" || br.SurgeQuote == nil || br.SurgeQuote.SurgeMultiplier != 1.8 {
+		t.Fatalf("expected 1.8x surge, got %+v", br)
+	}
+	accepted, err := sim.Book(context.Background(), ride.BookRequest{QuoteID: br.SurgeQuote.ID, AcceptSurge: true})
+	if err != nil || accepted.Status != "confirmed" {
+		t.Fatalf("accepting surge: %+v, %v", accepted, err)
+	}
+
+	if err := sim.Force(ride.OutcomeFail); err != nil {
+		t.Fatal(err)
+	}
+	if got := bookOnce(t, sim).Status; got != "failed" {
+		t.Fatalf("forced outcome ignored, got %s", got)
+	}
+}
+
+func TestSimulatorDriverProgression(t *testing.T) {
+	start := time.Date(2025, 9, 1, 8, 0, 0, 0, time.UTC)
+	clock := ride.NewSimClock(func() time.Time { return start })
+	sim := newSim(t, 7, ride.ScenarioConfirm, clock)
+	br := bookOnce(t, sim)
+	ctx := context.Background()
+
+	status := func() ride.StatusUpdate {
+		u, err := sim.Status(ctx, br.ExternalRef)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	if u := status(); u.Status != "confirmed" || u.Driver != nil {
+		t.Fatalf("at booking: %+v", u)
+	}
+	clock.Advance(time.Minute)
+	if u := status(); u.Status != "driver_assigned" || u.Driver == nil {
+		t.Fatalf("after 1m: %+v", u)
+	}
+	clock.Advance(time.Duration(br.EtaMinutes) * time.Minute)
+	if u := status(); u.Status != "in_progress" {
+		t.Fatalf("after pickup: %+v", u)
+	}
+	clock.Advance(20 * time.Minute)
+	if u := status(); u.Status != "completed" {
+		t.Fatalf("after trip: %+v", u)
+	}
+}
+
+func rideWebhookRequest(body string, ts time.Time, sig string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/v1/rides/webhook/Sim", strings.NewReader(body))
+	r.Header.Set(ride.HeaderWebhookTimestamp, strconv.FormatInt(ts.Unix(), 10))
+	r.Header.Set(ride.HeaderWebhookSignature, sig)
+	return r
+}
+
+func TestSimulatorWebhookRequiresSignature(t *testing.T) {
+	key := []byte("ride-secret")
+	sim, err := ride.NewSimulator("Sim", ride.SimulatorOptions{WebhookSecret: string(key)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"external_ref":"Sim-abc","status":"cancelled"}`
+	now := time.Now()
+
+	u, err := sim.ParseWebhook(rideWebhookRequest(body, now, ride.SignWebhook(key, now, []byte(body))))
+	if err != nil || u.ExternalRef != "Sim-abc" || u.Status != "cancelled" {
+		t.Fatalf("signed webhook: %+v, %v", u, err)
+	}
+	if _, err := sim.ParseWebhook(rideWebhookRequest(body, now, "")); !errors.Is(err, ride.ErrBadSignature) {
+		t.Fatalf("unsigned: expected ErrBadSignature, got %v", err)
+	}
+	forged := strings.Replace(body, "Sim-abc", "Sim-xyz", 1)
+	if _, err := sim.ParseWebhook(rideWebhookRequest(forged, now, ride.SignWebhook(key, now, []byte(body)))); !errors.Is(err, ride.ErrBadSignature) {
+		t.Fatalf("tampered: expected ErrBadSignature, got %v", err)
+	}
+	old := now.Add(-time.Hour)
+	if _, err := sim.ParseWebhook(rideWebhookRequest(body, old, ride.SignWebhook(key, old, []byte(body)))); !errors.Is(err, ride.ErrStaleWebhook) {
+		t.Fatalf("replayed: expected ErrStaleWebhook, got %v", err)
+	}
+
+	// without a secret nothing is accepted, signed or not
+	open := newSim(t, 1, ride.ScenarioConfirm, nil)
+	if _, err := open.ParseWebhook(rideWebhookRequest(body, now, ride.SignWebhook(nil, now, []byte(body)))); !errors.Is(err, ride.ErrBadSignature) {
+		t.Fatalf("no secret: expected ErrBadSignature, got %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"navmate-backend/config"
+	"navmate-backend/internal/handlers/travel"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/repository"
+)
+
+// travelRouter serves the travel handler as userID, without the JWT middleware.
+func travelRouter(db *gorm.DB, userID int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{}
+	cfg.Google.GoogleMapsAPIKey = "test-api-key"
+	h := travel.New(db, nil, cfg, zap.NewNop().Sugar())
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", userID) })
+	r.GET("/v1/trips/plans", h.ListPlans)
+	r.GET("/v1/trips/plans/:id", h.GetPlan)
+	r.GET("/v1/me/stats", h.Stats)
+	return r
+}
+
+func getJSON(t *testing.T, r *gin.Engine, url string, out any) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if out != nil && w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("decode %s: %v", url, err)
+		}
+	}
+	return w.Code
+}
+
+// createPlanAt stores a sample plan for userID to destination, created at.
+func createPlanAt(t *testing.T, db *gorm.DB, userID uint, destination, status string, at time.Time) models.TripPlan {
+	t.Helper()
+	plan := samplePlan(userID, nil)
+	plan.Destination, plan.Status, plan.CreatedAt = destination, status, at
+	if err := repository.NewTripRepository(db).CreatePlan(context.Background(), &plan); err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+	return plan
+}
+
+func TestListPlansFilters(t *testing.T) {
+	db := newTestDB(t)
+	day := func(d int) time.Time { return time.Date(2025, 9, d, 12, 0, 0, 0, time.Local) }
+	siam := createPlanAt(t, db, 1, "Siam Paragon", "planned", day(1))
+	cancelled := createPlanAt(t, db, 1, "Chatuchak", "cancelled", day(2))
+	latest := createPlanAt(t, db, 1, "Silom", "planned", day(3))
+	createPlanAt(t, db, 2, "Siam Square", "planned", day(3)) // someone else's
+	r := travelRouter(db, 1)
+
+	type page struct {
+		Items      []models.TripPlan `json:"items"`
+		NextCursor *uint             `json:"next_cursor"`
+	}
+	ids := func(p page) []uint {
+		out := make([]uint, 0, len(p.Items))
+		for _, it := range p.Items {
+			out = append(out, it.ID)
+		}
+		return out
+	}
+
+	// the cursor is the last id of a full page; the page after it holds only older plans
+	var first page
+	if code := getJSON(t, r, "/v1/trips/plans?limit=2", &first); code != http.StatusOK {
+		t.Fatalf("first page: %d", code)
+	}
+	if got := ids(first); len(got) != 2 || got[0] != latest.ID || got[1] != cancelled.ID || first.NextCursor == nil || *first.NextCursor != cancelled.ID {
+		t.Fatalf("first page = %v, cursor %v", got, first.NextCursor)
+	}
+	var second page
+	getJSON(t, r, fmt.Sprintf("/v1/trips/plans?limit=2&cursor=%d", *first.NextCursor), &second)
+	if got := ids(second); len(got) != 1 || got[0] != siam.ID || second.NextCursor != nil {
+		t.Fatalf("second page = %v, cursor %v", got, second.NextCursor)
+	}
+	// exactly limit plans left: no further page
+	var exact page
+	getJSON(t, r, fmt.Sprintf("/v1/trips/plans?limit=2&cursor=%d", latest.ID), &exact)
+	if len(exact.Items) != 2 || exact.NextCursor != nil {
+		t.Fatalf("exact page = %v, cursor %v", ids(exact), exact.NextCursor)
+	}
+
+	cases := []struct {
+		query string
+		want  []uint
+	}{
+		{"status=cancelled", []uint{cancelled.ID}},
+		{"destination=SIAM", []uint{siam.ID}},
+		// wildcards in the input are matched literally
+		{"destination=%25", []uint{}},
+		{"destination=S_lom", []uint{}},
+		{"from=2025-09-02", []uint{latest.ID, cancelled.ID}},
+		// a bare "to" date includes the whole day
+		{"to=2025-09-02", []uint{cancelled.ID, siam.ID}},
+		{"from=2025-09-02&to=2025-09-02", []uint{cancelled.ID}},
+		{"to=" + day(2).Add(-time.Hour).Format(time.RFC3339), []uint{siam.ID}},
+	}
+	for _, tc := range cases {
+		var p page
+		if code := getJSON(t, r, "/v1/trips/plans?"+tc.query, &p); code != http.StatusOK {
+			t.Fatalf("%s: status %d", tc.query, code)
+		}
+		if got := ids(p); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.query, got, tc.want)
+		}
+	}
+
+	for _, q := range []string{"from=2025-13-01", "to=yesterday", "cursor=abc", "limit=0"} {
+		if code := getJSON(t, r, "/v1/trips/plans?"+q, nil); code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", q, code)
+		}
+	}
+}
+
+func TestGetPlanInclude(t *testing.T) {
+	db := newTestDB(t)
+	plan := createPlanAt(t, db, 1, "Siam", "planned", time.Now())
+	ride := plan.Itineraries[0]
+	if err := db.Model(&models.Leg{}).Where("id = ?", ride.Legs[0].ID).Update("fare_cents", 12000).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&models.Itinerary{}).Where("id = ?", ride.ID).Update("currency", "THB").Error; err != nil {
+		t.Fatal(err)
+	}
+	r := travelRouter(db, 1)
+
+	type leg struct {
+		Index     int     `json:"index"`
+		Mode      string  `json:"mode"`
+		Provider  *string `json:"provider"`
+		FareCents int     `json:"fare_cents"`
+		Currency  string  `json:"currency"`
+	}
+	var full struct {
+		ItineraryCount int `json:"itinerary_count"`
+		Itineraries    []struct {
+			ID   uint  `json:"id"`
+			Legs []leg `json:"legs"`
+		} `json:"itineraries"`
+	}
+	if code := getJSON(t, r, fmt.Sprintf("/v1/trips/plans/%d", plan.ID), &full); code != http.StatusOK {
+		t.Fatalf("get plan: %d", code)
+	}
+	if full.ItineraryCount != 2 || len(full.Itineraries) != 2 || len(full.Itineraries[1].Legs) != 3 {
+		t.Fatalf("full plan = %+v", full)
+	}
+	if l := full.Itineraries[1].Legs; l[0].Index != 0 || l[2].Index != 2 || l[1].Mode != "TRANSIT" {
+		t.Fatalf("legs out of order: %+v", l)
+	}
+	if p := full.Itineraries[0].Legs[0].Provider; p == nil || *p != "RideNow" {
+		t.Fatalf("ride leg provider = %v", p)
+	}
+	if l := full.Itineraries[0].Legs[0]; l.FareCents != 12000 || l.Currency != "THB" {
+		t.Fatalf("ride leg fare = %d %s, want 12000 THB", l.FareCents, l.Currency)
+	}
+
+	var summary map[string]any
+	getJSON(t, r, fmt.Sprintf("/v1/trips/plans/%d?include=none", plan.ID), &summary)
+	if _, ok := summary["itineraries"]; ok || summary["itinerary_count"] != float64(2) {
+		t.Fatalf("include=none = %v", summary)
+	}
+	var noLegs struct {
+		Itineraries []struct {
+			Legs []leg `json:"legs"`
+		} `json:"itineraries"`
+	}
+	getJSON(t, r, fmt.Sprintf("/v1/trips/plans/%d?include=itineraries", plan.ID), &noLegs)
+	if len(noLegs.Itineraries) != 2 || noLegs.Itineraries[0].Legs != nil {
+		t.Fatalf("include=itineraries = %+v", noLegs)
+	}
+
+	if code := getJSON(t, travelRouter(db, 2), fmt.Sprintf("/v1/trips/plans/%d", plan.ID), nil); code != http.StatusNotFound {
+		t.Fatalf("other user's plan: got %d, want 404", code)
+	}
+}
+
+func TestStatsCountsSelectedTrips(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	chosen := createPlanAt(t, db, 1, "Siam", "selected", now)
+	createPlanAt(t, db, 1, "Silom", "planned", now) // no itinerary chosen
+	dropped := createPlanAt(t, db, 1, "Ari", "cancelled", now)
+	for _, p := range []models.TripPlan{chosen, dropped} {
+		if err := db.Model(&models.TripPlan{}).Where("id = ?", p.ID).Update("selected_itinerary_id", p.Itineraries[0].ID).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// what was charged: a fare less 2000 credit with 500 refunded, a fare in
+	// another currency, a cancellation fee, and a hold never captured
+	payments := []models.Payment{
+		{AmountCents: 10000, Currency: "THB", Status: "partially_refunded", CapturedCents: 10000, RefundedCents: 500},
+		{AmountCents: 1500, Currency: "USD", Status: "captured", CapturedCents: 1500},
+		{AmountCents: 9000, Currency: "THB", Status: "captured", CapturedCents: 3000},
+		{AmountCents: 8000, Currency: "THB", Status: "authorized"},
+	}
+	if err := db.Create(&payments).Error; err != nil {
+		t.Fatal(err)
+	}
+	bookings := []models.RideBooking{
+		{PlanID: chosen.ID, ItineraryID: chosen.Itineraries[0].ID, Provider: "RideNow", Status: models.BookingCompleted, FareCents: 12000, Currency: "THB", CreditCents: 2000},
+		{PlanID: chosen.ID, ItineraryID: chosen.Itineraries[0].ID, Provider: "RideNow", Status: models.BookingCompleted, FareCents: 1500, Currency: "USD"},
+		{PlanID: chosen.ID, ItineraryID: chosen.Itineraries[0].ID, Provider: "RideNow", Status: models.BookingCancelled, FareCents: 9000, Currency: "THB", CancellationFeeCents: 3000},
+		{PlanID: chosen.ID, ItineraryID: chosen.Itineraries[0].ID, Provider: "RideNow", Status: models.BookingConfirmed, FareCents: 8000, Currency: "THB"},
+	}
+	for i := range bookings {
+		bookings[i].PaymentID = &payments[i].ID
+	}
+	if err := db.Create(&bookings).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var stats struct {
+		TotalTrips   int64            `json:"total_trips"`
+		TotalMinutes int              `json:"total_minutes"`
+		RideSpent    map[string]int64 `json:"ride_spent"`
+		ByMode       map[string]struct {
+			DistanceM int64 `json:"distance_m"`
+		} `json:"by_mode"`
+	}
+	if code := getJSON(t, travelRouter(db, 1), "/v1/me/stats", &stats); code != http.StatusOK {
+		t.Fatalf("stats: %d", code)
+	}
+	// only the chosen, not cancelled plan counts; its itinerary is the single 18 min ride
+	if stats.TotalTrips != 1 || stats.TotalMinutes != 18 || stats.ByMode["RIDE"].DistanceM != 9000 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	// amounts in different currencies are not added up
+	if len(stats.RideSpent) != 2 || stats.RideSpent["THB"] != 12500 || stats.RideSpent["USD"] != 1500 {
+		t.Fatalf("ride spent = %v, want THB 12500 and USD 1500", stats.RideSpent)
+	}
+}
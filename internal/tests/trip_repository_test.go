@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"navmate-backend/internal/adapters/maps"
+	"navmate-backend/internal/models"
+	"navmate-backend/internal/repository"
+)
+
+func samplePlan(userID uint, expiresAt *time.Time) models.TripPlan {
+	ride := "RideNow"
+	opts := []maps.ItinOpt{
+		{ModeMix: "RIDE", TotalMinutes: 18, RoughCostCents: 12000, Legs: []maps.LegOpt{
+			{Mode: "RIDE", From: "A", To: "B", Minutes: 18, DistanceM: 9000, Provider: &ride},
+		}},
+		{ModeMix: "WALK+TRANSIT", TotalMinutes: 42, RoughCostCents: 3000, Legs: []maps.LegOpt{
+			{Mode: "WALK", From: "A", To: "S1", Minutes: 8, DistanceM: 600},
+			{Mode: "TRANSIT", From: "S1", To: "S2", Minutes: 30, DistanceM: 12000},
+			{Mode: "WALK", From: "S2", To: "B", Minutes: 4, DistanceM: 300},
+		}},
+	}
+	return repository.BuildPlan(userID, "A", "B", nil, opts, expiresAt)
+}
+
+func TestTripRepositoryCreatePlan(t *testing.T) {
+	db := newTestDB(t)
+	repo := repository.NewTripRepository(db)
+	ctx := context.Background()
+
+	plan := samplePlan(1, nil)
+	if err := repo.CreatePlan(ctx, &plan); err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+	if plan.ID == 0 || plan.Itineraries[1].ID == 0 || plan.Itineraries[1].Legs[2].ItineraryID != plan.Itineraries[1].ID {
+		t.Fatalf("ids not written back: %+v", plan)
+	}
+
+	var legs int64
+	db.Model(&models.Leg{}).Count(&legs)
+	if legs != 4 {
+		t.Fatalf("expected 4 legs, got %d", legs)
+	}
+}
+
+func TestTripRepositoryCreatePlanRollsBack(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	// fail every insert into legs so the plan and itineraries must be rolled back
+	if err := db.Callback().Create().Before("gorm:create").Register("test:fail_legs", func(tx *gorm.DB) {
+		if tx.Statement.Table == "legs" {
+			_ = tx.AddError(errors.New("boom"))
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Callback().Create().Remove("test:fail_legs") })
+
+	plan := samplePlan(1, nil)
+	if err := repository.NewTripRepository(db).CreatePlan(ctx, &plan); err == nil {
+		t.Fatal("expected error")
+	}
+
+	var plans, itins int64
+	db.Model(&models.TripPlan{}).Count(&plans)
+	db.Model(&models.Itinerary{}).Count(&itins)
+	if plans != 0 || itins != 0 {
+		t.Fatalf("expected rollback, found %d plans and %d itineraries", plans, itins)
+	}
+}
+
+func TestTripRepositoryCreateSelectedPlan(t *testing.T) {
+	db := newTestDB(t)
+	repo := repository.NewTripRepository(db)
+	ctx := context.Background()
+
+	plan := samplePlan(1, nil)
+	if err := repo.CreateSelectedPlan(ctx, &plan, 1); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	var stored models.TripPlan
+	db.First(&stored, plan.ID)
+	if stored.Status != "selected" || stored.SelectedItineraryID == nil || *stored.SelectedItineraryID != plan.Itineraries[1].ID {
+		t.Fatalf("stored plan = %q, selected %v", stored.Status, stored.SelectedItineraryID)
+	}
+
+	// a failed selection takes the plan with it
+	if err := db.Callback().Update().Before("gorm:update").Register("test:fail_select", func(tx *gorm.DB) {
+		if tx.Statement.Table == "trip_plans" {
+			_ = tx.AddError(errors.New("boom"))
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Callback().Update().Remove("test:fail_select") })
+	again := samplePlan(1, nil)
+	if err := repo.CreateSelectedPlan(ctx, &again, 0); err == nil {
+		t.Fatal("expected error")
+	}
+	if n := countWhere(t, db, &models.TripPlan{}, "id <> ?", plan.ID); n != 0 {
+		t.Fatalf("expected rollback, found %d more plans", n)
+	}
+}
+
+func TestTripRepositorySelectItineraryErrors(t *testing.T) {
+	db := newTestDB(t)
+	repo := repository.NewTripRepository(db)
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Minute)
+	plan := samplePlan(1, &past)
+	if err := repo.CreatePlan(ctx, &plan); err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+
+	if _, err := repo.SelectItinerary(ctx, 2, plan.ID, plan.Itineraries[0].ID, time.Now()); !errors.Is(err, repository.ErrPlanNotFound) {
+		t.Errorf("other user: got %v, want ErrPlanNotFound", err)
+	}
+	if _, err := repo.SelectItinerary(ctx, 1, plan.ID, 999999, time.Now()); !errors.Is(err, repository.ErrItineraryNotFound) {
+		t.Errorf("unknown itinerary: got %v, want ErrItineraryNotFound", err)
+	}
+	if _, err := repo.SelectItinerary(ctx, 1, plan.ID, plan.Itineraries[0].ID, time.Now()); !errors.Is(err, repository.ErrItineraryExpired) {
+		t.Errorf("expired itinerary: got %v, want ErrItineraryExpired", err)
+	}
+}
@@ -0,0 +1,43 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"navmate-backend/internal/models"
+)
+
+func TestTripScheduleNextDeparture(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		t.Skipf("tzdata unavailable: %v", err)
+	}
+	s := models.TripSchedule{Weekdays: "mon,wed,fri", DepartTime: "08:00", Timezone: "Asia/Bangkok"}
+
+	cases := []struct {
+		name  string
+		after time.Time
+		want  time.Time
+	}{
+		// 2025-09-01 is a Monday
+		{"before departure same day", time.Date(2025, 9, 1, 7, 0, 0, 0, loc), time.Date(2025, 9, 1, 8, 0, 0, 0, loc)},
+		{"exactly at departure", time.Date(2025, 9, 1, 8, 0, 0, 0, loc), time.Date(2025, 9, 3, 8, 0, 0, 0, loc)},
+		{"friday evening wraps to monday", time.Date(2025, 9, 5, 18, 0, 0, 0, loc), time.Date(2025, 9, 8, 8, 0, 0, 0, loc)},
+	}
+	for _, tc := range cases {
+		got, err := s.NextDeparture(tc.after)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTripScheduleRejectsBadWeekday(t *testing.T) {
+	s := models.TripSchedule{Weekdays: "mon,funday", DepartTime: "08:00", Timezone: "UTC"}
+	if _, err := s.NextDeparture(time.Now()); err == nil {
+		t.Fatal("expected error for unknown weekday")
+	}
+}
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_trip_plans_schedule_id;
+DROP INDEX IF EXISTS idx_trip_schedules_active;
+DROP INDEX IF EXISTS idx_trip_schedules_user_id;
+
+ALTER TABLE trip_plans DROP COLUMN IF EXISTS schedule_id;
+
+DROP TABLE IF EXISTS trip_schedules;
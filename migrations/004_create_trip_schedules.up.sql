@@ -0,0 +1,23 @@
+-- Trip Schedules (recurring trips)
+CREATE TABLE IF NOT EXISTS trip_schedules (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    origin VARCHAR(255) NOT NULL,
+    destination VARCHAR(255) NOT NULL,
+    weekdays VARCHAR(50) NOT NULL,
+    depart_time VARCHAR(5) NOT NULL,
+    timezone VARCHAR(64) DEFAULT 'Asia/Bangkok' NOT NULL,
+    lead_minutes INTEGER DEFAULT 60 NOT NULL,
+    auto_select VARCHAR(20) DEFAULT '' NOT NULL,
+    active BOOLEAN DEFAULT TRUE NOT NULL,
+    last_planned_for TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+ALTER TABLE trip_plans ADD COLUMN IF NOT EXISTS schedule_id INTEGER NULL REFERENCES trip_schedules(id) ON DELETE SET NULL;
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_trip_schedules_user_id ON trip_schedules(user_id);
+CREATE INDEX IF NOT EXISTS idx_trip_schedules_active ON trip_schedules(active);
+CREATE INDEX IF NOT EXISTS idx_trip_plans_schedule_id ON trip_plans(schedule_id);
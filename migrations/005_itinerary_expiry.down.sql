@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_trip_plans_created_at;
+DROP INDEX IF EXISTS idx_itineraries_expires_at;
+
+ALTER TABLE itineraries DROP COLUMN IF EXISTS expires_at;
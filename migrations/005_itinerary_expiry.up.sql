@@ -0,0 +1,6 @@
+-- Itinerary validity window
+ALTER TABLE itineraries ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP NULL;
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_itineraries_expires_at ON itineraries(expires_at);
+CREATE INDEX IF NOT EXISTS idx_trip_plans_created_at ON trip_plans(created_at);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_ride_bookings_external_ref;
@@ -0,0 +1,2 @@
+-- Lookup of bookings by provider reference (provider webhooks)
+CREATE INDEX IF NOT EXISTS idx_ride_bookings_external_ref ON ride_bookings(external_ref);
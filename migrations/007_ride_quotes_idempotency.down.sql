@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_ride_quotes_plan_id;
+DROP INDEX IF EXISTS idx_ride_quotes_user_id;
+DROP INDEX IF EXISTS uniq_ride_bookings_idempotency;
+DROP INDEX IF EXISTS uniq_ride_bookings_quote_id;
+
+CREATE INDEX IF NOT EXISTS idx_ride_bookings_quote_id ON ride_bookings(quote_id);
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS idempotency_key;
+
+DROP TABLE IF EXISTS ride_quotes;
@@ -0,0 +1,25 @@
+-- Ride Quotes
+CREATE TABLE IF NOT EXISTS ride_quotes (
+    id SERIAL PRIMARY KEY,
+    quote_id VARCHAR(255) UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    plan_id INTEGER NOT NULL REFERENCES trip_plans(id) ON DELETE CASCADE,
+    itinerary_id INTEGER NOT NULL REFERENCES itineraries(id) ON DELETE CASCADE,
+    provider VARCHAR(100) NOT NULL,
+    fare_cents INTEGER NOT NULL,
+    eta_minutes INTEGER NOT NULL,
+    surge_multiplier DOUBLE PRECISION DEFAULT 1 NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Idempotent bookings: one booking per quote and per (plan, Idempotency-Key)
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(255) NULL;
+UPDATE ride_bookings SET quote_id = NULL WHERE quote_id = '';
+DROP INDEX IF EXISTS idx_ride_bookings_quote_id;
+
+-- Indexes
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_ride_bookings_quote_id ON ride_bookings(quote_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_ride_bookings_idempotency ON ride_bookings(plan_id, idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_ride_quotes_user_id ON ride_quotes(user_id);
+CREATE INDEX IF NOT EXISTS idx_ride_quotes_plan_id ON ride_quotes(plan_id);
@@ -0,0 +1,5 @@
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS surge_quote_id;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS surge_fare_cents;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS surge_multiplier;
+
+DROP TABLE IF EXISTS user_preferences;
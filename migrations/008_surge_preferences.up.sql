@@ -0,0 +1,13 @@
+-- User Preferences
+CREATE TABLE IF NOT EXISTS user_preferences (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER UNIQUE NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    max_surge_multiplier DOUBLE PRECISION DEFAULT 1 NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Surge awaiting confirmation
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS surge_multiplier DOUBLE PRECISION DEFAULT 0 NOT NULL;
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS surge_fare_cents INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS surge_quote_id VARCHAR(255) DEFAULT '' NOT NULL;
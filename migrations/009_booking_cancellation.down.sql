@@ -0,0 +1,7 @@
+ALTER TABLE payments DROP COLUMN IF EXISTS refunded_cents;
+ALTER TABLE payments DROP COLUMN IF EXISTS captured_cents;
+
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS cancellation_fee_cents;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS cancel_reason;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS cancelled_by;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS cancelled_at;
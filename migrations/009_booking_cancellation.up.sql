@@ -0,0 +1,9 @@
+-- Booking cancellation details
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP NULL;
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS cancelled_by VARCHAR(20) DEFAULT '' NOT NULL;
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS cancel_reason VARCHAR(500) DEFAULT '' NOT NULL;
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS cancellation_fee_cents INTEGER DEFAULT 0 NOT NULL;
+
+-- Partial capture / refund amounts
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS captured_cents INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS refunded_cents INTEGER DEFAULT 0 NOT NULL;
@@ -0,0 +1,8 @@
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS driver_location_at;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS driver_lng;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS driver_lat;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS vehicle_model;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS vehicle_plate;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS driver_name;
+
+DROP TABLE IF EXISTS booking_events;
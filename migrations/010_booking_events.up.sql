@@ -0,0 +1,20 @@
+-- Booking status history
+CREATE TABLE IF NOT EXISTS booking_events (
+    id SERIAL PRIMARY KEY,
+    booking_id INTEGER NOT NULL REFERENCES ride_bookings(id) ON DELETE CASCADE,
+    from_status VARCHAR(30) DEFAULT '' NOT NULL,
+    to_status VARCHAR(30) NOT NULL,
+    actor VARCHAR(20) NOT NULL,
+    note VARCHAR(500) DEFAULT '' NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_booking_events_booking_id ON booking_events(booking_id);
+
+-- Driver details
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS driver_name VARCHAR(255) DEFAULT '' NOT NULL;
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS vehicle_plate VARCHAR(50) DEFAULT '' NOT NULL;
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS vehicle_model VARCHAR(255) DEFAULT '' NOT NULL;
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS driver_lat DOUBLE PRECISION NULL;
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS driver_lng DOUBLE PRECISION NULL;
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS driver_location_at TIMESTAMP NULL;
@@ -0,0 +1,2 @@
+-- What to do when a ride booking fails: retry|next_provider|alternative
+ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS ride_fallback VARCHAR(20) DEFAULT 'alternative' NOT NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_ride_bookings_pickup_at;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS pickup_at;
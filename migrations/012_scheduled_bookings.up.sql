@@ -0,0 +1,3 @@
+-- Advance bookings: dispatched to the provider ahead of pickup_at
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS pickup_at TIMESTAMP NULL;
+CREATE INDEX IF NOT EXISTS idx_ride_bookings_pickup_at ON ride_bookings(pickup_at);
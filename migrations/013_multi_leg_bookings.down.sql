@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS uniq_ride_bookings_idempotency;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_ride_bookings_idempotency ON ride_bookings(plan_id, idempotency_key);
+DROP INDEX IF EXISTS idx_ride_bookings_group_ref;
+DROP INDEX IF EXISTS idx_ride_bookings_leg_id;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS group_ref;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS leg_id;
+ALTER TABLE ride_quotes DROP COLUMN IF EXISTS leg_id;
+ALTER TABLE legs DROP COLUMN IF EXISTS fare_cents;
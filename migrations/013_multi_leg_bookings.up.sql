@@ -0,0 +1,11 @@
+-- Per-leg fares, and one booking per bookable leg of an itinerary
+ALTER TABLE legs ADD COLUMN IF NOT EXISTS fare_cents INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE ride_quotes ADD COLUMN IF NOT EXISTS leg_id INTEGER NULL REFERENCES legs(id) ON DELETE CASCADE;
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS leg_id INTEGER NULL REFERENCES legs(id) ON DELETE CASCADE;
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS group_ref VARCHAR(64) DEFAULT '' NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_ride_bookings_leg_id ON ride_bookings(leg_id);
+CREATE INDEX IF NOT EXISTS idx_ride_bookings_group_ref ON ride_bookings(group_ref);
+
+-- An Idempotency-Key now covers every leg booked by the request
+DROP INDEX IF EXISTS uniq_ride_bookings_idempotency;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_ride_bookings_idempotency ON ride_bookings(plan_id, idempotency_key, leg_id);
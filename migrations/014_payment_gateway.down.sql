@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_payments_external_ref;
+ALTER TABLE payments DROP COLUMN IF EXISTS failure_code;
+ALTER TABLE payments DROP COLUMN IF EXISTS gateway;
@@ -0,0 +1,4 @@
+-- Payments are held by a real (or mock) gateway instead of the stub
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS gateway VARCHAR(50) DEFAULT '' NOT NULL;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS failure_code VARCHAR(100) DEFAULT '' NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_payments_external_ref ON payments(external_ref);
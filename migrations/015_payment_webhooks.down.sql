@@ -0,0 +1,2 @@
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS payment_status;
+DROP TABLE IF EXISTS payment_webhook_events;
@@ -0,0 +1,21 @@
+-- Verified payment webhooks, processed asynchronously and replayable
+CREATE TABLE IF NOT EXISTS payment_webhook_events (
+    id SERIAL PRIMARY KEY,
+    gateway VARCHAR(50) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    type VARCHAR(100) NOT NULL,
+    charge_ref VARCHAR(255) DEFAULT '' NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(20) DEFAULT 'received' NOT NULL,
+    attempts INTEGER DEFAULT 0 NOT NULL,
+    last_error TEXT DEFAULT '' NOT NULL,
+    processed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_payment_webhook_events_event ON payment_webhook_events(gateway, event_id);
+CREATE INDEX IF NOT EXISTS idx_payment_webhook_events_charge_ref ON payment_webhook_events(charge_ref);
+CREATE INDEX IF NOT EXISTS idx_payment_webhook_events_status ON payment_webhook_events(status);
+
+-- The booking shows the state of its payment
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS payment_status VARCHAR(30) DEFAULT '' NOT NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE payments DROP COLUMN IF EXISTS idempotency_key;
+ALTER TABLE payments DROP COLUMN IF EXISTS discount_cents;
+ALTER TABLE payments DROP COLUMN IF EXISTS tip_cents;
+ALTER TABLE payments DROP COLUMN IF EXISTS fee_cents;
+ALTER TABLE payments DROP COLUMN IF EXISTS fare_cents;
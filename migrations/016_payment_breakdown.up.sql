@@ -0,0 +1,6 @@
+-- What an authorized amount was made of, and the client's key for retries
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS fare_cents INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS fee_cents INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS tip_cents INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS discount_cents INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(255) DEFAULT '' NOT NULL;
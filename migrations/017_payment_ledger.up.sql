@@ -0,0 +1,24 @@
+-- Payment ledger: every authorize/capture/refund/void with its amount
+CREATE TABLE IF NOT EXISTS payment_transactions (
+    id SERIAL PRIMARY KEY,
+    payment_id INTEGER NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    amount_cents INTEGER NOT NULL,
+    actor VARCHAR(20) NOT NULL,
+    note VARCHAR(500) DEFAULT '' NOT NULL,
+    idempotency_key VARCHAR(255) DEFAULT '' NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_payment_transactions_payment_id ON payment_transactions(payment_id);
+CREATE INDEX IF NOT EXISTS idx_payment_transactions_idempotency_key ON payment_transactions(idempotency_key);
+
+-- Ledger entries for payments made before the ledger existed
+INSERT INTO payment_transactions (payment_id, kind, amount_cents, actor, note, created_at)
+SELECT id, 'authorize', amount_cents, 'system', 'backfill', created_at FROM payments
+WHERE status NOT IN ('pending', 'declined');
+INSERT INTO payment_transactions (payment_id, kind, amount_cents, actor, note, created_at)
+SELECT id, 'capture', captured_cents, 'system', 'backfill', updated_at FROM payments WHERE captured_cents > 0;
+INSERT INTO payment_transactions (payment_id, kind, amount_cents, actor, note, created_at)
+SELECT id, 'refund', refunded_cents, 'system', 'backfill', updated_at FROM payments WHERE refunded_cents > 0;
+INSERT INTO payment_transactions (payment_id, kind, amount_cents, actor, note, created_at)
+SELECT id, 'void', 0, 'system', 'backfill', updated_at FROM payments WHERE status = 'voided';
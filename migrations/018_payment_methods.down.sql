@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_payments_payment_method_id;
+ALTER TABLE payments DROP COLUMN IF EXISTS payment_method_id;
+DROP TABLE IF EXISTS payment_methods;
@@ -0,0 +1,23 @@
+-- Saved payment methods: gateway references and display details only
+CREATE TABLE IF NOT EXISTS payment_methods (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL,
+    gateway VARCHAR(50) NOT NULL,
+    gateway_ref VARCHAR(255) DEFAULT '' NOT NULL,
+    provider VARCHAR(50) DEFAULT '' NOT NULL,
+    brand VARCHAR(50) DEFAULT '' NOT NULL,
+    last4 VARCHAR(4) DEFAULT '' NOT NULL,
+    exp_month INTEGER DEFAULT 0 NOT NULL,
+    exp_year INTEGER DEFAULT 0 NOT NULL,
+    label VARCHAR(100) DEFAULT '' NOT NULL,
+    is_default BOOLEAN DEFAULT FALSE NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_payment_methods_user_id ON payment_methods(user_id);
+-- at most one default per user
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_payment_methods_default ON payment_methods(user_id) WHERE is_default;
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS payment_method_id INTEGER NULL REFERENCES payment_methods(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_payments_payment_method_id ON payments(payment_method_id);
@@ -0,0 +1,14 @@
+ALTER TABLE user_preferences DROP COLUMN IF EXISTS currency;
+ALTER TABLE payments DROP COLUMN IF EXISTS fx_rate;
+ALTER TABLE payments DROP COLUMN IF EXISTS display_currency;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS fx_rate;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS display_currency;
+ALTER TABLE ride_quotes DROP COLUMN IF EXISTS fx_rate;
+ALTER TABLE ride_quotes DROP COLUMN IF EXISTS display_fare_cents;
+ALTER TABLE ride_quotes DROP COLUMN IF EXISTS display_currency;
+ALTER TABLE itineraries DROP COLUMN IF EXISTS fx_rate;
+ALTER TABLE itineraries DROP COLUMN IF EXISTS display_cost_cents;
+ALTER TABLE itineraries DROP COLUMN IF EXISTS display_currency;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS currency;
+ALTER TABLE ride_quotes DROP COLUMN IF EXISTS currency;
+ALTER TABLE itineraries DROP COLUMN IF EXISTS currency;
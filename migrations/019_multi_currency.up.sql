@@ -0,0 +1,19 @@
+-- Fares carry their region's currency; existing rows were all priced in THB
+ALTER TABLE itineraries ADD COLUMN IF NOT EXISTS currency VARCHAR(3) DEFAULT 'THB' NOT NULL;
+ALTER TABLE ride_quotes ADD COLUMN IF NOT EXISTS currency VARCHAR(3) DEFAULT 'THB' NOT NULL;
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS currency VARCHAR(3) DEFAULT 'THB' NOT NULL;
+
+-- Amounts converted to the user's preferred currency at quote time
+ALTER TABLE itineraries ADD COLUMN IF NOT EXISTS display_currency VARCHAR(3) DEFAULT '' NOT NULL;
+ALTER TABLE itineraries ADD COLUMN IF NOT EXISTS display_cost_cents INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE itineraries ADD COLUMN IF NOT EXISTS fx_rate DOUBLE PRECISION DEFAULT 0 NOT NULL;
+ALTER TABLE ride_quotes ADD COLUMN IF NOT EXISTS display_currency VARCHAR(3) DEFAULT '' NOT NULL;
+ALTER TABLE ride_quotes ADD COLUMN IF NOT EXISTS display_fare_cents INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE ride_quotes ADD COLUMN IF NOT EXISTS fx_rate DOUBLE PRECISION DEFAULT 0 NOT NULL;
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS display_currency VARCHAR(3) DEFAULT '' NOT NULL;
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS fx_rate DOUBLE PRECISION DEFAULT 0 NOT NULL;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS display_currency VARCHAR(3) DEFAULT '' NOT NULL;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS fx_rate DOUBLE PRECISION DEFAULT 0 NOT NULL;
+
+-- Preferred display currency; empty = local currency only
+ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS currency VARCHAR(3) DEFAULT '' NOT NULL;
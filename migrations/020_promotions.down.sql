@@ -0,0 +1,9 @@
+ALTER TABLE payments DROP COLUMN IF EXISTS credit_cents;
+DROP INDEX IF EXISTS idx_ride_bookings_promotion_id;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS credit_cents;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS discount_cents;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS promo_code;
+ALTER TABLE ride_bookings DROP COLUMN IF EXISTS promotion_id;
+DROP TABLE IF EXISTS credit_transactions;
+DROP TABLE IF EXISTS promotion_redemptions;
+DROP TABLE IF EXISTS promotions;
@@ -0,0 +1,57 @@
+-- Promo codes; Value is a percent or minor units of currency
+CREATE TABLE IF NOT EXISTS promotions (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(64) NOT NULL UNIQUE,
+    description TEXT DEFAULT '' NOT NULL,
+    kind VARCHAR(10) NOT NULL,
+    value INTEGER NOT NULL,
+    currency VARCHAR(3) DEFAULT 'THB' NOT NULL,
+    max_discount_cents INTEGER DEFAULT 0 NOT NULL,
+    min_fare_cents INTEGER DEFAULT 0 NOT NULL,
+    starts_at TIMESTAMP NULL,
+    ends_at TIMESTAMP NULL,
+    max_uses INTEGER DEFAULT 0 NOT NULL,
+    max_uses_per_user INTEGER DEFAULT 1 NOT NULL,
+    used_count INTEGER DEFAULT 0 NOT NULL,
+    providers TEXT DEFAULT '' NOT NULL,
+    mode_mixes TEXT DEFAULT '' NOT NULL,
+    active BOOLEAN DEFAULT TRUE NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- One redemption per booking; released when the booking ends without a ride
+CREATE TABLE IF NOT EXISTS promotion_redemptions (
+    id SERIAL PRIMARY KEY,
+    promotion_id INTEGER NOT NULL REFERENCES promotions(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    booking_id INTEGER NOT NULL UNIQUE,
+    status VARCHAR(20) DEFAULT 'applied' NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_promotion_redemptions_promotion_id ON promotion_redemptions(promotion_id);
+CREATE INDEX IF NOT EXISTS idx_promotion_redemptions_user_id ON promotion_redemptions(user_id);
+
+-- Append-only credit ledger; a user's balance is the sum per currency
+CREATE TABLE IF NOT EXISTS credit_transactions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(10) NOT NULL,
+    amount_cents INTEGER NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    booking_id INTEGER NULL,
+    actor VARCHAR(255) NOT NULL,
+    note TEXT DEFAULT '' NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_credit_transactions_user_id ON credit_transactions(user_id);
+CREATE INDEX IF NOT EXISTS idx_credit_transactions_booking_id ON credit_transactions(booking_id);
+
+-- The discounts applied to a booking, and the credit a payment used
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS promotion_id INTEGER NULL REFERENCES promotions(id) ON DELETE SET NULL;
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS promo_code VARCHAR(64) DEFAULT '' NOT NULL;
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS discount_cents INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE ride_bookings ADD COLUMN IF NOT EXISTS credit_cents INTEGER DEFAULT 0 NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_ride_bookings_promotion_id ON ride_bookings(promotion_id);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS credit_cents INTEGER DEFAULT 0 NOT NULL;
@@ -0,0 +1,6 @@
+ALTER TABLE user_preferences DROP COLUMN IF EXISTS email_receipts;
+ALTER TABLE user_preferences DROP COLUMN IF EXISTS billing_address;
+ALTER TABLE user_preferences DROP COLUMN IF EXISTS billing_tax_id;
+ALTER TABLE user_preferences DROP COLUMN IF EXISTS billing_name;
+DROP TABLE IF EXISTS invoice_counters;
+DROP TABLE IF EXISTS receipts;
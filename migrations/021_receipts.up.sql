@@ -0,0 +1,30 @@
+-- Receipts / tax invoices, frozen when issued; numbered PREFIX-YEAR-SEQ without gaps
+CREATE TABLE IF NOT EXISTS receipts (
+    id SERIAL PRIMARY KEY,
+    number VARCHAR(40) NOT NULL UNIQUE,
+    year INTEGER NOT NULL,
+    seq INTEGER NOT NULL,
+    payment_id INTEGER NOT NULL UNIQUE REFERENCES payments(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    currency VARCHAR(3) NOT NULL,
+    total_cents INTEGER NOT NULL,
+    tax_cents INTEGER NOT NULL,
+    document TEXT NOT NULL,
+    emailed_at TIMESTAMP NULL,
+    emailed_to VARCHAR(255) DEFAULT '' NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_receipts_seq ON receipts(year, seq);
+CREATE INDEX IF NOT EXISTS idx_receipts_user_id ON receipts(user_id);
+
+-- The last number issued per year; locked while a receipt is issued
+CREATE TABLE IF NOT EXISTS invoice_counters (
+    year INTEGER PRIMARY KEY,
+    last INTEGER DEFAULT 0 NOT NULL
+);
+
+-- Business details for tax invoices, and whether receipts are emailed
+ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS billing_name VARCHAR(200) DEFAULT '' NOT NULL;
+ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS billing_tax_id VARCHAR(20) DEFAULT '' NOT NULL;
+ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS billing_address TEXT DEFAULT '' NOT NULL;
+ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS email_receipts BOOLEAN DEFAULT FALSE NOT NULL;
@@ -0,0 +1,37 @@
+-- A booking's fare split between its owner and other users; each share has its own payment
+CREATE TABLE IF NOT EXISTS fare_splits (
+    id SERIAL PRIMARY KEY,
+    booking_id INTEGER NOT NULL REFERENCES ride_bookings(id) ON DELETE CASCADE,
+    owner_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) DEFAULT 'open' NOT NULL,
+    on_decline VARCHAR(20) DEFAULT 'owner_covers' NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    total_cents INTEGER NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    resolved_at TIMESTAMP NULL,
+    note TEXT DEFAULT '' NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_fare_splits_booking_id ON fare_splits(booking_id);
+CREATE INDEX IF NOT EXISTS idx_fare_splits_owner_id ON fare_splits(owner_id);
+CREATE INDEX IF NOT EXISTS idx_fare_splits_status ON fare_splits(status);
+-- one split at a time owns the booking's payment
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_fare_splits_active ON fare_splits(booking_id) WHERE status IN ('open', 'funded', 'settled');
+
+CREATE TABLE IF NOT EXISTS split_shares (
+    id SERIAL PRIMARY KEY,
+    split_id INTEGER NOT NULL REFERENCES fare_splits(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL,
+    amount_cents INTEGER NOT NULL,
+    status VARCHAR(20) DEFAULT 'invited' NOT NULL,
+    payment_id INTEGER NULL REFERENCES payments(id) ON DELETE SET NULL,
+    payment_status VARCHAR(30) DEFAULT '' NOT NULL,
+    responded_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_split_shares_user ON split_shares(split_id, user_id, role);
+CREATE INDEX IF NOT EXISTS idx_split_shares_user_id ON split_shares(user_id);
+CREATE INDEX IF NOT EXISTS idx_split_shares_payment_id ON split_shares(payment_id);